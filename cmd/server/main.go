@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"os"
 	"os/signal" 
 	"strconv"
 	"syscall"   
+	"time"
+	"so-http10-demo/internal/handlers"
+	"so-http10-demo/internal/http10"
+	"so-http10-demo/internal/jobs"
 	"so-http10-demo/internal/router"
+	"so-http10-demo/internal/sched"
 	"so-http10-demo/internal/server"
 )
 
@@ -19,8 +26,39 @@ func getenvInt(key string, def int) int {
 	return def
 }
 
+// getenvNonNeg es getenvInt pero acepta 0 (para knobs donde 0 apaga algo).
+func getenvNonNeg(key string, def int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return def
+}
 
 func main() {
+	http10.MaxHeaders = getenvInt("MAX_HEADERS", 100)
+	http10.MaxBodySize = int64(getenvInt("SERVER_MAX_BODY", 10<<20))
+	http10.Chunked = getenvInt("HTTP_CHUNKED", 0) == 1
+	http10.GzipMinSize = getenvInt("GZIP_MIN_BYTES", 1024)
+	server.WriteTimeout = time.Duration(getenvInt("WRITE_TIMEOUT_MS", 10000)) * time.Millisecond
+	server.MaxConnsPerIP = getenvNonNeg("MAX_CONNS_PER_IP", 64)
+	server.MaxConns = getenvNonNeg("SERVER_MAX_CONNS", 1024)
+	server.ReadTimeout = time.Duration(getenvInt("SERVER_READ_TIMEOUT_MS", 10000)) * time.Millisecond
+	server.KeepAliveTimeout = time.Duration(getenvInt("KEEPALIVE_TIMEOUT_MS", 5000)) * time.Millisecond
+	handlers.MaxTextLen = getenvInt("MAX_TEXT_LEN", 1<<20)
+	handlers.SetPiCacheSize(getenvInt("PI_CACHE_SIZE", 64))
+	jobs.JournalFsync = getenvInt("JOURNAL_FSYNC", 0) == 1
+	jobs.MaxJobs = getenvNonNeg("MAX_JOBS", 10000)
+	sched.AgingThreshold = time.Duration(getenvInt("SCHED_AGING_MS", 0)) * time.Millisecond
+	jitterPct := getenvNonNeg("RETRY_JITTER_PCT", 50) // 0..100; 0 = sin jitter
+	if jitterPct > 100 {
+		jitterPct = 100
+	}
+	sched.RetryJitter = float64(jitterPct) / 100
+	router.DrainTimeout = time.Duration(getenvInt("DRAIN_TIMEOUT_MS", 10000)) * time.Millisecond
+	shutdownGrace := time.Duration(getenvInt("SHUTDOWN_GRACE_MS", 10000)) * time.Millisecond
+
 	router.InitPools(map[string]int{
 	// básicos
 	"workers.sleep": getenvInt("WORKERS_SLEEP", 2),
@@ -35,10 +73,24 @@ func main() {
 	"queue.factor":       getenvInt("QUEUE_FACTOR", 64),
 	"workers.pi":         getenvInt("WORKERS_PI", 1),
 	"queue.pi":           getenvInt("QUEUE_PI", 8),
+	"workers.pidigit":    getenvInt("WORKERS_PIDIGIT", 1),
+	"queue.pidigit":      getenvInt("QUEUE_PIDIGIT", 8),
 	"workers.mandelbrot": getenvInt("WORKERS_MANDELBROT", 1),
 	"queue.mandelbrot":   getenvInt("QUEUE_MANDELBROT", 4),
+	"workers.julia":      getenvInt("WORKERS_JULIA", 1),
+	"queue.julia":        getenvInt("QUEUE_JULIA", 4),
 	"workers.matrixmul":  getenvInt("WORKERS_MATRIXMUL", 1),
 	"queue.matrixmul":    getenvInt("QUEUE_MATRIXMUL", 8),
+	"workers.determinant": getenvInt("WORKERS_DETERMINANT", 1),
+	"queue.determinant":   getenvInt("QUEUE_DETERMINANT", 8),
+	"workers.collatz":    getenvInt("WORKERS_COLLATZ", 2),
+	"queue.collatz":      getenvInt("QUEUE_COLLATZ", 64),
+	"workers.sieve":      getenvInt("WORKERS_SIEVE", 1),
+	"queue.sieve":        getenvInt("QUEUE_SIEVE", 8),
+	"workers.primesieve": getenvInt("WORKERS_PRIMESIEVE", 1),
+	"queue.primesieve":   getenvInt("QUEUE_PRIMESIEVE", 8),
+	"workers.ackermann":  getenvInt("WORKERS_ACKERMANN", 1),
+	"queue.ackermann":    getenvInt("QUEUE_ACKERMANN", 8),
 
 	// IO
 	"workers.wordcount":  getenvInt("WORKERS_WORDCOUNT", 2),
@@ -51,19 +103,44 @@ func main() {
 	"queue.sortfile":    getenvInt("QUEUE_SORTFILE", 4),
 	"workers.compress":  getenvInt("WORKERS_COMPRESS", 1),
 	"queue.compress":    getenvInt("QUEUE_COMPRESS", 4),
+	"workers.compressprobe": getenvInt("WORKERS_COMPRESSPROBE", 1),
+	"queue.compressprobe":   getenvInt("QUEUE_COMPRESSPROBE", 4),
+	"workers.decompress": getenvInt("WORKERS_DECOMPRESS", 1),
+	"queue.decompress":   getenvInt("QUEUE_DECOMPRESS", 4),
+	"workers.readfile":   getenvInt("WORKERS_READFILE", 2),
+	"queue.readfile":     getenvInt("QUEUE_READFILE", 16),
+	"workers.copyfile":   getenvInt("WORKERS_COPYFILE", 1),
+	"queue.copyfile":     getenvInt("QUEUE_COPYFILE", 8),
+	"workers.archive":    getenvInt("WORKERS_ARCHIVE", 1),
+	"queue.archive":      getenvInt("QUEUE_ARCHIVE", 4),
+	"workers.head":       getenvInt("WORKERS_HEAD", 2),
+	"queue.head":         getenvInt("QUEUE_HEAD", 16),
+	"workers.tail":       getenvInt("WORKERS_TAIL", 2),
+	"queue.tail":         getenvInt("QUEUE_TAIL", 16),
+	"workers.selftest":   getenvInt("WORKERS_SELFTEST", 1),
+	"queue.selftest":     getenvInt("QUEUE_SELFTEST", 2),
 	})
 
-	// cierre ordenado opcional
+	// cierre ordenado: SIGINT/SIGTERM dejan de aceptar conexiones, esperan los
+	// requests en vuelo (hasta SHUTDOWN_GRACE_MS) y drenan los pools (hasta
+	// DRAIN_TIMEOUT_MS)
     quit := make(chan os.Signal, 1)
+    stopped := make(chan struct{})
     signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
     go func() {
         <-quit
+        ctx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+        if err := server.Shutdown(ctx); err != nil {
+            log.Printf("shutdown: %v (requests en vuelo sin terminar)", err)
+        }
+        cancel()
         router.Close()
-        os.Exit(0)
+        close(stopped)
     }()
 
 	log.Println("HTTP/1.0 server starting on :8080")
-	if err := server.ListenAndServe(":8080"); err != nil {
+	if err := server.ListenAndServe(":8080"); !errors.Is(err, server.ErrServerClosed) {
 		log.Fatalf("listen failed: %v", err)
 	}
+	<-stopped
 }
@@ -68,17 +68,36 @@ func hashCore(text string) string {
 }
 
 // randomCore genera n enteros uniformes en [min, max] y los devuelve en JSON.
+// Con stats=true agrega {min,max,mean,count} calculado en el mismo recorrido.
 // PRECONDICIONES (garantizadas por el wrapper):
 //   - n >= 1
 //   - min <= max
-func randomCore(n, min, max int) string {
+func randomCore(n, min, max int, stats bool) string {
 	rand.Seed(time.Now().UnixNano())
 	arr := make([]int, n)
 	span := max - min + 1
+	lo, hi, sum := max, min, 0.0
 	for i := 0; i < n; i++ {
-		arr[i] = rand.Intn(span) + min
+		v := rand.Intn(span) + min
+		arr[i] = v
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+		sum += float64(v)
+	}
+	m := map[string]any{"values": arr}
+	if stats {
+		m["stats"] = map[string]any{
+			"min":   lo,
+			"max":   hi,
+			"mean":  sum / float64(n),
+			"count": n,
+		}
 	}
-	b, _ := json.Marshal(map[string]any{"values": arr})
+	b, _ := json.Marshal(m)
 	return string(b)
 }
 
@@ -112,47 +131,80 @@ func fibonacciCore(n int) string {
 // Help devuelve el listado de rutas disponibles (texto plano).
 func Help() resp.Result {
 	return resp.PlainOK(strings.TrimSpace(`
-/                      -> hola mundo
+/                      -> hola mundo (o ROOT_MESSAGE)
 /help                  -> este listado
-/status                -> estado del proceso + pools (pid, uptime, conns, colas, workers)
+/status                -> estado del proceso + pools (pid, uptime, conns, build, colas, workers)
 /metrics               -> metricas por pool (latencias, colas por prioridad, workers, contadores)
+/metrics/reset[?name=POOL] -> pone en cero contadores y latencias (un pool o todos)
+/healthz               -> liveness (200 si el proceso responde)
+/readyz                -> readiness (200 con pools y Job Manager listos; 503 si no)
+/selftest              -> smoke test: matrixmul, pi, isprime y sortfile contra valores conocidos (200 si pasan; 500 si no)
 
 # Basicas
 /fibonacci?num=N
 /reverse?text=abc
 /toupper?text=abc
-/random?count=n&min=a&max=b
+/random?count=n&min=a&max=b[&stats=true]
 /timestamp
 /hash?text=abc
 
 # Archivos (basico)
-/createfile?name=FILE&content=txt&repeat=x[&on_exist=rename|overwrite]
+/createfile?name=FILE&content=txt&repeat=x[&conflict=fail|overwrite|autorename|append][&hash=true][&newline=lf|crlf|none][&random_bytes=N[&seed=S]]
 /deletefile?name=FILE
+/listfiles[?pattern=REGEX]
+/movefile?from=FILE&to=FILE[&overwrite=true]
+/statfile?name=FILE[&hash=false]
 
 # Pools / simulacion
 /sleep?seconds=s
 /simulate?seconds=s&task=sleep|spin
 /loadtest?tasks=n&sleep=s
+/pools/resize?name=POOL&workers=N
+/pools/pause?name=POOL
+/pools/resume?name=POOL
 
 # CPU-bound
 /isprime?n=NUM[&method=division|miller-rabin]
-/factor?n=NUM
-/mandelbrot?width=W&height=H&max_iter=I
+/factor?n=NUM[&big=true]
+/pi?digits=D[&method=spigot|chudnovsky]
+/pi?method=bbp&index=N
+/pidigit?pos=N
+/mandelbrot?width=W&height=H&max_iter=I[&format=json|png]
+/julia?cre=X&cim=Y&width=W&height=H&max_iter=I
 /matrixmul?size=N&seed=S
+/determinant?size=N&seed=S
+/collatz?n=N
+/sieve?limit=N
+/primesieve?from=A&to=B
+/ackermann?m=M&n=N
 
 # IO-bound
-/wordcount?name=FILE
-/grep?name=FILE&pattern=REGEX
-/hashfile?name=FILE[&algo=sha256]
-/sortfile?name=FILE[&algo=merge|quick][&chunksize=N]
-/compress?name=FILE[&codec=gzip|xz]
+/wordcount?name=FILE[&top=N][&skip_blank=true]
+/grep?name=FILE&pattern=REGEX[&fixed=true][&word=true][&ignorecase=true][&count_only=true][&context=N][&capture=true]
+/hashfile?name=FILE[&algo=sha256|sha1|sha512|md5][&encoding=hex|base64]
+/sortfile?name=FILE[&algo=merge|quick][&chunksize=N][&order=asc|desc][&keytype=int|string][&dedup=1]
+/sortfile?premerged=true&names=C1,C2,...[&name=OUT]
+/compress?name=FILE[&codec=gzip|xz|zstd][&skip_if_incompressible=true]
+/compress/probe?name=FILE[&codec=gzip]   (muestra de 1 MiB en memoria con niveles 1, 5 y 9: ratio y tiempo; pool compressprobe)
+/decompress?name=FILE.gz|FILE.xz[&codec=gzip|xz]
+/readfile?name=FILE[&offset=N][&max_bytes=N]
+/copyfile?from=FILE&to=FILE[&conflict=fail|overwrite|autorename]
+/archive?names=A,B,...[&name=OUT][&codec=gzip]
+/head?name=FILE[&n=N]
+/tail?name=FILE[&n=N]
 
 # Jobs (ejecucion asincrona con colas por prioridad)
-/jobs/submit?task=TASK&<params>[&timeout_ms=MS][&prio=low|normal|high]
-/jobs/status?id=JOBID
+/jobs/submit?task=TASK&<params>[&timeout_ms=MS][&prio=low|normal|high][&callback_url=URL][&sync=true][&group=NAME[&group_limit=K]][&parent=JOBID][&tags=a,b][&repeat_every_ms=MS&repeat_count=K]
+/jobs/submit_batch?task=TASK&count=N&<params>   (N en 1..1000; devuelve job_ids)
+/jobs/status?id=JOBID | ids=ID1,ID2,...
 /jobs/result?id=JOBID
-/jobs/cancel?id=JOBID
-/jobs/list
+/jobs/wait?id=JOBID[&timeout_ms=MS]   (long-poll: espera a que termine, máx 60000; si no, 504 still_running)
+/jobs/cancel?id=JOBID | schedule=SCHEDID
+/jobs/schedule?id=SCHEDID
+/jobs/retry?id=JOBID   (job finalizado -> job nuevo con la misma task y params)
+/jobs/list[?status=S][&task=T][&tag=TAG][&offset=N][&limit=N]   (más nuevos primero; limit default 100, máx 1000)
+/jobs/export
+/jobs/metrics
 `) + "\n")
 }
 
@@ -163,25 +215,37 @@ func Timestamp(_ map[string]string) resp.Result {
 	return resp.JSONOK(timestampCore())
 }
 
+// MaxTextLen acota (en bytes) el text de /reverse y /toupper para no
+// convertir entradas enormes a []rune. Configurable con MAX_TEXT_LEN.
+var MaxTextLen = 1 << 20
+
 // Reverse invierte el texto recibido en ?text=... (UTF-8 seguro).
 // Errores:
 //   - 400 missing_param si falta text.
+//   - 413 too_large si len(text) > MaxTextLen.
 func Reverse(params map[string]string) resp.Result {
 	txt, ok := params["text"]
 	if !ok {
 		return resp.BadReq("missing_param", "text is required")
 	}
+	if len(txt) > MaxTextLen {
+		return resp.TooLarge("too_large", fmt.Sprintf("text exceeds %d bytes", MaxTextLen))
+	}
 	return resp.PlainOK(reverseCore(txt))
 }
 
 // ToUpper convierte a MAYÚSCULAS el parámetro ?text=...
 // Errores:
 //   - 400 missing_param si falta text.
+//   - 413 too_large si len(text) > MaxTextLen.
 func ToUpper(params map[string]string) resp.Result {
 	txt, ok := params["text"]
 	if !ok {
 		return resp.BadReq("missing_param", "text is required")
 	}
+	if len(txt) > MaxTextLen {
+		return resp.TooLarge("too_large", fmt.Sprintf("text exceeds %d bytes", MaxTextLen))
+	}
 	return resp.PlainOK(toUpperCore(txt))
 }
 
@@ -202,7 +266,8 @@ func Hash(params map[string]string) resp.Result {
 //   - min requerido, entero        → 400 si no.
 //   - max requerido, entero        → 400 si no.
 //   - min <= max                   → 400 "range" si no.
-// 200 + JSON {values:[...] } si todo OK.
+//   - stats opcional, true|false   → 400 si no es booleano.
+// 200 + JSON {values:[...] [,stats:{min,max,mean,count}]} si todo OK.
 func Random(params map[string]string) resp.Result {
 	cStr, ok := params["count"]
 	if !ok {
@@ -234,7 +299,12 @@ func Random(params map[string]string) resp.Result {
 		return resp.BadReq("range", "min must be <= max")
 	}
 
-	return resp.JSONOK(randomCore(count, min, max))
+	stats, ok := optBool(params["stats"])
+	if !ok {
+		return resp.BadReq("stats", "stats must be true|false")
+	}
+
+	return resp.JSONOK(randomCore(count, min, max, stats))
 }
 
 // Fibonacci devuelve el n-ésimo número de Fibonacci como texto terminado en "\n".
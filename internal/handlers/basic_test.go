@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"math"
 	"strings"
 	"sync"
 	"testing"
@@ -115,7 +116,7 @@ func TestRandomCore(t *testing.T) {
 	type out struct {
 		Values []int `json:"values"`
 	}
-	o := mustParseJSON[out](t, randomCore(20, -1, 1))
+	o := mustParseJSON[out](t, randomCore(20, -1, 1, false))
 	if len(o.Values) != 20 {
 		t.Fatalf("len=%d want 20", len(o.Values))
 	}
@@ -126,6 +127,41 @@ func TestRandomCore(t *testing.T) {
 	}
 }
 
+func TestRandomCore_StatsMatchValues(t *testing.T) {
+	t.Parallel()
+	type out struct {
+		Values []int `json:"values"`
+		Stats  struct {
+			Min   int     `json:"min"`
+			Max   int     `json:"max"`
+			Mean  float64 `json:"mean"`
+			Count int     `json:"count"`
+		} `json:"stats"`
+	}
+	o := mustParseJSON[out](t, randomCore(50, -10, 10, true))
+	lo, hi, sum := o.Values[0], o.Values[0], 0
+	for _, v := range o.Values {
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+		sum += v
+	}
+	mean := float64(sum) / float64(len(o.Values))
+	if o.Stats.Count != 50 || o.Stats.Min != lo || o.Stats.Max != hi || math.Abs(o.Stats.Mean-mean) > 1e-9 {
+		t.Fatalf("stats=%+v want min=%d max=%d mean=%v count=50", o.Stats, lo, hi, mean)
+	}
+
+	if r := Random(map[string]string{"count": "1", "min": "0", "max": "1", "stats": "x"}); r.Status != 400 {
+		t.Fatalf("bad stats flag -> 400: %+v", r)
+	}
+	if r := Random(map[string]string{"count": "3", "min": "0", "max": "1"}); strings.Contains(r.Body, "stats") {
+		t.Fatalf("stats must be off by default: %s", r.Body)
+	}
+}
+
 // ---------- tests for exported handlers ----------
 
 func TestHelpContainsRoutes(t *testing.T) {
@@ -194,6 +230,25 @@ func TestReverseAndToUpperHandlers(t *testing.T) {
 	}
 }
 
+func TestReverseAndToUpper_TooLarge(t *testing.T) {
+	old := MaxTextLen
+	MaxTextLen = 8
+	defer func() { MaxTextLen = old }()
+
+	big := strings.Repeat("a", 9)
+	for name, h := range map[string]func(map[string]string) resp.Result{"reverse": Reverse, "toupper": ToUpper} {
+		r := h(map[string]string{"text": big})
+		if r.Status != 413 || r.Err == nil || r.Err.Code != "too_large" {
+			t.Fatalf("%s oversized: %+v", name, r)
+		}
+		// justo en el límite sigue siendo válido
+		ok := h(map[string]string{"text": big[:8]})
+		if ok.Status != 200 {
+			t.Fatalf("%s at limit: %+v", name, ok)
+		}
+	}
+}
+
 func TestHashHandler(t *testing.T) {
 	t.Parallel()
 	// OK
@@ -9,26 +9,42 @@
 //
 // Endpoints cubiertos:
 //   /isprime?n=NUM[&method=division|miller-rabin]
-//   /factor?n=NUM
-//   /pi?digits=D[&method=spigot|chudnovsky]
-//   /mandelbrot?width=W&height=H&max_iter=I
+//   /factor?n=NUM[&big=true]
+//   /pi?digits=D[&method=spigot|chudnovsky] | /pi?method=bbp&index=N
+//   /pidigit?pos=N
+//   /mandelbrot?width=W&height=H&max_iter=I[&format=json|png]
+//   /julia?cre=X&cim=Y&width=W&height=H&max_iter=I
 //   /matrixmul?size=N&seed=S
+//   /determinant?size=N&seed=S
+//   /collatz?n=N
+//   /sieve?limit=N
+//   /primesieve?from=A&to=B
+//   /ackermann?m=M&n=N
 package handlers
 
 import (
+	"bytes"
+	"container/list"
 	"context"
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
 	"math"
 	"math/big"
 	"math/cmplx"
 	"math/rand"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"so-http10-demo/internal/util"
 	"so-http10-demo/internal/resp"
 )
 
@@ -183,11 +199,20 @@ func mrIsPrime64Ctx(ctx context.Context, n uint64) bool {
 // ============================================================================
 // /factor — factorización por división trial (con conteos).
 // - Parám. requeridos: n (>=2)
+// - Parám. opcional : big=true fuerza el modo big.Int; también se activa
+//                     solo si n no cabe en int64.
 // - Cancelación: chequeos periódicos.
 // - JSON: { "n", "factors":[[p,c],...], "elapsed_ms" }
+//   (modo big: n y p como strings, más "big":true)
 // ============================================================================
 func FactorJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	if params["big"] == "true" {
+		return factorBigJSONCtx(ctx, params["n"])
+	}
 	n64, err := strconv.ParseInt(params["n"], 10, 64)
+	if errors.Is(err, strconv.ErrRange) && !strings.HasPrefix(params["n"], "-") {
+		return factorBigJSONCtx(ctx, params["n"])
+	}
 	if err != nil || n64 < 2 {
 		return resp.BadReq("n", "n must be integer >= 2")
 	}
@@ -253,23 +278,177 @@ func FactorJSONCtx(ctx context.Context, params map[string]string) resp.Result {
 }
 
 
+// factorBigJSONCtx factoriza enteros arbitrarios con big.Int:
+// división trial por primos pequeños y luego Pollard's rho (Brent)
+// sobre los cofactores compuestos que queden.
+func factorBigJSONCtx(ctx context.Context, raw string) resp.Result {
+	n, ok := new(big.Int).SetString(raw, 10)
+	if !ok || n.Cmp(big.NewInt(2)) < 0 {
+		return resp.BadReq("n", "n must be integer >= 2")
+	}
+	start := time.Now()
+
+	counts := map[string]int64{}
+	var order []*big.Int
+	add := func(p *big.Int) {
+		k := p.String()
+		if counts[k] == 0 {
+			order = append(order, new(big.Int).Set(p))
+		}
+		counts[k]++
+	}
+
+	// Trial division con divisores chicos (barato y quita la mayoría de factores)
+	rest := new(big.Int).Set(n)
+	mod := new(big.Int)
+	for d := int64(2); d < 10000; d++ {
+		if d&1023 == 0 && canceled(ctx) {
+			return ctxErrResult(ctx)
+		}
+		bd := big.NewInt(d)
+		for {
+			q, r := new(big.Int).QuoRem(rest, bd, mod)
+			if r.Sign() != 0 {
+				break
+			}
+			add(bd)
+			rest = q
+		}
+		if rest.Cmp(big.NewInt(1)) == 0 {
+			break
+		}
+	}
+
+	// Pollard's rho sobre lo que queda
+	stack := []*big.Int{}
+	if rest.Cmp(big.NewInt(1)) > 0 {
+		stack = append(stack, rest)
+	}
+	for len(stack) > 0 {
+		m := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if m.ProbablyPrime(20) {
+			add(m)
+			continue
+		}
+		f := pollardRhoCtx(ctx, m)
+		if f == nil {
+			return ctxErrResult(ctx)
+		}
+		stack = append(stack, f, new(big.Int).Quo(m, f))
+	}
+
+	sort.Slice(order, func(i, j int) bool { return order[i].Cmp(order[j]) < 0 })
+	facts := make([][2]string, 0, len(order))
+	for _, p := range order {
+		k := p.String()
+		facts = append(facts, [2]string{k, strconv.FormatInt(counts[k], 10)})
+	}
+
+	type outT struct {
+		N         string      `json:"n"`
+		Big       bool        `json:"big"`
+		Factors   [][2]string `json:"factors"`
+		ElapsedMS int64       `json:"elapsed_ms"`
+	}
+	b, _ := json.Marshal(outT{
+		N:         n.String(),
+		Big:       true,
+		Factors:   facts,
+		ElapsedMS: time.Since(start).Milliseconds(),
+	})
+	return resp.JSONOK(string(b))
+}
+
+// pollardRhoCtx devuelve un factor no trivial de n (compuesto e impar),
+// usando la variante de Brent; prueba distintas constantes c si el ciclo
+// degenera. Devuelve nil si ctx se cancela.
+func pollardRhoCtx(ctx context.Context, n *big.Int) *big.Int {
+	one := big.NewInt(1)
+	if n.Bit(0) == 0 {
+		return big.NewInt(2)
+	}
+	for c := int64(1); ; c++ {
+		bc := big.NewInt(c)
+		f := func(x *big.Int) *big.Int {
+			x.Mul(x, x)
+			x.Add(x, bc)
+			return x.Mod(x, n)
+		}
+		y := big.NewInt(2)
+		var x *big.Int
+		g := big.NewInt(1)
+		q := big.NewInt(1)
+		ys := new(big.Int)
+		diff := new(big.Int)
+		r, m := 1, 128
+		iters := 0
+		for g.Cmp(one) == 0 {
+			x = new(big.Int).Set(y)
+			for i := 0; i < r; i++ {
+				f(y)
+			}
+			for k := 0; k < r && g.Cmp(one) == 0; k += m {
+				ys.Set(y)
+				for i := 0; i < m && i < r-k; i++ {
+					f(y)
+					diff.Sub(x, y)
+					q.Mul(q, diff.Abs(diff))
+					q.Mod(q, n)
+				}
+				g.GCD(nil, nil, q, n)
+				iters++
+				if iters&63 == 0 && canceled(ctx) {
+					return nil
+				}
+			}
+			r *= 2
+		}
+		if g.Cmp(n) == 0 {
+			// retroceder paso a paso desde ys
+			for {
+				if canceled(ctx) {
+					return nil
+				}
+				f(ys)
+				diff.Sub(x, ys)
+				g.GCD(nil, nil, diff.Abs(diff), n)
+				if g.Cmp(one) > 0 {
+					break
+				}
+			}
+		}
+		if g.Cmp(n) != 0 {
+			return g
+		}
+	}
+}
+
+
 // ============================================================================
 // /pi — cálculo de π con dos métodos: "chudnovsky" (rápido) y "spigot" (simple).
 // - Parám. requeridos: digits (>=1; cap a 10000)
 // - Parám. opcional : method=chudnovsky|spigot (default: chudnovsky)
+// - method=bbp      : extrae un único dígito hex en index (ver piBBPJSONCtx);
+//                     si viene index sin method, también se usa bbp.
 // - Cancelación     : chequeos periódicos; NO maneja timeout local.
 // - JSON            : { "digits","method","iterations","truncated","pi","elapsed_ms" }
 // ============================================================================
+const PiMaxDigits = 10000 // también acota la memoria del spigot: (10·(d+guarda))/3+1 ints, ~267 KB
+
 func PiJSONCtx(ctx context.Context, params map[string]string) resp.Result {
-	const maxDigits = 10000
+	// BBP tiene otra forma de respuesta: se decide antes de exigir digits
+	if _, hasIndex := params["index"]; params["method"] == "bbp" || (params["method"] == "" && hasIndex) {
+		return piBBPJSONCtx(ctx, params)
+	}
 
 	// digits requerido
 	d, err := strconv.Atoi(params["digits"])
 	if err != nil || d < 1 {
 		return resp.BadReq("digits", "digits must be integer >= 1")
 	}
-	if d > maxDigits {
-		d = maxDigits
+	if d > PiMaxDigits {
+		d = PiMaxDigits
 	}
 
 	// method=spigot|chudnovsky (default chudnovsky)
@@ -278,7 +457,7 @@ func PiJSONCtx(ctx context.Context, params map[string]string) resp.Result {
 		method = "chudnovsky"
 	}
 	if method != "spigot" && method != "chudnovsky" {
-		return resp.BadReq("method", "use method=spigot|chudnovsky")
+		return resp.BadReq("method", "use method=spigot|chudnovsky|bbp")
 	}
 
 	start := time.Now()
@@ -286,11 +465,21 @@ func PiJSONCtx(ctx context.Context, params map[string]string) resp.Result {
 	var iters int
 	var truncated bool
 
-	switch method {
-	case "spigot":
-		s, iters, truncated = piSpigotCtx(ctx, d)
-	case "chudnovsky":
-		s, iters, truncated = piChudnovskyCtx(ctx, d)
+	key := method + ":" + strconv.Itoa(d)
+	e, cached := piCache.get(key)
+	if cached {
+		s, iters = e.pi, e.iters
+	} else {
+		switch method {
+		case "spigot":
+			s, iters, truncated = piSpigotCtx(ctx, d)
+		case "chudnovsky":
+			s, iters, truncated = piChudnovskyCtx(ctx, d)
+		}
+		// sólo se guardan resultados completos (no truncados por cancelación)
+		if !truncated {
+			piCache.put(key, piEntry{pi: s, iters: iters})
+		}
 	}
 
 	type outT struct {
@@ -298,6 +487,7 @@ func PiJSONCtx(ctx context.Context, params map[string]string) resp.Result {
 		Method     string `json:"method"`
 		Iterations int    `json:"iterations"`
 		Truncated  bool   `json:"truncated"`
+		Cached     bool   `json:"cached,omitempty"`
 		Pi         string `json:"pi"`
 		Elapsed    int64  `json:"elapsed_ms"`
 	}
@@ -306,6 +496,7 @@ func PiJSONCtx(ctx context.Context, params map[string]string) resp.Result {
 		Method:     method,
 		Iterations: iters,
 		Truncated:  truncated,
+		Cached:     cached,
 		Pi:         s,
 		Elapsed:    time.Since(start).Milliseconds(),
 	}
@@ -313,6 +504,129 @@ func PiJSONCtx(ctx context.Context, params map[string]string) resp.Result {
 	return resp.JSONOK(string(b))
 }
 
+// ---- Cache LRU de /pi ----
+
+// PiCacheSize es la cantidad máxima de resultados (method:digits) que guarda
+// la cache de /pi. Configurable con PI_CACHE_SIZE; se aplica con SetPiCacheSize.
+var PiCacheSize = 64
+
+// piCache guarda resultados completos de /pi por "method:digits".
+var piCache = newPiLRU(PiCacheSize)
+
+// SetPiCacheSize reemplaza la cache por una vacía de tamaño n (n>=1).
+func SetPiCacheSize(n int) {
+	if n < 1 {
+		n = 1
+	}
+	PiCacheSize = n
+	piCache = newPiLRU(n)
+}
+
+// PiCacheStats devuelve los contadores de la cache para /metrics.
+func PiCacheStats() map[string]any { return piCache.stats() }
+
+type piEntry struct {
+	pi    string
+	iters int
+}
+
+type piLRUItem struct {
+	key string
+	val piEntry
+}
+
+// piLRU es una LRU acotada: lista doblemente enlazada (frente = más
+// reciente) + índice por clave. Cuenta hits, misses y evictions.
+type piLRU struct {
+	mu    sync.Mutex
+	cap   int
+	ll    *list.List
+	index map[string]*list.Element
+
+	hits, misses, evictions uint64
+}
+
+func newPiLRU(capacity int) *piLRU {
+	return &piLRU{cap: capacity, ll: list.New(), index: make(map[string]*list.Element)}
+}
+
+func (c *piLRU) get(key string) (piEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.index[key]; ok {
+		c.ll.MoveToFront(el)
+		c.hits++
+		return el.Value.(*piLRUItem).val, true
+	}
+	c.misses++
+	return piEntry{}, false
+}
+
+func (c *piLRU) put(key string, v piEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.index[key]; ok {
+		el.Value.(*piLRUItem).val = v
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.index[key] = c.ll.PushFront(&piLRUItem{key: key, val: v})
+	for c.ll.Len() > c.cap {
+		old := c.ll.Back()
+		c.ll.Remove(old)
+		delete(c.index, old.Value.(*piLRUItem).key)
+		c.evictions++
+	}
+}
+
+func (c *piLRU) stats() map[string]any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return map[string]any{
+		"size":      c.ll.Len(),
+		"capacity":  c.cap,
+		"hits":      c.hits,
+		"misses":    c.misses,
+		"evictions": c.evictions,
+	}
+}
+
+// piBBPJSONCtx resuelve /pi?method=bbp&index=N: dígito hex N (0-based tras
+// el punto) con la misma serie que /pidigit.
+// - JSON: { "method":"bbp","index","hex_digit","elapsed_ms" }
+func piBBPJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	const maxIndex = 1_000_000
+
+	idx, err := strconv.Atoi(params["index"])
+	if err != nil || idx < 0 || idx >= maxIndex {
+		return resp.BadReq("index", "index must be integer in [0, 999999]")
+	}
+	start := time.Now()
+
+	d, ok := bbpHexDigitCtx(ctx, idx)
+	if !ok {
+		return resp.Unavail("canceled", "job canceled")
+	}
+
+	type outT struct {
+		Method   string `json:"method"`
+		Index    int    `json:"index"`
+		HexDigit string `json:"hex_digit"`
+		Elapsed  int64  `json:"elapsed_ms"`
+	}
+	b, _ := json.Marshal(outT{
+		Method:   "bbp",
+		Index:    idx,
+		HexDigit: strings.ToUpper(strconv.FormatInt(int64(d), 16)),
+		Elapsed:  time.Since(start).Milliseconds(),
+	})
+	return resp.JSONOK(string(b))
+}
+
+// spigotGuard son decimales extra que se calculan y se descartan: con el
+// arreglo justo para n dígitos los últimos salen mal (p. ej. 3.14158 para n=5).
+const spigotGuard = 10
+
 // piSpigotCtx: Spigot (Rabinowitz–Wagon, base 10) con soporte de ctx.
 // Devuelve "3." + d decimales exactos (sin redondear), el número de
 // iteraciones internas y un flag si se truncó por cancelación.
@@ -321,7 +635,8 @@ func piSpigotCtx(ctx context.Context, n int) (string, int, bool) {
 		return "3", 0, false
 	}
 
-	size := (10*n)/3 + 1
+	m := n + spigotGuard // dígitos a generar; se devuelven n
+	size := (10*m)/3 + 1
 	a := make([]int, size)
 	for i := range a {
 		a[i] = 2
@@ -339,10 +654,10 @@ func piSpigotCtx(ctx context.Context, n int) (string, int, bool) {
 	predigit := 0
 	iters := 0
 
-	out := make([]byte, 0, n+2)
+	out := make([]byte, 0, m+2)
 	out = append(out, '3', '.')
 
-	for digits := 0; digits < n; {
+	for digits := 0; digits < m; {
 		// cancelación periódica
 		if (digits & 63) == 0 {
 			select {
@@ -411,7 +726,7 @@ func piSpigotCtx(ctx context.Context, n int) (string, int, bool) {
 		}
 	}
 
-	// Empujar el último predigit para completar exactamente n decimales
+	// Empujar el último predigit (por si acaso) y quedarse con n decimales
 	if len(out) < 2+n {
 		out = append(out, byte(predigit)+'0')
 	}
@@ -475,7 +790,8 @@ func piChudnovskyCtx(ctx context.Context, d int) (string, int, bool) {
 		k++
 		sign *= -1
 
-		num := new(big.Float).SetPrec(bits).SetFloat64(float64(6*k - 5))
+		// t_k/t_{k-1} = (6k)!/(6k-6)! · (3k-3)!/(3k)! / (k³·C³) = 8(6k-5)(6k-3)(6k-1) / (k³·C³)
+		num := new(big.Float).SetPrec(bits).SetFloat64(float64(8 * (6*k - 5)))
 		num.Mul(num, new(big.Float).SetPrec(bits).SetFloat64(float64(6*k - 3)))
 		num.Mul(num, new(big.Float).SetPrec(bits).SetFloat64(float64(6*k - 1)))
 
@@ -490,7 +806,8 @@ func piChudnovskyCtx(ctx context.Context, d int) (string, int, bool) {
 	den := new(big.Float).SetPrec(bits).Mul(new(big.Float).SetPrec(bits).SetFloat64(12.0), sum)
 	pi := new(big.Float).SetPrec(bits).Quo(c3Sqrt, den)
 
-	txt := pi.Text('f', d) // “3.” + d decimales
+	// Se piden decimales de más y se corta: truncar (como el spigot), no redondear
+	txt := pi.Text('f', d+3)
 	truncated := false
 	if idx := strings.IndexByte(txt, '.'); idx >= 0 {
 		want := idx + 1 + d
@@ -504,13 +821,118 @@ func piChudnovskyCtx(ctx context.Context, d int) (string, int, bool) {
 }
 
 
+// ============================================================================
+// /pidigit — N-ésimo dígito hexadecimal de π con Bailey–Borwein–Plouffe.
+// - No calcula los dígitos previos: usa exponenciación modular por término.
+// - Parám. requeridos: pos (1..1_000_000); pos=1 es el primer dígito tras el punto.
+// - Cancelación     : chequeos periódicos dentro de la serie.
+// - JSON            : { "pos","hex_digit","elapsed_ms" }
+// ============================================================================
+func PiDigitJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	const maxPos = 1_000_000
+
+	pos, err := strconv.Atoi(params["pos"])
+	if err != nil || pos < 1 || pos > maxPos {
+		return resp.BadReq("pos", "pos must be integer in [1, 1000000]")
+	}
+	start := time.Now()
+
+	d, ok := bbpHexDigitCtx(ctx, pos-1)
+	if !ok {
+		return resp.Unavail("canceled", "job canceled")
+	}
+
+	type outT struct {
+		Pos      int    `json:"pos"`
+		HexDigit string `json:"hex_digit"`
+		Elapsed  int64  `json:"elapsed_ms"`
+	}
+	b, _ := json.Marshal(outT{
+		Pos:      pos,
+		HexDigit: strings.ToUpper(strconv.FormatInt(int64(d), 16)),
+		Elapsed:  time.Since(start).Milliseconds(),
+	})
+	return resp.JSONOK(string(b))
+}
+
+// bbpHexDigitCtx devuelve el dígito hex en la posición n (0-based tras el punto):
+//   16^n·π mod 1 = 4·S(1) − 2·S(4) − S(5) − S(6)  (mod 1)
+// Devuelve ok=false si ctx se cancela a mitad de la serie.
+func bbpHexDigitCtx(ctx context.Context, n int) (int, bool) {
+	s1, ok1 := bbpSeriesCtx(ctx, 1, n)
+	s4, ok4 := bbpSeriesCtx(ctx, 4, n)
+	s5, ok5 := bbpSeriesCtx(ctx, 5, n)
+	s6, ok6 := bbpSeriesCtx(ctx, 6, n)
+	if !ok1 || !ok4 || !ok5 || !ok6 {
+		return 0, false
+	}
+	x := 4*s1 - 2*s4 - s5 - s6
+	x -= math.Floor(x)
+	return int(16 * x), true
+}
+
+// bbpSeriesCtx calcula la parte fraccionaria de Σ 16^(n-k)/(8k+j).
+// Tramo k<=n con aritmética modular entera; cola k>n en float hasta que
+// los términos dejan de aportar.
+func bbpSeriesCtx(ctx context.Context, j, n int) (float64, bool) {
+	s := 0.0
+	for k := 0; k <= n; k++ {
+		if k&0xFFFF == 0 {
+			select {
+			case <-ctx.Done():
+				return 0, false
+			default:
+			}
+		}
+		r := int64(8*k + j)
+		s += float64(powMod(16, int64(n-k), r)) / float64(r)
+		s -= math.Floor(s)
+	}
+	for k := n + 1; ; k++ {
+		t := math.Pow(16, float64(n-k)) / float64(8*k+j)
+		if t < 1e-17 {
+			break
+		}
+		s += t
+	}
+	return s - math.Floor(s), true
+}
+
+// powMod calcula b^e mod m con enteros de 64 bits (m < 2^31 en la práctica).
+func powMod(b, e, m int64) int64 {
+	if m == 1 {
+		return 0
+	}
+	r := int64(1)
+	b %= m
+	for e > 0 {
+		if e&1 == 1 {
+			r = r * b % m
+		}
+		b = b * b % m
+		e >>= 1
+	}
+	return r
+}
+
+
 // ============================================================================
 // /mandelbrot — genera mapa de iteraciones (matriz de int) en JSON.
 // - Parám. requeridos: width>0, height>0, max_iter>0 (cap en 512x512, 2000)
+// - Parám. opcional : format=json|png (default json). png devuelve una imagen
+//   en escala de grises (negro = no escapa) en vez del mapa.
 // - Cancelación: chequeos dentro de los bucles
 // - JSON: { "width","height","max_iter","map":[[...]],"elapsed_ms" }
 // ============================================================================
 func MandelbrotJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	format := params["format"]
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "png" {
+		return resp.BadReq("format", "use format=json|png")
+	}
+
 	// Parseo y validación de parámetros
 	w, errW := strconv.Atoi(params["width"])
 	h, errH := strconv.Atoi(params["height"])
@@ -567,6 +989,16 @@ func MandelbrotJSONCtx(ctx context.Context, params map[string]string) resp.Resul
 			row[x] = iter
 		}
 		img[y] = row
+		// avance real por fila (lo muestra /jobs/status si corre como job)
+		util.ReportProgress(ctx, int64(y+1), int64(h))
+	}
+
+	if format == "png" {
+		data, err := iterMapPNG(img, w, h, it)
+		if err != nil {
+			return resp.IntErr("png", "encode failed")
+		}
+		return resp.Binary("image/png", data)
 	}
 
 	out := map[string]any{
@@ -580,6 +1012,111 @@ func MandelbrotJSONCtx(ctx context.Context, params map[string]string) resp.Resul
 	return resp.JSONOK(string(b))
 }
 
+// iterMapPNG codifica el mapa de iteraciones como PNG en escala de grises:
+// los puntos que escapan rápido quedan claros y los que llegan a maxIter
+// (dentro del conjunto) quedan negros.
+func iterMapPNG(m [][]int, w, h, maxIter int) ([]byte, error) {
+	g := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			g.SetGray(x, y, color.Gray{Y: uint8(255 - m[y][x]*255/maxIter)})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, g); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+
+// ============================================================================
+// /julia — conjunto de Julia para una constante c fija (cre + cim·i).
+// - Parám. requeridos: cre, cim (float), width>0, height>0, max_iter>0
+//   (mismos caps que /mandelbrot: 512x512, 2000)
+// - z arranca en la coordenada del píxel y se itera z = z² + c.
+// - Cancelación: chequeos por fila y dentro del bucle de iteración
+// - JSON: { "width","height","max_iter","cre","cim","map":[[...]],"elapsed_ms" }
+// ============================================================================
+func JuliaJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	cre, errR := strconv.ParseFloat(params["cre"], 64)
+	cim, errC := strconv.ParseFloat(params["cim"], 64)
+	if errR != nil || errC != nil || math.IsNaN(cre) || math.IsNaN(cim) || math.IsInf(cre, 0) || math.IsInf(cim, 0) {
+		return resp.BadReq("params", "cre,cim must be finite floats")
+	}
+	w, errW := strconv.Atoi(params["width"])
+	h, errH := strconv.Atoi(params["height"])
+	it, errI := strconv.Atoi(params["max_iter"])
+	if errW != nil || errH != nil || errI != nil {
+		return resp.BadReq("params", "width,height,max_iter must be integers")
+	}
+	if w <= 0 || h <= 0 || it <= 0 {
+		return resp.BadReq("params", "width,height,max_iter must be > 0")
+	}
+	if w > 512 { w = 512 }
+	if h > 512 { h = 512 }
+	if it > 2000 { it = 2000 }
+
+	start := time.Now()
+
+	// Ventana simétrica que contiene los Julia conexos habituales
+	minRe, maxRe := -1.5, 1.5
+	minIm, maxIm := -1.5, 1.5
+	// evita dividir por cero con imágenes de 1 píxel de ancho/alto
+	span := func(n int) float64 {
+		if n <= 1 {
+			return 1
+		}
+		return float64(n - 1)
+	}
+	c := complex(cre, cim)
+
+	img := make([][]int, h)
+	for y := 0; y < h; y++ {
+		if y&63 == 0 {
+			select {
+			case <-ctx.Done():
+				return resp.Unavail("canceled", "job canceled")
+			default:
+			}
+		}
+		row := make([]int, w)
+		zi := minIm + (maxIm-minIm)*float64(y)/span(h)
+		for x := 0; x < w; x++ {
+			zr := minRe + (maxRe-minRe)*float64(x)/span(w)
+			z := complex(zr, zi)
+			iter := 0
+			for iter = 0; iter < it; iter++ {
+				if iter&255 == 0 {
+					select {
+					case <-ctx.Done():
+						return resp.Unavail("canceled", "job canceled")
+					default:
+					}
+				}
+				if cmplx.Abs(z) > 2.0 { // escape
+					break
+				}
+				z = z*z + c
+			}
+			row[x] = iter
+		}
+		img[y] = row
+	}
+
+	out := map[string]any{
+		"width":      w,
+		"height":     h,
+		"max_iter":   it,
+		"cre":        cre,
+		"cim":        cim,
+		"map":        img,
+		"elapsed_ms": time.Since(start).Milliseconds(),
+	}
+	b, _ := json.Marshal(out)
+	return resp.JSONOK(string(b))
+}
+
 
 // ============================================================================
 // /matrixmul — multiplicación de matrices NxN con hash del resultado.
@@ -680,3 +1217,444 @@ func MatrixMulHashCtx(ctx context.Context, params map[string]string) resp.Result
 	b, _ := json.Marshal(out)
 	return resp.JSONOK(string(b))
 }
+
+
+// ============================================================================
+// /collatz — tiempo de parada de Collatz (pasos hasta llegar a 1).
+// - Parám. requeridos: n (>=1, int64)
+// - Aritmética uint64 con chequeo de overflow; si 3n+1 no cabe se continúa
+//   con big.Int (los picos pueden superar 2^63).
+// - Cancelación: chequeo de ctx.Done() cada 4096 iteraciones.
+// - JSON: { "n","steps","max_value","elapsed_ms" }
+// ============================================================================
+func CollatzJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	n, err := strconv.ParseInt(params["n"], 10, 64)
+	if err != nil || n < 1 {
+		return resp.BadReq("n", "n must be integer >= 1")
+	}
+	start := time.Now()
+
+	steps, peak, ok := collatzCtx(ctx, n)
+	if !ok {
+		return resp.Unavail("canceled", "job canceled")
+	}
+
+	// *big.Int se serializa como número JSON (sin comillas)
+	type outT struct {
+		N        int64    `json:"n"`
+		Steps    int64    `json:"steps"`
+		MaxValue *big.Int `json:"max_value"`
+		Elapsed  int64    `json:"elapsed_ms"`
+	}
+	b, _ := json.Marshal(outT{
+		N:        n,
+		Steps:    steps,
+		MaxValue: peak,
+		Elapsed:  time.Since(start).Milliseconds(),
+	})
+	return resp.JSONOK(string(b))
+}
+
+// collatzCtx recorre la secuencia desde n y devuelve (pasos, pico, ok).
+// ok=false si ctx se cancela antes de llegar a 1.
+func collatzCtx(ctx context.Context, n int64) (int64, *big.Int, bool) {
+	x := uint64(n)
+	peak := x
+	var steps int64
+
+	// Tramo rápido en uint64
+	for x != 1 {
+		if steps&4095 == 0 {
+			select {
+			case <-ctx.Done():
+				return 0, nil, false
+			default:
+			}
+		}
+		if x&1 == 0 {
+			x >>= 1
+		} else {
+			if x > (math.MaxUint64-1)/3 {
+				break // 3x+1 desbordaría: seguimos con big.Int
+			}
+			x = 3*x + 1
+		}
+		steps++
+		if x > peak {
+			peak = x
+		}
+	}
+	if x == 1 {
+		return steps, new(big.Int).SetUint64(peak), true
+	}
+
+	// Tramo lento en big.Int
+	bx := new(big.Int).SetUint64(x)
+	bpeak := new(big.Int).SetUint64(peak)
+	one := big.NewInt(1)
+	three := big.NewInt(3)
+	for bx.Cmp(one) != 0 {
+		if steps&4095 == 0 {
+			select {
+			case <-ctx.Done():
+				return 0, nil, false
+			default:
+			}
+		}
+		if bx.Bit(0) == 0 {
+			bx.Rsh(bx, 1)
+		} else {
+			bx.Mul(bx, three)
+			bx.Add(bx, one)
+		}
+		steps++
+		if bx.Cmp(bpeak) > 0 {
+			bpeak.Set(bx)
+		}
+	}
+	return steps, bpeak, true
+}
+
+
+// ============================================================================
+// /sieve — primos hasta limit con la Criba de Eratóstenes.
+// - Parám. requeridos: limit (2..10_000_000; el tope acota la memoria)
+// - "primes" se recorta a los primeros sieveMaxList (truncated=true);
+//   "count" siempre es el total real.
+// - Cancelación: chequeos en el tachado y en el recorrido final.
+// - JSON: { "limit","count","primes":[...],"truncated","elapsed_ms" }
+// ============================================================================
+const (
+	SieveMaxLimit = 10_000_000
+	sieveMaxList  = 1000
+)
+
+func SieveJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	limit, err := strconv.Atoi(params["limit"])
+	if err != nil || limit < 2 || limit > SieveMaxLimit {
+		return resp.BadReq("limit", "limit must be integer in [2, 10000000]")
+	}
+	start := time.Now()
+
+	// composite[i] == true ⇒ i no es primo
+	composite := make([]bool, limit+1)
+	for i := 2; i*i <= limit; i++ {
+		if composite[i] {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return resp.Unavail("canceled", "job canceled")
+		default:
+		}
+		for j := i * i; j <= limit; j += i {
+			composite[j] = true
+		}
+	}
+
+	count := 0
+	primes := make([]int, 0, sieveMaxList)
+	for i := 2; i <= limit; i++ {
+		if i&0xFFFF == 0 {
+			select {
+			case <-ctx.Done():
+				return resp.Unavail("canceled", "job canceled")
+			default:
+			}
+		}
+		if composite[i] {
+			continue
+		}
+		count++
+		if len(primes) < sieveMaxList {
+			primes = append(primes, i)
+		}
+	}
+
+	type outT struct {
+		Limit     int   `json:"limit"`
+		Count     int   `json:"count"`
+		Primes    []int `json:"primes"`
+		Truncated bool  `json:"truncated"`
+		Elapsed   int64 `json:"elapsed_ms"`
+	}
+	b, _ := json.Marshal(outT{
+		Limit:     limit,
+		Count:     count,
+		Primes:    primes,
+		Truncated: count > len(primes),
+		Elapsed:   time.Since(start).Milliseconds(),
+	})
+	return resp.JSONOK(string(b))
+}
+
+
+// ============================================================================
+// /primesieve — primos en [from, to] con criba segmentada.
+// - Parám. requeridos: from>=0, to>=from (to <= primeSieveMaxTo) y un rango
+//   de a lo sumo primeSieveMaxRange números (acota tiempo y tamaño del JSON).
+// - Primero se criban los primos base hasta √to; luego el rango se recorre
+//   en segmentos de primeSieveSegment tachando múltiplos de cada base.
+// - Cancelación: al cribar los primos base y antes de cada segmento.
+// - JSON: { "from","to","primes":[...],"count","elapsed_ms" }
+// ============================================================================
+const (
+	primeSieveMaxTo    = 1_000_000_000_000
+	primeSieveMaxRange = 1_000_000
+	primeSieveSegment  = 1 << 16
+)
+
+func PrimeSieveJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	from, err := strconv.ParseInt(params["from"], 10, 64)
+	if err != nil || from < 0 {
+		return resp.BadReq("from", "from must be integer >= 0")
+	}
+	to, err := strconv.ParseInt(params["to"], 10, 64)
+	if err != nil || to < from || to > primeSieveMaxTo {
+		return resp.BadReq("to", "to must be integer in [from, 1000000000000]")
+	}
+	if to-from+1 > primeSieveMaxRange {
+		return resp.BadReq("range", "to-from+1 must be <= 1000000")
+	}
+	start := time.Now()
+
+	// primos base hasta √to (a lo sumo 10^6)
+	root := int64(math.Sqrt(float64(to)))
+	for root*root > to {
+		root--
+	}
+	for (root+1)*(root+1) <= to {
+		root++
+	}
+	composite := make([]bool, root+1)
+	var base []int64
+	for i := int64(2); i <= root; i++ {
+		if composite[i] {
+			continue
+		}
+		if i&1023 == 0 && canceled(ctx) {
+			return resp.Unavail("canceled", "job canceled")
+		}
+		base = append(base, i)
+		for j := i * i; j <= root; j += i {
+			composite[j] = true
+		}
+	}
+
+	primes := make([]int64, 0)
+	seg := make([]bool, primeSieveSegment)
+	for lo := from; lo <= to; lo += primeSieveSegment {
+		if canceled(ctx) {
+			return resp.Unavail("canceled", "job canceled")
+		}
+		hi := lo + primeSieveSegment - 1
+		if hi > to {
+			hi = to
+		}
+		mark := seg[:hi-lo+1]
+		for i := range mark {
+			mark[i] = false
+		}
+		for _, p := range base {
+			if p*p > hi {
+				break
+			}
+			// primer múltiplo de p en [lo, hi], sin tachar p mismo
+			m := (lo + p - 1) / p * p
+			if m < p*p {
+				m = p * p
+			}
+			for ; m <= hi; m += p {
+				mark[m-lo] = true
+			}
+		}
+		for i, c := range mark {
+			if n := lo + int64(i); !c && n >= 2 {
+				primes = append(primes, n)
+			}
+		}
+	}
+
+	type outT struct {
+		From    int64   `json:"from"`
+		To      int64   `json:"to"`
+		Primes  []int64 `json:"primes"`
+		Count   int     `json:"count"`
+		Elapsed int64   `json:"elapsed_ms"`
+	}
+	b, _ := json.Marshal(outT{
+		From:    from,
+		To:      to,
+		Primes:  primes,
+		Count:   len(primes),
+		Elapsed: time.Since(start).Milliseconds(),
+	})
+	return resp.JSONOK(string(b))
+}
+
+
+// ============================================================================
+// /determinant — determinante de una matriz NxN pseudoaleatoria.
+// - Parám. requeridos: size>0 (cap a detMaxSize), seed (int64)
+// - Generación (igual que /matrixmul): rand.NewSource(seed) y, en orden
+//   fila-mayor, cada elemento = rng.Intn(7) - 3.
+// - Descomposición LU con pivoteo parcial en float64.
+// - Cancelación: chequeos en la generación y por columna de eliminación.
+// - JSON: { "size","seed","determinant","elapsed_ms" }
+// ============================================================================
+
+// detMaxSize acota el O(n³) y evita que |det| desborde float64
+// (con elementos en -3..3, |det| ronda sqrt(n!)·2^n).
+const detMaxSize = 200
+
+func DeterminantJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	n, err1 := strconv.Atoi(params["size"])
+	seed, err2 := strconv.ParseInt(params["seed"], 10, 64)
+	if err1 != nil || n <= 0 || err2 != nil {
+		return resp.BadReq("params", "size>0 and valid seed required")
+	}
+	if n > detMaxSize {
+		n = detMaxSize
+	}
+	start := time.Now()
+
+	rng := rand.New(rand.NewSource(seed))
+	a := make([]float64, n*n)
+	for i := range a {
+		if i&1023 == 0 && canceled(ctx) {
+			return resp.Unavail("canceled", "job canceled")
+		}
+		a[i] = float64(rng.Intn(7) - 3)
+	}
+
+	det, ok := determinantLUCtx(ctx, a, n)
+	if !ok {
+		return resp.Unavail("canceled", "job canceled")
+	}
+	if math.IsInf(det, 0) || math.IsNaN(det) {
+		return resp.IntErr("overflow", "determinant out of float64 range")
+	}
+
+	type outT struct {
+		Size        int     `json:"size"`
+		Seed        int64   `json:"seed"`
+		Determinant float64 `json:"determinant"`
+		Elapsed     int64   `json:"elapsed_ms"`
+	}
+	b, _ := json.Marshal(outT{
+		Size:        n,
+		Seed:        seed,
+		Determinant: det,
+		Elapsed:     time.Since(start).Milliseconds(),
+	})
+	return resp.JSONOK(string(b))
+}
+
+// determinantLUCtx calcula det(a) (a es NxN fila-mayor, se modifica in situ)
+// por eliminación gaussiana con pivoteo parcial: det = ±Π U[i][i], con el
+// signo cambiado en cada intercambio de filas. ok=false si ctx se cancela.
+func determinantLUCtx(ctx context.Context, a []float64, n int) (float64, bool) {
+	det := 1.0
+	for k := 0; k < n; k++ {
+		if canceled(ctx) {
+			return 0, false
+		}
+		// pivote: mayor |a[i][k]| en la columna k
+		p := k
+		for i := k + 1; i < n; i++ {
+			if math.Abs(a[i*n+k]) > math.Abs(a[p*n+k]) {
+				p = i
+			}
+		}
+		if a[p*n+k] == 0 {
+			return 0, true // columna nula ⇒ matriz singular
+		}
+		if p != k {
+			for j := 0; j < n; j++ {
+				a[k*n+j], a[p*n+j] = a[p*n+j], a[k*n+j]
+			}
+			det = -det
+		}
+		piv := a[k*n+k]
+		det *= piv
+		for i := k + 1; i < n; i++ {
+			f := a[i*n+k] / piv
+			if f == 0 {
+				continue
+			}
+			for j := k + 1; j < n; j++ {
+				a[i*n+j] -= f * a[k*n+j]
+			}
+		}
+	}
+	return det, true
+}
+
+
+// ============================================================================
+// /ackermann — A(m,n) para pruebas de carga con recursión profunda.
+// - Parám. requeridos: m (0..4), n (0..12); con m=4 sólo n<=1, porque
+//   A(4,2) = 2^65536-3 no cabe en int64.
+// - Iterativo con pila explícita (no consume la pila de la goroutine);
+//   "calls" cuenta cada evaluación A(m,n) desapilada.
+// - Cancelación: chequeo de ctx.Done() cada 65536 evaluaciones.
+// - JSON: { "m","n","value","calls","elapsed_ms" }
+// ============================================================================
+func AckermannJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	m, errM := strconv.Atoi(params["m"])
+	n, errN := strconv.Atoi(params["n"])
+	if errM != nil || errN != nil || m < 0 || n < 0 || m > 4 || n > 12 {
+		return resp.BadReq("params", "m must be integer in [0, 4] and n in [0, 12]")
+	}
+	if m == 4 && n > 1 {
+		return resp.BadReq("params", "A(4,n) for n>=2 does not fit in int64")
+	}
+	start := time.Now()
+
+	value, calls, ok := ackermannCtx(ctx, int64(m), int64(n))
+	if !ok {
+		return resp.Unavail("canceled", "job canceled")
+	}
+
+	type outT struct {
+		M       int   `json:"m"`
+		N       int   `json:"n"`
+		Value   int64 `json:"value"`
+		Calls   int64 `json:"calls"`
+		Elapsed int64 `json:"elapsed_ms"`
+	}
+	b, _ := json.Marshal(outT{
+		M: m, N: n, Value: value, Calls: calls,
+		Elapsed: time.Since(start).Milliseconds(),
+	})
+	return resp.JSONOK(string(b))
+}
+
+// ackermannCtx evalúa A(m,n) con una pila de "m" pendientes:
+//   A(0,n)   = n+1
+//   A(m,0)   = A(m-1, 1)
+//   A(m,n)   = A(m-1, A(m, n-1))
+// Devuelve (valor, evaluaciones, ok); ok=false si ctx se cancela.
+func ackermannCtx(ctx context.Context, m, n int64) (int64, int64, bool) {
+	stack := []int64{m}
+	var calls int64
+	for len(stack) > 0 {
+		if calls&0xFFFF == 0 && canceled(ctx) {
+			return 0, calls, false
+		}
+		calls++
+		m = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch {
+		case m == 0:
+			n++
+		case n == 0:
+			stack = append(stack, m-1)
+			n = 1
+		default:
+			stack = append(stack, m-1, m)
+			n--
+		}
+	}
+	return n, calls, true
+}
@@ -1,8 +1,16 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"image/color"
+	"image/png"
+	"math"
+	"math/big"
+	"math/rand"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -219,6 +227,33 @@ func TestPiJSONCtx_Spigot_And_Chudnovsky(t *testing.T) {
 	}
 }
 
+func TestPiJSONCtx_HugeSpigotIsCapped(t *testing.T) {
+	t.Parallel()
+	// el plazo corta el spigot (10000 dígitos tardan segundos): alcanza para
+	// ver que se acepta con el tope en vez de reservar para 1e9 dígitos
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	r := PiJSONCtx(ctx, map[string]string{"digits": "1000000000", "method": "spigot"})
+	if r.Status != 200 {
+		t.Fatalf("huge spigot: %+v", r)
+	}
+	o := mustJSON[struct {
+		Digits    int    `json:"digits"`
+		Truncated bool   `json:"truncated"`
+		Pi        string `json:"pi"`
+	}](t, r.Body)
+	if o.Digits != 10000 || len(o.Pi) > 2+10000 || !strings.HasPrefix(o.Pi, "3.") {
+		t.Fatalf("digits=%d len(pi)=%d", o.Digits, len(o.Pi))
+	}
+	if !o.Truncated && len(o.Pi) != 2+10000 {
+		t.Fatalf("sin truncar debe traer los 10000 dígitos: %d", len(o.Pi))
+	}
+	if el := time.Since(start); el > 2*time.Second {
+		t.Fatalf("el cap debe acotar el trabajo: %v", el)
+	}
+}
+
 func TestPiJSONCtx_Validation(t *testing.T) {
 	t.Parallel()
 	if r := PiJSONCtx(ctxBg(), map[string]string{}); r.Status != 400 {
@@ -232,6 +267,142 @@ func TestPiJSONCtx_Validation(t *testing.T) {
 	}
 }
 
+func TestPiLRU_EvictionAndCounters(t *testing.T) {
+	c := newPiLRU(2)
+	if _, ok := c.get("a"); ok {
+		t.Fatalf("empty cache must miss")
+	}
+	c.put("a", piEntry{pi: "3.1"})
+	c.put("b", piEntry{pi: "3.14"})
+	if e, ok := c.get("a"); !ok || e.pi != "3.1" { // a pasa a ser el más reciente
+		t.Fatalf("hit a: %+v %v", e, ok)
+	}
+	c.put("c", piEntry{pi: "3.141"}) // desaloja b (el menos reciente)
+	c.put("d", piEntry{pi: "3.1415"}) // desaloja a
+	if _, ok := c.get("b"); ok {
+		t.Fatalf("b must have been evicted")
+	}
+	if _, ok := c.get("d"); !ok {
+		t.Fatalf("d must be cached")
+	}
+	st := c.stats()
+	if st["size"] != 2 || st["capacity"] != 2 || st["hits"] != uint64(2) || st["misses"] != uint64(2) || st["evictions"] != uint64(2) {
+		t.Fatalf("stats: %v", st)
+	}
+}
+
+func TestPiJSONCtx_UsesCache(t *testing.T) {
+	old := piCache
+	piCache = newPiLRU(1)
+	defer func() { piCache = old }()
+
+	type out struct {
+		Pi     string `json:"pi"`
+		Cached bool   `json:"cached"`
+	}
+	first := mustJSON[out](t, PiJSONCtx(ctxBg(), map[string]string{"digits": "30", "method": "spigot"}).Body)
+	again := mustJSON[out](t, PiJSONCtx(ctxBg(), map[string]string{"digits": "30", "method": "spigot"}).Body)
+	if first.Cached || !again.Cached || again.Pi != first.Pi {
+		t.Fatalf("first=%+v again=%+v", first, again)
+	}
+	// otra clave con capacidad 1 desaloja la anterior
+	_ = PiJSONCtx(ctxBg(), map[string]string{"digits": "31", "method": "spigot"})
+	st := PiCacheStats()
+	if st["hits"] != uint64(1) || st["misses"] != uint64(2) || st["evictions"] != uint64(1) {
+		t.Fatalf("stats: %v", st)
+	}
+
+	// un resultado truncado por cancelación no se guarda
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = PiJSONCtx(ctx, map[string]string{"digits": "500", "method": "spigot"})
+	if again := mustJSON[out](t, PiJSONCtx(ctxBg(), map[string]string{"digits": "500", "method": "spigot"}).Body); again.Cached {
+		t.Fatalf("truncated result must not be cached")
+	}
+}
+
+func TestPiJSONCtx_BBP_KnownHexDigits(t *testing.T) {
+	// π = 3.243F6A8885A308D3... (hex)
+	type out struct {
+		Method   string `json:"method"`
+		Index    int    `json:"index"`
+		HexDigit string `json:"hex_digit"`
+	}
+	for idx, want := range map[int]string{0: "2", 1: "4", 2: "3", 3: "F", 9: "5", 15: "3"} {
+		r := PiJSONCtx(ctxBg(), map[string]string{"method": "bbp", "index": strconv.Itoa(idx)})
+		if r.Status != 200 || !r.JSON {
+			t.Fatalf("index=%d: %+v", idx, r)
+		}
+		o := mustJSON[out](t, r.Body)
+		if o.Method != "bbp" || o.Index != idx || o.HexDigit != want {
+			t.Fatalf("index=%d got %+v want %s", idx, o, want)
+		}
+	}
+
+	// index sin method también usa bbp
+	o := mustJSON[out](t, PiJSONCtx(ctxBg(), map[string]string{"index": "4"}).Body)
+	if o.Method != "bbp" || o.HexDigit != "6" {
+		t.Fatalf("index sin method: %+v", o)
+	}
+
+	for _, bad := range []string{"", "-1", "x", "1000000"} {
+		if r := PiJSONCtx(ctxBg(), map[string]string{"method": "bbp", "index": bad}); r.Status != 400 {
+			t.Fatalf("index=%q -> 400: %+v", bad, r)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r := PiJSONCtx(ctx, map[string]string{"method": "bbp", "index": "500000"}); r.Status != 503 {
+		t.Fatalf("canceled -> 503: %+v", r)
+	}
+}
+
+/* ---------- kernels de π: dígitos conocidos ---------- */
+
+const piRef100 = "3.1415926535897932384626433832795028841971693993751058209749445923078164062862089986280348253421170679"
+
+func TestPiKernels_KnownDigits(t *testing.T) {
+	t.Parallel()
+	for d := 1; d <= 100; d++ {
+		if s, _, _ := piChudnovskyCtx(context.Background(), d); s != piRef100[:2+d] {
+			t.Fatalf("chudnovsky d=%d: %s", d, s)
+		}
+		if s, _, _ := piSpigotCtx(context.Background(), d); s != piRef100[:2+d] {
+			t.Fatalf("spigot d=%d: %s", d, s)
+		}
+	}
+}
+
+func TestPiSpigot_GuardDigitsFixLastDigit(t *testing.T) {
+	t.Parallel()
+	// sin decimales de guarda el arreglo justo daba 3.14158
+	if s, _, _ := piSpigotCtx(context.Background(), 5); s != "3.14159" {
+		t.Fatalf("spigot n=5: %s", s)
+	}
+}
+
+func TestPiChudnovsky_TruncatesInsteadOfRounding(t *testing.T) {
+	t.Parallel()
+	// 3.14159… redondeado a 4 decimales sería 3.1416
+	if s, _, _ := piChudnovskyCtx(context.Background(), 4); s != "3.1415" {
+		t.Fatalf("chudnovsky d=4: %s", s)
+	}
+}
+
+func TestPiChudnovsky_TermRatioConverges(t *testing.T) {
+	t.Parallel()
+	// con la razón correcta cada término aporta ~14,18 dígitos: 1000 dígitos
+	// piden ~71 términos y coinciden con el spigot
+	s, iters, _ := piChudnovskyCtx(context.Background(), 1000)
+	if iters < 70 || iters > 75 {
+		t.Fatalf("chudnovsky d=1000: %d iteraciones, want ~72", iters)
+	}
+	if sp, _, _ := piSpigotCtx(context.Background(), 1000); s != sp {
+		t.Fatalf("chudnovsky y spigot difieren a 1000 dígitos")
+	}
+}
+
 // NUEVO: piSpigotCtx casos n<=0 y cancelación
 func TestPiSpigotCtx_NonPositive_And_Cancel(t *testing.T) {
 	t.Parallel()
@@ -277,6 +448,39 @@ func TestMandelbrotJSONCtx_SmallImage(t *testing.T) {
 	}
 }
 
+func TestMandelbrotJSONCtx_PNG(t *testing.T) {
+	t.Parallel()
+	r := MandelbrotJSONCtx(ctxBg(), map[string]string{
+		"width": "16", "height": "9", "max_iter": "40", "format": "png",
+	})
+	if r.Status != 200 || r.JSON || r.ContentType != "image/png" || len(r.Bytes) == 0 {
+		t.Fatalf("png result: status=%d json=%v ct=%q len=%d", r.Status, r.JSON, r.ContentType, len(r.Bytes))
+	}
+	img, err := png.Decode(bytes.NewReader(r.Bytes))
+	if err != nil {
+		t.Fatalf("png.Decode: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 16 || b.Dy() != 9 {
+		t.Fatalf("png bounds = %v", b)
+	}
+	// el origen (dentro del conjunto) queda negro; la esquina (-2.5,-1) escapa
+	// enseguida y queda clara. Columna x=11 ≈ re 0.07, fila y=4 = im 0.
+	if g := color.GrayModel.Convert(img.At(11, 4)).(color.Gray); g.Y != 0 {
+		t.Fatalf("inside point should be black: %v", g)
+	}
+	if g := color.GrayModel.Convert(img.At(0, 0)).(color.Gray); g.Y < 200 {
+		t.Fatalf("escaping corner should be light: %v", g)
+	}
+
+	// json sigue siendo el default y format inválido → 400
+	if r := MandelbrotJSONCtx(ctxBg(), map[string]string{"width": "4", "height": "4", "max_iter": "5"}); !r.JSON || r.Bytes != nil {
+		t.Fatalf("default must stay json: %+v", r)
+	}
+	if r := MandelbrotJSONCtx(ctxBg(), map[string]string{"width": "4", "height": "4", "max_iter": "5", "format": "gif"}); r.Status != 400 {
+		t.Fatalf("bad format -> 400: %+v", r)
+	}
+}
+
 func TestMandelbrotJSONCtx_Validation_And_Cancel(t *testing.T) {
 	t.Parallel()
 	if r := MandelbrotJSONCtx(ctxBg(), map[string]string{}); r.Status != 400 {
@@ -297,6 +501,186 @@ func TestMandelbrotJSONCtx_Validation_And_Cancel(t *testing.T) {
 	}
 }
 
+func TestJuliaJSONCtx_SmallImage(t *testing.T) {
+	t.Parallel()
+	type out struct {
+		Width   int     `json:"width"`
+		Height  int     `json:"height"`
+		MaxIter int     `json:"max_iter"`
+		Cre     float64 `json:"cre"`
+		Cim     float64 `json:"cim"`
+		Map     [][]int `json:"map"`
+	}
+	// c = 0: el Julia es el disco unidad; el centro nunca escapa y las esquinas
+	// (|z| = 1.5·√2 > 2) escapan sin iterar.
+	r := JuliaJSONCtx(ctxBg(), map[string]string{
+		"cre": "0", "cim": "0", "width": "5", "height": "5", "max_iter": "30",
+	})
+	if r.Status != 200 || !r.JSON {
+		t.Fatalf("status/json: %+v", r)
+	}
+	o := mustJSON[out](t, r.Body)
+	if o.Width != 5 || o.Height != 5 || len(o.Map) != 5 || len(o.Map[0]) != 5 {
+		t.Fatalf("dimensions mismatch: %+v", o)
+	}
+	if o.Map[2][2] != 30 || o.Map[0][0] != 0 {
+		t.Fatalf("center=%d corner=%d", o.Map[2][2], o.Map[0][0])
+	}
+
+	o = mustJSON[out](t, JuliaJSONCtx(ctxBg(), map[string]string{
+		"cre": "-0.8", "cim": "0.156", "width": "8", "height": "6", "max_iter": "20",
+	}).Body)
+	if o.Cre != -0.8 || o.Cim != 0.156 || len(o.Map) != 6 || len(o.Map[0]) != 8 {
+		t.Fatalf("echo/dims mismatch: %+v", o)
+	}
+}
+
+func TestJuliaJSONCtx_Validation_And_Cancel(t *testing.T) {
+	t.Parallel()
+	base := func(k, v string) map[string]string {
+		p := map[string]string{"cre": "0.1", "cim": "0.2", "width": "4", "height": "4", "max_iter": "5"}
+		p[k] = v
+		return p
+	}
+	for _, p := range []map[string]string{base("cre", ""), base("cim", "x"), base("cre", "NaN"), base("width", "0"), base("max_iter", "-1")} {
+		if r := JuliaJSONCtx(ctxBg(), p); r.Status != 400 {
+			t.Fatalf("%v -> 400: %+v", p, r)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := JuliaJSONCtx(ctx, map[string]string{
+		"cre": "-0.8", "cim": "0.156", "width": "64", "height": "64", "max_iter": "50",
+	})
+	if r.Status != 503 || r.Err == nil {
+		t.Fatalf("expected 503 on cancel: %+v", r)
+	}
+}
+
+/********** DeterminantJSONCtx **********/
+
+func TestDeterminantLUCtx_KnownMatrices(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name string
+		n    int
+		a    []float64
+		want float64
+	}{
+		{"identity", 3, []float64{1, 0, 0, 0, 1, 0, 0, 0, 1}, 1},
+		{"2x2", 2, []float64{3, 8, 4, 6}, -14},
+		// a[0][0]=0 obliga a intercambiar filas (cambia el signo)
+		{"swap", 3, []float64{0, 2, 1, 1, 1, 1, 2, 1, 3}, -3},
+		{"singular", 3, []float64{1, 2, 3, 2, 4, 6, 1, 0, 1}, 0},
+	}
+	for _, c := range cases {
+		got, ok := determinantLUCtx(ctxBg(), append([]float64(nil), c.a...), c.n)
+		if !ok || math.Abs(got-c.want) > 1e-9 {
+			t.Fatalf("%s: det=%v ok=%v want %v", c.name, got, ok, c.want)
+		}
+	}
+}
+
+func TestDeterminantJSONCtx_ReproducibleFromSeed(t *testing.T) {
+	t.Parallel()
+	type out struct {
+		Size        int     `json:"size"`
+		Seed        int64   `json:"seed"`
+		Determinant float64 `json:"determinant"`
+	}
+	// Generación documentada: rand.NewSource(seed), fila-mayor, Intn(7)-3.
+	rng := rand.New(rand.NewSource(42))
+	var m [4]float64
+	for i := range m {
+		m[i] = float64(rng.Intn(7) - 3)
+	}
+	want := m[0]*m[3] - m[1]*m[2]
+
+	o := mustJSON[out](t, DeterminantJSONCtx(ctxBg(), map[string]string{"size": "2", "seed": "42"}).Body)
+	if o.Size != 2 || o.Seed != 42 || math.Abs(o.Determinant-want) > 1e-9 {
+		t.Fatalf("got %+v want det=%v", o, want)
+	}
+
+	// misma semilla → mismo resultado; size se limita a detMaxSize
+	r1 := DeterminantJSONCtx(ctxBg(), map[string]string{"size": "30", "seed": "7"})
+	r2 := DeterminantJSONCtx(ctxBg(), map[string]string{"size": "30", "seed": "7"})
+	if r1.Status != 200 || mustJSON[out](t, r1.Body).Determinant != mustJSON[out](t, r2.Body).Determinant {
+		t.Fatalf("not deterministic: %s vs %s", r1.Body, r2.Body)
+	}
+	if o := mustJSON[out](t, DeterminantJSONCtx(ctxBg(), map[string]string{"size": "100000", "seed": "1"}).Body); o.Size != detMaxSize {
+		t.Fatalf("size cap: %+v", o)
+	}
+}
+
+func TestDeterminantJSONCtx_Validation_And_Cancel(t *testing.T) {
+	t.Parallel()
+	for _, p := range []map[string]string{{}, {"size": "0", "seed": "1"}, {"size": "-2", "seed": "1"}, {"size": "2", "seed": "x"}} {
+		if r := DeterminantJSONCtx(ctxBg(), p); r.Status != 400 {
+			t.Fatalf("%v -> 400: %+v", p, r)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r := DeterminantJSONCtx(ctx, map[string]string{"size": "64", "seed": "7"}); r.Status != 503 || r.Err == nil {
+		t.Fatalf("expected 503 on cancel: %+v", r)
+	}
+}
+
+/********** AckermannJSONCtx **********/
+
+func TestAckermannJSONCtx_KnownValues(t *testing.T) {
+	t.Parallel()
+	type out struct {
+		M     int   `json:"m"`
+		N     int   `json:"n"`
+		Value int64 `json:"value"`
+		Calls int64 `json:"calls"`
+	}
+	cases := []struct {
+		m, n  string
+		value int64
+	}{
+		{"0", "0", 1},
+		{"1", "5", 7},
+		{"2", "3", 9},
+		{"3", "3", 61},
+		{"3", "6", 509},
+		{"4", "0", 13},
+	}
+	for _, c := range cases {
+		r := AckermannJSONCtx(ctxBg(), map[string]string{"m": c.m, "n": c.n})
+		if r.Status != 200 || !r.JSON {
+			t.Fatalf("A(%s,%s): %+v", c.m, c.n, r)
+		}
+		o := mustJSON[out](t, r.Body)
+		if o.Value != c.value || o.Calls <= 0 {
+			t.Fatalf("A(%s,%s) got %+v want %d", c.m, c.n, o, c.value)
+		}
+	}
+	// A(1,1) = A(0, A(1,0)) = A(0, A(0,1)) → 4 evaluaciones
+	if o := mustJSON[out](t, AckermannJSONCtx(ctxBg(), map[string]string{"m": "1", "n": "1"}).Body); o.Value != 3 || o.Calls != 4 {
+		t.Fatalf("A(1,1) calls: %+v", o)
+	}
+}
+
+func TestAckermannJSONCtx_Validation_And_Cancel(t *testing.T) {
+	t.Parallel()
+	bad := []map[string]string{
+		{"m": "5", "n": "0"}, {"m": "0", "n": "13"}, {"m": "-1", "n": "0"},
+		{"m": "x", "n": "1"}, {"m": "1"}, {"m": "4", "n": "2"},
+	}
+	for _, p := range bad {
+		if r := AckermannJSONCtx(ctxBg(), p); r.Status != 400 {
+			t.Fatalf("%v -> 400: %+v", p, r)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r := AckermannJSONCtx(ctx, map[string]string{"m": "3", "n": "10"}); r.Status != 503 || r.Err == nil {
+		t.Fatalf("expected 503 on cancel: %+v", r)
+	}
+}
+
 /********** MatrixMulHashCtx **********/
 
 func TestMatrixMulHashCtx_Deterministic(t *testing.T) {
@@ -546,3 +930,246 @@ func TestPiSpigotCtx_FinalPredigitPush_ShortN(t *testing.T) {
 		t.Fatalf("esperamos iteraciones > 0")
 	}
 }
+
+func TestFactorJSONCtx_BigBeyondInt64(t *testing.T) {
+	type out struct {
+		N       string      `json:"n"`
+		Big     bool        `json:"big"`
+		Factors [][2]string `json:"factors"`
+	}
+	cases := []struct {
+		n    string
+		want [][2]string
+	}{
+		// 2^64+1 (auto-detecta overflow de int64)
+		{"18446744073709551617", [][2]string{{"274177", "1"}, {"67280421310721", "1"}}},
+		// 2^67-1 (Cole)
+		{"147573952589676412927", [][2]string{{"193707721", "1"}, {"761838257287", "1"}}},
+		// potencias repetidas: 2^70 * 3^2
+		{"10625324586456701730816", [][2]string{{"2", "70"}, {"3", "2"}}},
+	}
+	for _, tc := range cases {
+		r := FactorJSONCtx(ctxBg(), map[string]string{"n": tc.n})
+		if r.Status != 200 {
+			t.Fatalf("n=%s: %+v", tc.n, r)
+		}
+		o := mustJSON[out](t, r.Body)
+		if !o.Big || o.N != tc.n || len(o.Factors) != len(tc.want) {
+			t.Fatalf("n=%s: %+v", tc.n, o)
+		}
+		for i := range tc.want {
+			if o.Factors[i] != tc.want[i] {
+				t.Fatalf("n=%s factors=%v want %v", tc.n, o.Factors, tc.want)
+			}
+		}
+	}
+
+	// big=true fuerza el modo big también para números chicos
+	o := mustJSON[out](t, FactorJSONCtx(ctxBg(), map[string]string{"n": "360", "big": "true"}).Body)
+	if !o.Big || len(o.Factors) != 3 || o.Factors[0] != [2]string{"2", "3"} {
+		t.Fatalf("big=true 360: %+v", o)
+	}
+	// sin big, los chicos siguen por la ruta int64
+	if r := FactorJSONCtx(ctxBg(), map[string]string{"n": "360"}); strings.Contains(r.Body, `"big"`) {
+		t.Fatalf("small n must stay on int64 path: %s", r.Body)
+	}
+	if r := FactorJSONCtx(ctxBg(), map[string]string{"n": "-99999999999999999999"}); r.Status != 400 {
+		t.Fatalf("negative overflow -> 400: %+v", r)
+	}
+	if r := FactorJSONCtx(ctxBg(), map[string]string{"n": "abc", "big": "true"}); r.Status != 400 {
+		t.Fatalf("bad big n -> 400: %+v", r)
+	}
+}
+
+func TestPiDigitJSONCtx_KnownHexDigits(t *testing.T) {
+	// π = 3.243F6A8885A308D3... (hex)
+	const want = "243F6A8885A308D3"
+	type out struct {
+		Pos      int    `json:"pos"`
+		HexDigit string `json:"hex_digit"`
+	}
+	for i := 0; i < len(want); i++ {
+		r := PiDigitJSONCtx(ctxBg(), map[string]string{"pos": strconv.Itoa(i + 1)})
+		if r.Status != 200 {
+			t.Fatalf("pos=%d: %+v", i+1, r)
+		}
+		o := mustJSON[out](t, r.Body)
+		if o.Pos != i+1 || o.HexDigit != want[i:i+1] {
+			t.Fatalf("pos=%d got %+v want %c", i+1, o, want[i])
+		}
+	}
+
+	for _, bad := range []string{"", "0", "-3", "x", "1000001"} {
+		if r := PiDigitJSONCtx(ctxBg(), map[string]string{"pos": bad}); r.Status != 400 {
+			t.Fatalf("pos=%q -> 400: %+v", bad, r)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r := PiDigitJSONCtx(ctx, map[string]string{"pos": "500000"}); r.Status != 503 {
+		t.Fatalf("canceled -> 503: %+v", r)
+	}
+}
+
+func TestCollatzJSONCtx_KnownSequences(t *testing.T) {
+	type out struct {
+		N        int64       `json:"n"`
+		Steps    int64       `json:"steps"`
+		MaxValue json.Number `json:"max_value"`
+	}
+	cases := []struct {
+		n     string
+		steps int64
+		max   string
+	}{
+		{"1", 0, "1"},
+		{"6", 8, "16"},
+		{"27", 111, "9232"},
+	}
+	for _, c := range cases {
+		r := CollatzJSONCtx(ctxBg(), map[string]string{"n": c.n})
+		if r.Status != 200 || !r.JSON {
+			t.Fatalf("n=%s: %+v", c.n, r)
+		}
+		o := mustJSON[out](t, r.Body)
+		if o.Steps != c.steps || o.MaxValue.String() != c.max {
+			t.Fatalf("n=%s got %+v want steps=%d max=%s", c.n, o, c.steps, c.max)
+		}
+	}
+
+	// MaxInt64 es impar: 3n+1 no cabe en uint64 y obliga al tramo big.Int
+	r := CollatzJSONCtx(ctxBg(), map[string]string{"n": "9223372036854775807"})
+	if r.Status != 200 {
+		t.Fatalf("maxint64: %+v", r)
+	}
+	o := mustJSON[out](t, r.Body)
+	peak, ok := new(big.Int).SetString(o.MaxValue.String(), 10)
+	if !ok || peak.Cmp(new(big.Int).SetUint64(math.MaxUint64)) <= 0 || o.Steps <= 0 {
+		t.Fatalf("expected peak beyond 2^64: %+v", o)
+	}
+}
+
+func TestCollatzJSONCtx_Validation_And_Cancel(t *testing.T) {
+	for _, bad := range []string{"", "0", "-5", "x", "1.5", "9223372036854775808"} {
+		if r := CollatzJSONCtx(ctxBg(), map[string]string{"n": bad}); r.Status != 400 {
+			t.Fatalf("n=%q -> 400: %+v", bad, r)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r := CollatzJSONCtx(ctx, map[string]string{"n": "27"}); r.Status != 503 {
+		t.Fatalf("canceled -> 503: %+v", r)
+	}
+}
+
+func TestSieveJSONCtx_CountsAndTruncation(t *testing.T) {
+	type out struct {
+		Limit     int   `json:"limit"`
+		Count     int   `json:"count"`
+		Primes    []int `json:"primes"`
+		Truncated bool  `json:"truncated"`
+	}
+
+	r := SieveJSONCtx(ctxBg(), map[string]string{"limit": "100"})
+	if r.Status != 200 || !r.JSON {
+		t.Fatalf("limit=100: %+v", r)
+	}
+	o := mustJSON[out](t, r.Body)
+	if o.Count != 25 || len(o.Primes) != 25 || o.Truncated {
+		t.Fatalf("limit=100 got %+v", o)
+	}
+	if o.Primes[0] != 2 || o.Primes[24] != 97 {
+		t.Fatalf("primes extremos: %v", o.Primes)
+	}
+
+	// π(10^5) = 9592 → la lista se recorta a sieveMaxList
+	o = mustJSON[out](t, SieveJSONCtx(ctxBg(), map[string]string{"limit": "100000"}).Body)
+	if o.Count != 9592 || len(o.Primes) != sieveMaxList || !o.Truncated {
+		t.Fatalf("limit=100000 got count=%d len=%d truncated=%v", o.Count, len(o.Primes), o.Truncated)
+	}
+	if o.Primes[sieveMaxList-1] != 7919 { // primo número 1000
+		t.Fatalf("primo 1000 = %d", o.Primes[sieveMaxList-1])
+	}
+}
+
+func TestPrimeSieveJSONCtx_Range(t *testing.T) {
+	type out struct {
+		From   int64   `json:"from"`
+		To     int64   `json:"to"`
+		Primes []int64 `json:"primes"`
+		Count  int     `json:"count"`
+	}
+
+	r := PrimeSieveJSONCtx(ctxBg(), map[string]string{"from": "10", "to": "30"})
+	if r.Status != 200 || !r.JSON {
+		t.Fatalf("[10,30]: %+v", r)
+	}
+	o := mustJSON[out](t, r.Body)
+	want := []int64{11, 13, 17, 19, 23, 29}
+	if o.From != 10 || o.To != 30 || o.Count != len(want) || fmt.Sprint(o.Primes) != fmt.Sprint(want) {
+		t.Fatalf("[10,30] got %+v want %v", o, want)
+	}
+
+	// varios segmentos desde 0: coincide con π(10^5)
+	if o := mustJSON[out](t, PrimeSieveJSONCtx(ctxBg(), map[string]string{"from": "0", "to": "100000"}).Body); o.Count != 9592 || o.Primes[0] != 2 {
+		t.Fatalf("[0,1e5] count=%d first=%v", o.Count, o.Primes[:1])
+	}
+
+	// rango alto: se verifica contra ProbablyPrime
+	o = mustJSON[out](t, PrimeSieveJSONCtx(ctxBg(), map[string]string{"from": "999999000", "to": "1000000000"}).Body)
+	n := 0
+	for x := int64(999999000); x <= 1000000000; x++ {
+		if big.NewInt(x).ProbablyPrime(20) {
+			if n >= len(o.Primes) || o.Primes[n] != x {
+				t.Fatalf("falta primo %d en %v", x, o.Primes)
+			}
+			n++
+		}
+	}
+	if n != o.Count {
+		t.Fatalf("count=%d want %d", o.Count, n)
+	}
+
+	// rango sin primos
+	if o := mustJSON[out](t, PrimeSieveJSONCtx(ctxBg(), map[string]string{"from": "24", "to": "28"}).Body); o.Count != 0 || o.Primes == nil {
+		t.Fatalf("[24,28] got %+v", o)
+	}
+}
+
+func TestPrimeSieveJSONCtx_Validation_And_Cancel(t *testing.T) {
+	bad := []map[string]string{
+		{"to": "10"},
+		{"from": "-1", "to": "10"},
+		{"from": "20", "to": "10"},
+		{"from": "0", "to": "x"},
+		{"from": "0", "to": "1000001"},
+		{"from": "0", "to": "1000000000001"},
+	}
+	for _, p := range bad {
+		if r := PrimeSieveJSONCtx(ctxBg(), p); r.Status != 400 {
+			t.Fatalf("%v -> 400: %+v", p, r)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r := PrimeSieveJSONCtx(ctx, map[string]string{"from": "0", "to": "1000"}); r.Status != 503 {
+		t.Fatalf("canceled -> 503: %+v", r)
+	}
+}
+
+func TestSieveJSONCtx_Validation_And_Cancel(t *testing.T) {
+	for _, bad := range []string{"", "1", "0", "-7", "x", "10000001"} {
+		if r := SieveJSONCtx(ctxBg(), map[string]string{"limit": bad}); r.Status != 400 {
+			t.Fatalf("limit=%q -> 400: %+v", bad, r)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r := SieveJSONCtx(ctx, map[string]string{"limit": "1000"}); r.Status != 503 {
+		t.Fatalf("canceled -> 503: %+v", r)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package handlers
+
+// freeDiskBytes: sin statfs el espacio libre es desconocido y no se chequea.
+func freeDiskBytes(dir string) (uint64, bool) { return 0, false }
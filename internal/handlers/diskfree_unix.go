@@ -0,0 +1,14 @@
+//go:build unix
+
+package handlers
+
+import "syscall"
+
+// freeDiskBytes usa statfs: bloques disponibles para usuarios no root.
+func freeDiskBytes(dir string) (uint64, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, false
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), true
+}
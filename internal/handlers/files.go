@@ -2,11 +2,18 @@ package handlers
 
 import (
 	"bytes"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	mrand "math/rand"
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -18,11 +25,41 @@ import (
 // Montado desde docker-compose.yml (./data:/app/data).
 const dataDir = "/app/data"
 
-// WriteRepeat es un seam para tests: envuelve f.WriteString.
+// MaxRandomBytes acota random_bytes en /createfile para no agotar el disco.
+var MaxRandomBytes int64 = 64 << 20
+
+// FreeDiskBytes devuelve el espacio libre (bytes) del filesystem de dir;
+// ok=false si no se puede saber (entonces no se aplica el chequeo).
+// Seam para tests.
+var FreeDiskBytes = freeDiskBytes
+
+// diskCheckMin: por debajo de este tamaño proyectado /createfile no consulta
+// el espacio libre.
+const diskCheckMin = 1 << 20
+
+// WriteRepeat es un seam para tests: escribe content completo en f.
 // En producción usa esta implementación; en tests puedes reasignarlo.
+// Garantía: si devuelve nil se escribieron los len(content) bytes; las
+// escrituras cortas se reintentan con el resto hasta completar.
 var WriteRepeat = func(f *os.File, content string) error {
-	_, err := f.WriteString(content)
-	return err
+	b := []byte(content)
+	for len(b) > 0 {
+		n, err := writeChunk(f, b)
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			return io.ErrShortWrite // evita bucle infinito si no avanza
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// writeChunk es el seam de bajo nivel usado por WriteRepeat (un solo Write).
+// Los tests lo reasignan para simular escrituras cortas.
+var writeChunk = func(f *os.File, b []byte) (int, error) {
+	return f.Write(b)
 }
 
 // sanitize permite solo nombres simples de archivo (sin "../", "/" o "\").
@@ -53,11 +90,22 @@ Parámetros:
   - name=FILE           (obligatorio; pasa por sanitize)
   - content=TEXT        (opcional; default "")
   - repeat=N            (opcional; default 1; N>=1)
-  - conflict=fail|overwrite|autorename  (opcional; default fail)
+  - conflict=fail|overwrite|autorename|append  (opcional; default fail)
+  - hash=true           (opcional; default false) devuelve "sha256" del contenido escrito
+  - newline=lf|crlf|none (opcional; default lf) separador tras cada repetición
+  - random_bytes=N      (opcional; 1..MaxRandomBytes) escribe N bytes aleatorios;
+                        ignora content/repeat/newline
+  - seed=S              (opcional, con random_bytes) PRNG determinista; sin seed
+                        se usa crypto/rand
+
+Si el tamaño proyectado (>= diskCheckMin) no entra en el espacio libre de
+dataDir responde 507 insufficient_storage sin tocar el archivo.
 
 Comportamiento:
   - fail (default): si existe → 409 con suggested_name y hints.
   - overwrite: trunca/crea con ese nombre.
+  - append: agrega al final (O_APPEND); si no existe, crea. "bytes" es el
+    tamaño total resultante del archivo.
   - autorename:
       * regla única: siempre probar base + "(k)" con k=1..∞ (sin anidar más "(1)" sobre lo ya existente).
         Ejemplos:
@@ -84,8 +132,43 @@ func CreateFile(q map[string]string) resp.Result {
 	if mode == "" {
 		mode = "fail"
 	}
-	if mode != "fail" && mode != "overwrite" && mode != "autorename" {
-		return resp.BadReq("conflict", "use conflict=fail|overwrite|autorename")
+	if mode != "fail" && mode != "overwrite" && mode != "autorename" && mode != "append" {
+		return resp.BadReq("conflict", "use conflict=fail|overwrite|autorename|append")
+	}
+	sep := "\n"
+	switch q["newline"] {
+	case "", "lf":
+	case "crlf":
+		sep = "\r\n"
+	case "none":
+		sep = ""
+	default:
+		return resp.BadReq("newline", "use newline=lf|crlf|none")
+	}
+	withHash := false
+	if v := q["hash"]; v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return resp.BadReq("hash", "hash must be true|false")
+		}
+		withHash = b
+	}
+	var randN int64
+	var rnd io.Reader
+	if v := q["random_bytes"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > MaxRandomBytes {
+			return resp.BadReq("random_bytes", fmt.Sprintf("random_bytes must be integer in [1, %d]", MaxRandomBytes))
+		}
+		randN = n
+		rnd = crand.Reader
+		if sv := q["seed"]; sv != "" {
+			seed, err := strconv.ParseInt(sv, 10, 64)
+			if err != nil {
+				return resp.BadReq("seed", "seed must be integer")
+			}
+			rnd = mrand.New(mrand.NewSource(seed))
+		}
 	}
 
 	if err := os.MkdirAll(dataDir, 0o755); err != nil {
@@ -123,41 +206,114 @@ func CreateFile(q map[string]string) resp.Result {
 
 		case "overwrite":
 			action = "overwritten"
+
+		case "append":
+			action = "appended"
 		}
 	}
 
-	// Crear/truncar y escribir
-	f, err := os.Create(dst)
+	// Espacio libre: si lo proyectado no entra, 507 antes de truncar/escribir
+	projected := randN
+	if randN == 0 {
+		per := int64(len(content) + len(sep))
+		if per > 0 && int64(rep) > math.MaxInt64/per {
+			projected = math.MaxInt64
+		} else {
+			projected = int64(rep) * per
+		}
+	}
+	if action == "overwritten" {
+		if info, err := os.Stat(dst); err == nil {
+			projected -= info.Size() // el truncado libera lo anterior
+		}
+	}
+	if projected >= diskCheckMin {
+		if free, ok := FreeDiskBytes(dataDir); ok && uint64(projected) > free {
+			return resp.NoStorage("insufficient_storage",
+				fmt.Sprintf("need %d bytes, %d available", projected, free))
+		}
+	}
+
+	// Crear/truncar (o abrir en append) y escribir
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if mode == "append" {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(dst, flags, 0o666)
 	if err != nil {
 		return resp.IntErr("fs_error", "cannot create file")
 	}
 	defer f.Close()
 
+	// El hash se alimenta con los mismos bytes que se escriben (sin releer).
+	// written sólo suma tras un WriteRepeat exitoso, que garantiza escritura
+	// completa; así "bytes" coincide con el tamaño real del archivo.
+	h := sha256.New()
 	var written int64
+	if randN > 0 {
+		// Bloques de 64 KiB para no reservar N bytes de una vez.
+		buf := make([]byte, 64<<10)
+		for written < randN {
+			chunk := buf
+			if rest := randN - written; rest < int64(len(chunk)) {
+				chunk = chunk[:rest]
+			}
+			if _, err := io.ReadFull(rnd, chunk); err != nil {
+				return resp.IntErr("rand_error", "cannot read random source")
+			}
+			if err := WriteRepeat(f, string(chunk)); err != nil {
+				return resp.IntErr("fs_error", "write failed")
+			}
+			written += int64(len(chunk))
+			if withHash {
+				h.Write(chunk)
+			}
+		}
+		rep = 0 // content/repeat no aplican
+	}
 	for i := 0; i < rep; i++ {
 		if err := WriteRepeat(f, content); err != nil {
 			return resp.IntErr("fs_error", "write failed")
 		}
 		written += int64(len(content))
-		if err := WriteRepeat(f, "\n"); err != nil {
-			return resp.IntErr("fs_error", "write failed")
+		if sep != "" {
+			if err := WriteRepeat(f, sep); err != nil {
+				return resp.IntErr("fs_error", "write failed")
+			}
+			written += int64(len(sep))
+		}
+		if withHash {
+			h.Write([]byte(content))
+			h.Write([]byte(sep))
+		}
+	}
+
+	if action == "appended" {
+		// total real tras agregar (incluye lo que ya había)
+		if info, err := f.Stat(); err == nil {
+			written = info.Size()
 		}
-		written += 1
 	}
 
 	out := map[string]any{
         "file":       name,
-        "action":     action,  // created | overwritten | autorename
+        "action":     action,  // created | overwritten | autorename | appended
         "bytes":      written,
         "elapsed_ms": time.Since(start).Milliseconds(),
     }
     // Sólo muestra policy si NO es el default "fail"
     if mode != "fail" {
-        out["policy"] = mode // overwrite | autorename
+        out["policy"] = mode // overwrite | autorename | append
     }
     if action == "autorename" && renamedFrom != "" {
         out["renamed_from"] = renamedFrom
     }
+    if randN > 0 {
+        out["random"] = true
+    }
+    if withHash {
+        out["sha256"] = hex.EncodeToString(h.Sum(nil))
+    }
 
     b, _ := json.Marshal(out)
     return resp.JSONOK(string(b))
@@ -179,6 +335,139 @@ func DeleteFile(q map[string]string) resp.Result {
 	return resp.PlainOK("deleted\n")
 }
 
+// MoveFileJSON renombra from → to dentro de dataDir.
+// 409 si to existe (salvo overwrite=true), 404 si from no existe.
+func MoveFileJSON(q map[string]string) resp.Result {
+	from, ok := sanitize(q["from"])
+	if !ok {
+		return resp.BadReq("bad_name", "invalid from name")
+	}
+	to, ok := sanitize(q["to"])
+	if !ok {
+		return resp.BadReq("bad_name", "invalid to name")
+	}
+	overwrite, ok := optBool(q["overwrite"])
+	if !ok {
+		return resp.BadReq("overwrite", "overwrite must be true|false")
+	}
+
+	src := filepath.Join(dataDir, from)
+	dst := filepath.Join(dataDir, to)
+	if _, err := os.Stat(src); err != nil {
+		if os.IsNotExist(err) {
+			return resp.NotFound("not_found", "from does not exist")
+		}
+		return resp.IntErr("fs_error", "stat failed")
+	}
+	action := "moved"
+	if _, err := os.Stat(dst); err == nil {
+		if !overwrite {
+			return resp.Conflict("exists", "to already exists (use overwrite=true)")
+		}
+		action = "overwritten"
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return resp.IntErr("fs_error", "rename failed")
+	}
+	b, _ := json.Marshal(map[string]string{"from": from, "to": to, "action": action})
+	return resp.JSONOK(string(b))
+}
+
+// StatFileJSON devuelve metadatos de un archivo de dataDir. sha256_quick es el
+// hash de los primeros 4 KiB (barato); hash=false lo omite.
+func StatFileJSON(q map[string]string) resp.Result {
+	name, ok := sanitize(q["name"])
+	if !ok {
+		return resp.BadReq("bad_name", "invalid file name")
+	}
+	withHash := true
+	if v := q["hash"]; v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return resp.BadReq("hash", "hash must be true|false")
+		}
+		withHash = b
+	}
+	path := filepath.Join(dataDir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return resp.NotFound("not_found", "file does not exist")
+		}
+		return resp.IntErr("fs_error", "stat failed")
+	}
+
+	out := map[string]any{
+		"file":     name,
+		"size":     info.Size(),
+		"modified": info.ModTime().UTC().Format(time.RFC3339),
+		"mode":     info.Mode().String(),
+	}
+	if withHash && info.Mode().IsRegular() {
+		f, err := os.Open(path)
+		if err != nil {
+			return resp.IntErr("fs_error", "open failed")
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.CopyN(h, f, 4<<10); err != nil && err != io.EOF {
+			return resp.IntErr("fs_error", "read failed")
+		}
+		out["sha256_quick"] = hex.EncodeToString(h.Sum(nil))
+	}
+	b, _ := json.Marshal(out)
+	return resp.JSONOK(string(b))
+}
+
+// ListFilesJSON lista el contenido de dataDir ordenado por nombre.
+// pattern=REGEX (opcional) filtra por nombre; se omiten jobs.journal y results/.
+func ListFilesJSON(q map[string]string) resp.Result {
+	var re *regexp.Regexp
+	if pat := q["pattern"]; pat != "" {
+		r, err := regexp.Compile(pat)
+		if err != nil {
+			return resp.BadReq("pattern", "invalid regex")
+		}
+		re = r
+	}
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return resp.JSONOK(`{"files":[]}`)
+		}
+		return resp.IntErr("fs_error", "cannot read data dir")
+	}
+
+	type fileInfo struct {
+		Name     string `json:"name"`
+		Size     int64  `json:"size"`
+		Modified string `json:"modified"`
+		IsDir    bool   `json:"is_dir"`
+	}
+	files := make([]fileInfo, 0, len(entries))
+	for _, e := range entries { // os.ReadDir ya devuelve ordenado por nombre
+		// estado interno del Job Manager (journal y resultados), no son archivos del usuario
+		if e.Name() == "jobs.journal" || (e.Name() == "results" && e.IsDir()) {
+			continue
+		}
+		if re != nil && !re.MatchString(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // borrado entre ReadDir e Info
+		}
+		files = append(files, fileInfo{
+			Name:     e.Name(),
+			Size:     info.Size(),
+			Modified: info.ModTime().UTC().Format(time.RFC3339),
+			IsDir:    e.IsDir(),
+		})
+	}
+	b, _ := json.Marshal(map[string]any{"files": files})
+	return resp.JSONOK(string(b))
+}
+
 // ---------- Helpers de nombres ----------
 
 // Regla única para autorename (siempre "(k)" creciente sin anidar más niveles en la última parte):
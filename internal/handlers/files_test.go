@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -180,6 +184,79 @@ func TestCreateFile_Overwrite_ComputesBytes(t *testing.T) {
 	}
 }
 
+func TestCreateFile_Hash_MatchesRepeatedContent(t *testing.T) {
+	name := uniqueName("hashed")
+	full := filepath.Join(dataDir, name)
+	defer cleanup(full)
+
+	r := CreateFile(map[string]string{
+		"name":    name,
+		"content": "hola",
+		"repeat":  "3",
+		"hash":    "true",
+	})
+	if r.Status != 200 {
+		t.Fatalf("create with hash: %+v", r)
+	}
+	type out struct {
+		Sha256 string `json:"sha256"`
+	}
+	o := mustUnmarshal[out](t, r.Body)
+	sum := sha256.Sum256([]byte(strings.Repeat("hola\n", 3)))
+	if o.Sha256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("sha256=%q want %x", o.Sha256, sum)
+	}
+
+	// sin hash=true no se reporta el campo
+	r = CreateFile(map[string]string{"name": name, "content": "x", "conflict": "overwrite"})
+	if strings.Contains(r.Body, "sha256") {
+		t.Fatalf("hash must be opt-in: %s", r.Body)
+	}
+	if r := CreateFile(map[string]string{"name": name, "hash": "si", "conflict": "overwrite"}); r.Status != 400 {
+		t.Fatalf("bad hash flag -> 400: %+v", r)
+	}
+}
+
+func TestCreateFile_NewlineStyles(t *testing.T) {
+	cases := []struct {
+		newline string
+		want    string
+	}{
+		{"", "ab\nab\nab\n"},
+		{"lf", "ab\nab\nab\n"},
+		{"crlf", "ab\r\nab\r\nab\r\n"},
+		{"none", "ababab"},
+	}
+	for _, tc := range cases {
+		name := uniqueName("nl_" + tc.newline)
+		full := filepath.Join(dataDir, name)
+
+		r := CreateFile(map[string]string{"name": name, "content": "ab", "repeat": "3", "newline": tc.newline})
+		if r.Status != 200 {
+			t.Fatalf("newline=%q: %+v", tc.newline, r)
+		}
+		type out struct {
+			Bytes int64 `json:"bytes"`
+		}
+		o := mustUnmarshal[out](t, r.Body)
+		got, err := os.ReadFile(full)
+		cleanup(full)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if string(got) != tc.want {
+			t.Fatalf("newline=%q file=%q want %q", tc.newline, got, tc.want)
+		}
+		if o.Bytes != int64(len(tc.want)) {
+			t.Fatalf("newline=%q bytes=%d want %d", tc.newline, o.Bytes, len(tc.want))
+		}
+	}
+
+	if r := CreateFile(map[string]string{"name": uniqueName("nl_bad"), "newline": "cr"}); r.Status != 400 {
+		t.Fatalf("newline=cr should 400: %+v", r)
+	}
+}
+
 func TestCreateFile_Validations_And_WriteError(t *testing.T) {
 	// repeat inválido
 	if r := CreateFile(map[string]string{"name": "x.txt", "repeat": "0"}); r.Status != 400 {
@@ -222,6 +299,165 @@ func TestCreateFile_Validations_And_WriteError(t *testing.T) {
 	}
 }
 
+func TestCreateFile_InsufficientStorage(t *testing.T) {
+	prev := FreeDiskBytes
+	defer func() { FreeDiskBytes = prev }()
+	FreeDiskBytes = func(string) (uint64, bool) { return 4096, true }
+
+	name := uniqueName("nospace")
+	full := filepath.Join(dataDir, name)
+	defer cleanup(full)
+
+	// random_bytes de 2 MiB no entra en 4 KiB libres: 507 y sin archivo
+	r := CreateFile(map[string]string{"name": name, "random_bytes": "2097152", "seed": "1"})
+	if r.Status != 507 || r.Err == nil || r.Err.Code != "insufficient_storage" {
+		t.Fatalf("expected 507, got: %+v", r)
+	}
+	if _, err := os.Stat(full); !os.IsNotExist(err) {
+		t.Fatalf("no debe crearse el archivo")
+	}
+	// content*repeat también se proyecta (incluye el separador)
+	if r := CreateFile(map[string]string{"name": name, "content": "abcdefg", "repeat": "200000"}); r.Status != 507 {
+		t.Fatalf("repeat projection -> 507, got: %+v", r)
+	}
+	if r := CreateFile(map[string]string{"name": name, "content": "x", "repeat": "9223372036854775807"}); r.Status != 507 {
+		t.Fatalf("overflowing projection -> 507, got: %+v", r)
+	}
+
+	// archivos chicos no consultan el espacio libre
+	if r := CreateFile(map[string]string{"name": name, "content": "hola"}); r.Status != 200 {
+		t.Fatalf("small file must be written: %+v", r)
+	}
+
+	// espacio desconocido: no se aplica el chequeo
+	FreeDiskBytes = func(string) (uint64, bool) { return 0, false }
+	if r := CreateFile(map[string]string{"name": name, "random_bytes": "2097152", "seed": "1", "conflict": "overwrite"}); r.Status != 200 {
+		t.Fatalf("unknown free space must not block: %+v", r)
+	}
+}
+
+func TestCreateFile_ShortWrites_AreCompleted(t *testing.T) {
+	name := uniqueName("shortwrite")
+	full := filepath.Join(dataDir, name)
+	defer cleanup(full)
+
+	// Simula un FS que acepta como máximo 3 bytes por Write.
+	prev := writeChunk
+	defer func() { writeChunk = prev }()
+	calls := 0
+	writeChunk = func(f *os.File, b []byte) (int, error) {
+		calls++
+		if len(b) > 3 {
+			b = b[:3]
+		}
+		return f.Write(b)
+	}
+
+	r := CreateFile(map[string]string{
+		"name":     name,
+		"content":  "payload-largo",
+		"repeat":   "3",
+		"conflict": "overwrite",
+	})
+	if r.Status != 200 {
+		t.Fatalf("create with short writes: %+v", r)
+	}
+	want := strings.Repeat("payload-largo\n", 3)
+	got, err := os.ReadFile(full)
+	if err != nil || string(got) != want {
+		t.Fatalf("content=%q err=%v want %q", got, err, want)
+	}
+	out := mustUnmarshal[struct {
+		Bytes int64 `json:"bytes"`
+	}](t, r.Body)
+	if out.Bytes != int64(len(want)) {
+		t.Fatalf("bytes=%d want %d", out.Bytes, len(want))
+	}
+	if calls <= 6 {
+		t.Fatalf("expected multiple partial writes, got %d calls", calls)
+	}
+
+	// Un Write que no avanza ni falla no debe colgar: io.ErrShortWrite.
+	writeChunk = func(f *os.File, b []byte) (int, error) { return 0, nil }
+	if r := CreateFile(map[string]string{
+		"name": name, "content": "x", "conflict": "overwrite",
+	}); r.Status != 500 {
+		t.Fatalf("zero-progress write -> 500: %+v", r)
+	}
+}
+
+func TestCreateFile_RandomBytes_SizeAndSeed(t *testing.T) {
+	a := uniqueName("rand_a")
+	b := uniqueName("rand_b")
+	defer cleanup(filepath.Join(dataDir, a))
+	defer cleanup(filepath.Join(dataDir, b))
+
+	type out struct {
+		Bytes  int64  `json:"bytes"`
+		Random bool   `json:"random"`
+		SHA256 string `json:"sha256"`
+	}
+	var hashes []string
+	for _, name := range []string{a, b} {
+		r := CreateFile(map[string]string{
+			"name": name, "random_bytes": "1024", "seed": "42",
+			"content": "ignorado", "repeat": "5", "hash": "true",
+		})
+		if r.Status != 200 {
+			t.Fatalf("random create: %+v", r)
+		}
+		o := mustUnmarshal[out](t, r.Body)
+		info, err := os.Stat(filepath.Join(dataDir, name))
+		if err != nil || info.Size() != 1024 || o.Bytes != 1024 || !o.Random {
+			t.Fatalf("size: payload=%+v err=%v", o, err)
+		}
+		hashes = append(hashes, o.SHA256)
+	}
+	ca, _ := os.ReadFile(filepath.Join(dataDir, a))
+	cb, _ := os.ReadFile(filepath.Join(dataDir, b))
+	if !bytes.Equal(ca, cb) || hashes[0] != hashes[1] {
+		t.Fatalf("same seed must produce identical content")
+	}
+
+	if r := CreateFile(map[string]string{"name": a, "random_bytes": "0"}); r.Status != 400 {
+		t.Fatalf("random_bytes=0 -> 400: %+v", r)
+	}
+	if r := CreateFile(map[string]string{
+		"name": a, "random_bytes": strconv.FormatInt(MaxRandomBytes+1, 10),
+	}); r.Status != 400 {
+		t.Fatalf("random_bytes over cap -> 400: %+v", r)
+	}
+}
+
+func TestCreateFile_Append_AccumulatesBytes(t *testing.T) {
+	name := uniqueName("append")
+	full := filepath.Join(dataDir, name)
+	defer cleanup(full)
+
+	type out struct {
+		Action string `json:"action"`
+		Bytes  int64  `json:"bytes"`
+	}
+	// no existe: se comporta como create
+	r := CreateFile(map[string]string{"name": name, "content": "abc", "repeat": "2", "conflict": "append"})
+	if r.Status != 200 {
+		t.Fatalf("append (create): %+v", r)
+	}
+	if o := mustUnmarshal[out](t, r.Body); o.Action != "created" || o.Bytes != 8 {
+		t.Fatalf("first append payload: %+v", o)
+	}
+
+	r = CreateFile(map[string]string{"name": name, "content": "xy", "conflict": "append"})
+	if r.Status != 200 {
+		t.Fatalf("append: %+v", r)
+	}
+	o := mustUnmarshal[out](t, r.Body)
+	got, _ := os.ReadFile(full)
+	if o.Action != "appended" || string(got) != "abc\nabc\nxy\n" || o.Bytes != int64(len(got)) {
+		t.Fatalf("second append payload=%+v content=%q", o, got)
+	}
+}
+
 func TestDeleteFile_OK_And_NotFound(t *testing.T) {
 	// crea
 	name := uniqueName("todel")
@@ -379,3 +615,151 @@ func TestFirstAvailableAppendCounter_BaseAlreadyHasParens(t *testing.T) {
 		t.Fatalf("want %q got %q", "foo(4)(2).txt", got)
 	}
 }
+
+func TestListFilesJSON_SizesAndPattern(t *testing.T) {
+	a := uniqueName("list_a")
+	b := uniqueName("list_b")
+	pa, pb := filepath.Join(dataDir, a), filepath.Join(dataDir, b)
+	defer cleanup(pa)
+	defer cleanup(pb)
+	if err := os.WriteFile(pa, []byte("12345"), 0o644); err != nil {
+		t.Fatalf("write a: %v", err)
+	}
+	if err := os.WriteFile(pb, []byte("1234567890"), 0o644); err != nil {
+		t.Fatalf("write b: %v", err)
+	}
+	// directorio de resultados del Job Manager (puede existir ya)
+	rdir := filepath.Join(dataDir, "results")
+	if _, err := os.Stat(rdir); os.IsNotExist(err) {
+		if err := os.Mkdir(rdir, 0o755); err != nil {
+			t.Fatalf("mkdir results: %v", err)
+		}
+		defer os.Remove(rdir)
+	}
+
+	type entry struct {
+		Name  string `json:"name"`
+		Size  int64  `json:"size"`
+		IsDir bool   `json:"is_dir"`
+	}
+	type out struct {
+		Files []entry `json:"files"`
+	}
+	r := ListFilesJSON(map[string]string{})
+	if r.Status != 200 || !r.JSON {
+		t.Fatalf("listfiles: %+v", r)
+	}
+	o := mustUnmarshal[out](t, r.Body)
+	sizes := map[string]int64{}
+	for i, e := range o.Files {
+		if e.Name == "jobs.journal" || e.Name == "results" {
+			t.Fatalf("%s must be skipped", e.Name)
+		}
+		if i > 0 && o.Files[i-1].Name > e.Name {
+			t.Fatalf("not sorted by name: %q > %q", o.Files[i-1].Name, e.Name)
+		}
+		sizes[e.Name] = e.Size
+	}
+	if sizes[a] != 5 || sizes[b] != 10 {
+		t.Fatalf("sizes a=%d b=%d", sizes[a], sizes[b])
+	}
+
+	o = mustUnmarshal[out](t, ListFilesJSON(map[string]string{"pattern": "^" + regexp.QuoteMeta(a) + "$"}).Body)
+	if len(o.Files) != 1 || o.Files[0].Name != a {
+		t.Fatalf("pattern filter: %+v", o.Files)
+	}
+	if r := ListFilesJSON(map[string]string{"pattern": "("}); r.Status != 400 {
+		t.Fatalf("bad regex -> 400: %+v", r)
+	}
+}
+
+func TestMoveFileJSON_MoveConflictOverwrite(t *testing.T) {
+	a, b := uniqueName("mv_a"), uniqueName("mv_b")
+	pa, pb := filepath.Join(dataDir, a), filepath.Join(dataDir, b)
+	defer cleanup(pa)
+	defer cleanup(pb)
+	if err := os.WriteFile(pa, []byte("AAA"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// move OK
+	r := MoveFileJSON(map[string]string{"from": a, "to": b})
+	if r.Status != 200 || !strings.Contains(r.Body, `"action":"moved"`) {
+		t.Fatalf("move: %+v", r)
+	}
+	if _, err := os.Stat(pa); !os.IsNotExist(err) {
+		t.Fatalf("from must be gone: %v", err)
+	}
+	if got, _ := os.ReadFile(pb); string(got) != "AAA" {
+		t.Fatalf("to content=%q", got)
+	}
+
+	// colisión → 409
+	if err := os.WriteFile(pa, []byte("NEW"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if r := MoveFileJSON(map[string]string{"from": a, "to": b}); r.Status != 409 {
+		t.Fatalf("collision -> 409: %+v", r)
+	}
+
+	// overwrite=true reemplaza
+	r = MoveFileJSON(map[string]string{"from": a, "to": b, "overwrite": "true"})
+	if r.Status != 200 || !strings.Contains(r.Body, `"action":"overwritten"`) {
+		t.Fatalf("overwrite: %+v", r)
+	}
+	if got, _ := os.ReadFile(pb); string(got) != "NEW" {
+		t.Fatalf("overwritten content=%q", got)
+	}
+
+	if r := MoveFileJSON(map[string]string{"from": "../x", "to": b}); r.Status != 400 {
+		t.Fatalf("bad from -> 400: %+v", r)
+	}
+	if r := MoveFileJSON(map[string]string{"from": b, "to": "a/b"}); r.Status != 400 {
+		t.Fatalf("bad to -> 400: %+v", r)
+	}
+	if r := MoveFileJSON(map[string]string{"from": a, "to": uniqueName("mv_c")}); r.Status != 404 {
+		t.Fatalf("missing from -> 404: %+v", r)
+	}
+}
+
+func TestStatFileJSON_KnownFile(t *testing.T) {
+	name := uniqueName("stat")
+	full := filepath.Join(dataDir, name)
+	defer cleanup(full)
+	content := strings.Repeat("a", 5000) // > 4 KiB: el hash rápido sólo cubre el prefijo
+	if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	r := StatFileJSON(map[string]string{"name": name})
+	if r.Status != 200 || !r.JSON {
+		t.Fatalf("statfile: %+v", r)
+	}
+	o := mustUnmarshal[struct {
+		File        string `json:"file"`
+		Size        int64  `json:"size"`
+		Modified    string `json:"modified"`
+		Mode        string `json:"mode"`
+		SHA256Quick string `json:"sha256_quick"`
+	}](t, r.Body)
+	if o.File != name || o.Size != 5000 || o.Mode == "" {
+		t.Fatalf("payload: %+v", o)
+	}
+	if _, err := time.Parse(time.RFC3339, o.Modified); err != nil {
+		t.Fatalf("modified no es RFC3339: %q (%v)", o.Modified, err)
+	}
+	sum := sha256.Sum256([]byte(content[:4096]))
+	if o.SHA256Quick != hex.EncodeToString(sum[:]) {
+		t.Fatalf("sha256_quick=%s", o.SHA256Quick)
+	}
+
+	if r := StatFileJSON(map[string]string{"name": name, "hash": "false"}); strings.Contains(r.Body, "sha256_quick") {
+		t.Fatalf("hash=false must omit sha256_quick: %s", r.Body)
+	}
+	if r := StatFileJSON(map[string]string{"name": "../x"}); r.Status != 400 {
+		t.Fatalf("bad name -> 400: %+v", r)
+	}
+	if r := StatFileJSON(map[string]string{"name": uniqueName("nope")}); r.Status != 404 {
+		t.Fatalf("missing -> 404: %+v", r)
+	}
+}
@@ -1,16 +1,24 @@
 package handlers
 
 import (
+	"archive/tar"
 	"bufio"
+	"bytes"
 	"compress/gzip"
 	"container/heap"
 	"context"
+	"crypto/md5"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -18,9 +26,11 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"so-http10-demo/internal/resp"
+	"so-http10-demo/internal/util"
 )
 
 /*
@@ -51,6 +61,62 @@ func ctxErrResult(ctx context.Context) resp.Result {
 	return resp.Unavail("canceled", ctx.Err().Error())
 }
 
+/*
+   ===============================================================
+   Lock por archivo
+   - Las operaciones que escriben (sortfile, compress, decompress,
+     copyfile, archive) toman el lock de su ruta de salida, así dos
+     pedidos sobre el mismo archivo se serializan en vez de pisarse.
+   - Las de sólo lectura (grep, wordcount, hashfile...) no lo usan.
+   ===============================================================
+*/
+
+type fileLock struct {
+	ch   chan struct{} // capacidad 1: lleno = tomado
+	refs int           // dueños + en espera; en 0 se borra del mapa
+}
+
+var (
+	fileLocksMu sync.Mutex
+	fileLocks   = map[string]*fileLock{}
+)
+
+// lockFile toma el lock de path (ruta ya resuelta) esperando a que se libere
+// o a que se cancele ctx. Con ok=false no hay nada que liberar.
+func lockFile(ctx context.Context, path string) (unlock func(), ok bool) {
+	fileLocksMu.Lock()
+	l := fileLocks[path]
+	if l == nil {
+		l = &fileLock{ch: make(chan struct{}, 1)}
+		fileLocks[path] = l
+	}
+	l.refs++
+	fileLocksMu.Unlock()
+
+	release := func() {
+		fileLocksMu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(fileLocks, path)
+		}
+		fileLocksMu.Unlock()
+	}
+
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	select {
+	case l.ch <- struct{}{}:
+	case <-done:
+		release()
+		return nil, false
+	}
+	return func() {
+		<-l.ch
+		release()
+	}, true
+}
+
 /*
    ===============================================================
    Helper: limpiar líneas numéricas (BOM + espacios)
@@ -73,11 +139,15 @@ func cleanIntLine(b []byte) string {
 
 /*
    ===============================================================
-   /wordcount?name=FILE
+   /wordcount?name=FILE[&top=N][&skip_blank=true]
    - Cuenta líneas, palabras y bytes (tipo `wc`).
    - Soporta archivos grandes (lectura streaming).
+   - top=N (máx 100): agrega "top_words" con los N tokens (separados por
+     espacios) más frecuentes, por count desc y luego word asc.
+   - skip_blank=true: las líneas vacías o sólo con espacios no suman a
+     "lines" (sus bytes sí se cuentan).
    Respuesta (orden estable):
-     {"file":..., "lines":N, "words":N, "bytes":N, "elapsed_ms":N}
+     {"file":..., "lines":N, "words":N, "bytes":N, "top_words":[...], "elapsed_ms":N}
    ===============================================================
 */
 
@@ -95,6 +165,21 @@ func WordCountJSONCtx(ctx context.Context, params map[string]string) resp.Result
 	if !ok {
 		return resp.BadReq("bad_name", "invalid file name")
 	}
+	top := 0
+	if v := params["top"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return resp.BadReq("top", "top must be integer >= 0")
+		}
+		if n > wordcountMaxTop {
+			n = wordcountMaxTop
+		}
+		top = n
+	}
+	skipBlank, ok := optBool(params["skip_blank"])
+	if !ok {
+		return resp.BadReq("skip_blank", "skip_blank must be true|false")
+	}
 
 	fp := filepath.Join(dataDir, path)
 	f, err := os.Open(fp)
@@ -108,6 +193,10 @@ func WordCountJSONCtx(ctx context.Context, params map[string]string) resp.Result
 
 	start := time.Now()
 	var lines, words, bytes int64
+	var freq map[string]int // sólo con top>0
+	if top > 0 {
+		freq = make(map[string]int)
+	}
 
 	sc := bufio.NewScanner(f)
 	// Si esperas líneas muy grandes, descomenta y ajusta:
@@ -120,46 +209,127 @@ func WordCountJSONCtx(ctx context.Context, params map[string]string) resp.Result
 		}
 		i++
 
-		lines++
 		b := sc.Bytes()
 		bytes += int64(len(b) + 1) // +1 por '\n' (Scanner quita el salto)
 
+		w0 := words
 		inWord := false
-		for _, c := range b {
+		ws := 0 // inicio del token actual
+		for j, c := range b {
 			if c > ' ' {
 				if !inWord {
 					words++
 					inWord = true
+					ws = j
 				}
 			} else {
+				if inWord && freq != nil {
+					freq[string(b[ws:j])]++
+				}
 				inWord = false
 			}
 		}
+		if inWord && freq != nil {
+			freq[string(b[ws:])]++
+		}
+		// sin tokens => línea vacía o sólo espacios
+		if !skipBlank || words > w0 {
+			lines++
+		}
 	}
 	if err := sc.Err(); err != nil {
 		return resp.IntErr("fs_error", "scan error")
 	}
 
 	type out struct {
-		File      string `json:"file"`
-		Lines     int64  `json:"lines"`
-		Words     int64  `json:"words"`
-		Bytes     int64  `json:"bytes"`
-		ElapsedMS int64  `json:"elapsed_ms"`
+		File      string      `json:"file"`
+		Lines     int64       `json:"lines"`
+		Words     int64       `json:"words"`
+		Bytes     int64       `json:"bytes"`
+		TopWords  []wordFreq  `json:"top_words,omitempty"`
+		ElapsedMS int64       `json:"elapsed_ms"`
+	}
+	var topWords []wordFreq
+	if freq != nil {
+		topWords = topWordsN(freq, top)
 	}
 	b, _ := json.Marshal(out{
 		File: path, Lines: lines, Words: words, Bytes: bytes,
+		TopWords:  topWords,
 		ElapsedMS: time.Since(start).Milliseconds(),
 	})
 	return resp.JSONOK(string(b))
 }
 
+// wordcountMaxTop limita top=N de /wordcount.
+const wordcountMaxTop = 100
+
+// wordFreq es una entrada de "top_words".
+type wordFreq struct {
+	Word  string `json:"word"`
+	Count int    `json:"count"`
+}
+
+// wordFreqBefore define el orden final: count desc, luego word asc.
+func wordFreqBefore(a, b wordFreq) bool {
+	if a.Count != b.Count {
+		return a.Count > b.Count
+	}
+	return a.Word < b.Word
+}
+
+// wordFreqHeap es un min-heap (según wordFreqBefore): la raíz es la peor
+// entrada del top actual y es la que se descarta.
+type wordFreqHeap []wordFreq
+
+func (h wordFreqHeap) Len() int            { return len(h) }
+func (h wordFreqHeap) Less(i, j int) bool  { return wordFreqBefore(h[j], h[i]) }
+func (h wordFreqHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *wordFreqHeap) Push(x any)         { *h = append(*h, x.(wordFreq)) }
+func (h *wordFreqHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// topWordsN selecciona los n mejores con un heap de tamaño n (O(k log n))
+// y sólo ordena ese resultado.
+func topWordsN(freq map[string]int, n int) []wordFreq {
+	h := make(wordFreqHeap, 0, n)
+	for w, c := range freq {
+		e := wordFreq{Word: w, Count: c}
+		if h.Len() < n {
+			heap.Push(&h, e)
+		} else if wordFreqBefore(e, h[0]) {
+			h[0] = e
+			heap.Fix(&h, 0)
+		}
+	}
+	out := []wordFreq(h)
+	sort.Slice(out, func(i, j int) bool { return wordFreqBefore(out[i], out[j]) })
+	return out
+}
+
 /*
    ===============================================================
-   /grep?name=FILE&pattern=REGEX
+   /grep?name=FILE&pattern=REGEX[&fixed=true][&word=true][&ignorecase=true][&count_only=true][&context=N][&capture=true]
    - Devuelve número de coincidencias y las primeras 10 líneas que hacen match
+   - word=true: sólo palabras completas (como `grep -w`), envuelve el patrón
+     en \b(?:...)\b para que "cat" no coincida con "category".
+   - ignorecase=true: antepone (?i) al patrón (como `grep -i`).
+   - fixed=true: el patrón es texto literal (como `grep -F`): "a.b" sólo
+     coincide con "a.b"; se escapa con regexp.QuoteMeta y compone con el resto.
+   - count_only=true: sólo cuenta; "first" sale vacío (ahorra memoria).
+   - context=N (máx 20): para las primeras 10 coincidencias agrega "blocks",
+     cada uno con N líneas antes/después: [{"line_no":N,"text":...}, ...].
+   - capture=true: para las primeras 10 coincidencias agrega "captures" con
+     el substring que hizo match y el grupo 1 (si existe).
+   - "match_ratio" = matches/lines_scanned (4 decimales; 0 si el archivo
+     está vacío) para ver rápido la selectividad del patrón.
    Respuesta (orden estable):
-     {"file":..., "pattern":..., "matches":N, "first":[...], "elapsed_ms":N}
+     {"file":..., "pattern":..., "matches":N, "lines_scanned":N, "match_ratio":R,
+      "first":[...], "elapsed_ms":N}
    ===============================================================
 */
 
@@ -177,7 +347,38 @@ func GrepJSONCtx(ctx context.Context, params map[string]string) resp.Result {
 	if !ok {
 		return resp.BadReq("bad_name", "invalid file name")
 	}
-	re, err := regexp.Compile(pat)
+	word, ok := optBool(params["word"])
+	if !ok {
+		return resp.BadReq("word", "word must be true|false")
+	}
+	icase, ok := optBool(params["ignorecase"])
+	if !ok {
+		return resp.BadReq("ignorecase", "ignorecase must be true|false")
+	}
+	fixed, ok := optBool(params["fixed"])
+	if !ok {
+		return resp.BadReq("fixed", "fixed must be true|false")
+	}
+	countOnly, ok := optBool(params["count_only"])
+	if !ok {
+		return resp.BadReq("count_only", "count_only must be true|false")
+	}
+	capture, ok := optBool(params["capture"])
+	if !ok {
+		return resp.BadReq("capture", "capture must be true|false")
+	}
+	ctxLines := 0
+	if v := params["context"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return resp.BadReq("context", "context must be integer >= 0")
+		}
+		if n > grepMaxContext {
+			n = grepMaxContext
+		}
+		ctxLines = n
+	}
+	re, err := regexp.Compile(grepExpr(pat, fixed, word, icase))
 	if err != nil {
 		return resp.BadReq("pattern", "invalid regex")
 	}
@@ -197,6 +398,14 @@ func GrepJSONCtx(ctx context.Context, params map[string]string) resp.Result {
 	matches := 0
 	first := make([]string, 0, 10)
 
+	// Contexto (-C N): ring con las últimas N líneas y, por cada bloque
+	// abierto, cuántas líneas posteriores le faltan. Una sola pasada.
+	withBlocks := ctxLines > 0 && !countOnly
+	var ring []grepLine
+	var blocks [][]grepLine
+	var pending []int // pending[k] = líneas "after" que le faltan a blocks[k]
+	var captures []grepCapture
+
 	i := 0
 	for sc.Scan() {
 		if i&(checkEvery-1) == 0 && canceled(ctx) {
@@ -205,37 +414,125 @@ func GrepJSONCtx(ctx context.Context, params map[string]string) resp.Result {
 		i++
 
 		line := sc.Text()
+		if withBlocks {
+			for k := range pending {
+				if pending[k] > 0 {
+					blocks[k] = append(blocks[k], grepLine{LineNo: i, Text: line})
+					pending[k]--
+				}
+			}
+		}
 		if re.MatchString(line) {
 			matches++
-			if len(first) < 10 {
+			if !countOnly && len(first) < 10 {
 				first = append(first, line)
+				if capture {
+					m := re.FindStringSubmatch(line)
+					c := grepCapture{Match: m[0]}
+					if len(m) > 1 {
+						c.Group1 = &m[1]
+					}
+					captures = append(captures, c)
+				}
+				if withBlocks {
+					blk := make([]grepLine, 0, 2*ctxLines+1)
+					blk = append(blk, ring...)
+					blk = append(blk, grepLine{LineNo: i, Text: line})
+					blocks = append(blocks, blk)
+					pending = append(pending, ctxLines)
+				}
+			}
+		}
+		if withBlocks {
+			if len(ring) == ctxLines {
+				ring = append(ring[:0], ring[1:]...)
 			}
+			ring = append(ring, grepLine{LineNo: i, Text: line})
 		}
 	}
 	if err := sc.Err(); err != nil {
 		return resp.IntErr("fs_error", "scan error")
 	}
 
+	ratio := 0.0
+	if i > 0 {
+		ratio = math.Round(float64(matches)/float64(i)*10000) / 10000
+	}
+
 	type out struct {
-		File      string   `json:"file"`
-		Pattern   string   `json:"pattern"`
-		Matches   int      `json:"matches"`
-		First     []string `json:"first"`
-		ElapsedMS int64    `json:"elapsed_ms"`
+		File      string       `json:"file"`
+		Pattern   string       `json:"pattern"`
+		Matches   int          `json:"matches"`
+		Scanned   int           `json:"lines_scanned"`
+		Ratio     float64       `json:"match_ratio"`
+		First     []string      `json:"first"`
+		Captures  []grepCapture `json:"captures,omitempty"`
+		Blocks    [][]grepLine  `json:"blocks,omitempty"`
+		ElapsedMS int64         `json:"elapsed_ms"`
 	}
 	b, _ := json.Marshal(out{
 		File: path, Pattern: pat, Matches: matches, First: first,
+		Scanned:   i,
+		Ratio:     ratio,
+		Captures:  captures,
+		Blocks:    blocks,
 		ElapsedMS: time.Since(start).Milliseconds(),
 	})
 	return resp.JSONOK(string(b))
 }
 
+// grepMaxContext limita context=N para acotar memoria por bloque.
+const grepMaxContext = 20
+
+// grepLine es una línea dentro de un bloque de contexto.
+type grepLine struct {
+	LineNo int    `json:"line_no"`
+	Text   string `json:"text"`
+}
+
+// grepCapture es el texto que hizo match (y el grupo 1 si el patrón lo tiene).
+type grepCapture struct {
+	Match  string  `json:"match"`
+	Group1 *string `json:"group1,omitempty"`
+}
+
+// grepExpr arma la expresión final a partir del patrón del usuario.
+// Las opciones se aplican como envoltorios para que sigan componiendo
+// entre sí (p. ej., word + flags de mayúsculas).
+func grepExpr(pat string, fixed, word, icase bool) string {
+	if fixed {
+		pat = regexp.QuoteMeta(pat)
+	}
+	if word {
+		pat = `\b(?:` + pat + `)\b`
+	}
+	if icase {
+		pat = `(?i)` + pat
+	}
+	return pat
+}
+
+// optBool interpreta un flag opcional ("" => false). ok=false si no es booleano.
+func optBool(v string) (bool, bool) {
+	if v == "" {
+		return false, true
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, false
+	}
+	return b, true
+}
+
 /*
    ===============================================================
-   /hashfile?name=FILE&algo=sha256
-   - Calcula hash SHA-256 streaming.
+   /hashfile?name=FILE&algo=sha256|sha1|sha512|md5[&encoding=hex|base64]
+   - Calcula el hash en streaming (default sha256).
+   - encoding=base64 devuelve el digest en base64 estándar (con padding)
+     en "digest" en lugar de "hex" (default hex, respuesta sin cambios).
    Respuesta (orden estable):
      {"file":..., "algo":"sha256", "hex":"...", "elapsed_ms":N}
+     {"file":..., "algo":"sha256", "encoding":"base64", "digest":"...", "elapsed_ms":N}
    ===============================================================
 */
 
@@ -249,8 +546,16 @@ func HashFileJSONCtx(ctx context.Context, params map[string]string) resp.Result
 	if algo == "" {
 		algo = "sha256"
 	}
-	if algo != "sha256" {
-		return resp.BadReq("algo", "only sha256 is supported for now")
+	h := newHash(algo)
+	if h == nil {
+		return resp.BadReq("algo", "use algo=sha256|sha1|sha512|md5")
+	}
+	enc := params["encoding"]
+	if enc == "" {
+		enc = "hex"
+	}
+	if enc != "hex" && enc != "base64" {
+		return resp.BadReq("encoding", "use encoding=hex|base64")
 	}
 	if name == "" {
 		return resp.BadReq("name", "file name required")
@@ -271,7 +576,12 @@ func HashFileJSONCtx(ctx context.Context, params map[string]string) resp.Result
 	defer f.Close()
 
 	start := time.Now()
-	h := sha256.New()
+
+	// total para el progreso (si corre como job); 0 si no se puede saber
+	var total, done int64
+	if info, err := f.Stat(); err == nil {
+		total = info.Size()
+	}
 
 	buf := make([]byte, 1<<20) // 1 MiB
 	for {
@@ -283,6 +593,8 @@ func HashFileJSONCtx(ctx context.Context, params map[string]string) resp.Result
 			if _, werr := h.Write(buf[:n]); werr != nil {
 				return resp.IntErr("fs_error", "hash write error")
 			}
+			done += int64(n)
+			util.ReportProgress(ctx, done, total)
 		}
 		if rerr == io.EOF {
 			break
@@ -295,24 +607,56 @@ func HashFileJSONCtx(ctx context.Context, params map[string]string) resp.Result
 	type out struct {
 		File      string `json:"file"`
 		Algo      string `json:"algo"`
-		Hex       string `json:"hex"`
+		Hex       string `json:"hex,omitempty"`
+		Encoding  string `json:"encoding,omitempty"`
+		Digest    string `json:"digest,omitempty"`
 		ElapsedMS int64  `json:"elapsed_ms"`
 	}
-	b, _ := json.Marshal(out{
-		File: path, Algo: "sha256", Hex: hex.EncodeToString(h.Sum(nil)),
-		ElapsedMS: time.Since(start).Milliseconds(),
-	})
+	o := out{File: path, Algo: algo, ElapsedMS: time.Since(start).Milliseconds()}
+	if sum := h.Sum(nil); enc == "base64" {
+		o.Encoding, o.Digest = enc, base64.StdEncoding.EncodeToString(sum)
+	} else {
+		o.Hex = hex.EncodeToString(sum)
+	}
+	b, _ := json.Marshal(o)
 	return resp.JSONOK(string(b))
 }
 
+// newHash es la fábrica de algoritmos soportados por /hashfile.
+// Devuelve nil si el nombre no es reconocido.
+func newHash(algo string) hash.Hash {
+	switch algo {
+	case "sha256":
+		return sha256.New()
+	case "sha1":
+		return sha1.New()
+	case "sha512":
+		return sha512.New()
+	case "md5":
+		return md5.New()
+	}
+	return nil
+}
+
 /*
    ===============================================================
    /sortfile?name=FILE&algo=merge|quick[&chunksize=N]
+   /sortfile?premerged=true&names=C1,C2,...[&name=OUT]
    - Ordena enteros (uno por línea).
    - "merge": external sort (para archivos >= 50MB).
    - "quick": in-memory (rápido si cabe en RAM).
+   - "algo_reason" explica la elección: requested (el pedido), default (sin
+     algo => merge) o default_invalid (algo desconocido => merge).
+   - premerged: los chunks ya vienen ordenados; solo se ejecuta el
+     k-way merge. La salida es OUT.sorted (por defecto C1.sorted); si es
+     uno de los chunks → 409 output_is_input.
+   - order=asc|desc (default asc).
+   - keytype=int|string (default int): string ordena líneas
+     lexicográficamente en vez de parsear int64.
+   - dedup=1: omite valores repetidos y reporta "unique" (distintos emitidos).
    Respuesta (orden estable):
-     {"file":..., "algo":..., "sorted_file":..., "chunks":N, "bytes_in":N,
+     {"file":..., "algo":..., "algo_reason":..., "order":..., "keytype":...,
+      "sorted_file":..., "chunks":N, "bytes_in":N,
       "bytes_out":N, "elapsed_ms":N}
    ===============================================================
 */
@@ -322,6 +666,9 @@ func SortFileJSON(params map[string]string) resp.Result {
 }
 
 func SortFileJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	if v := params["premerged"]; v == "1" || v == "true" {
+		return sortPremergedCtx(ctx, params)
+	}
 	name := params["name"]
 	if name == "" {
 		return resp.BadReq("name", "file name required")
@@ -333,14 +680,22 @@ func SortFileJSONCtx(ctx context.Context, params map[string]string) resp.Result
 	inPath := filepath.Join(dataDir, base)
 	outPath := inPath + ".sorted"
 
-	algo := params["algo"]
+	algo, reason := params["algo"], "requested"
 	if algo != "quick" && algo != "merge" {
+		reason = "default_invalid"
+		if algo == "" {
+			reason = "default"
+		}
 		algo = "merge" // por defecto: external sort (más robusto)
 	}
 	chunkSize := 1_000_000 // líneas por chunk en modo merge
 	if v, err := strconv.Atoi(params["chunksize"]); err == nil && v > 0 {
 		chunkSize = v
 	}
+	opts, bad, ok := parseSortOpts(params)
+	if !ok {
+		return bad
+	}
 
 	info, err := os.Stat(inPath)
 	if err != nil {
@@ -351,12 +706,18 @@ func SortFileJSONCtx(ctx context.Context, params map[string]string) resp.Result
 	}
 	bytesIn := info.Size()
 
+	unlock, ok := lockFile(ctx, outPath)
+	if !ok {
+		return ctxErrResult(ctx)
+	}
+	defer unlock()
+
 	start := time.Now()
-	var chunks int
+	var chunks, emitted int
 	if algo == "quick" {
-		chunks, err = sortInMemoryCtx(ctx, inPath, outPath)
+		chunks, emitted, err = sortInMemoryCtx(ctx, inPath, outPath, opts)
 	} else {
-		chunks, err = externalSortCtx(ctx, inPath, outPath, chunkSize)
+		chunks, emitted, err = externalSortCtx(ctx, inPath, outPath, chunkSize, opts)
 	}
 	if err != nil {
 		if errors.Is(err, context.Canceled) {
@@ -373,80 +734,282 @@ func SortFileJSONCtx(ctx context.Context, params map[string]string) resp.Result
 	type out struct {
 		File       string `json:"file"`
 		Algo       string `json:"algo"`
+		AlgoReason string `json:"algo_reason"`
+		Order      string `json:"order"`
+		KeyType    string `json:"keytype"`
 		SortedFile string `json:"sorted_file"`
 		Chunks     int    `json:"chunks"`
+		Unique     *int   `json:"unique,omitempty"` // sólo con dedup
 		BytesIn    int64  `json:"bytes_in"`
 		BytesOut   int64  `json:"bytes_out"`
 		ElapsedMS  int64  `json:"elapsed_ms"`
 	}
-	b, _ := json.Marshal(out{
-		File: base, Algo: algo, SortedFile: filepath.Base(outPath),
+	o := out{
+		File: base, Algo: algo, AlgoReason: reason, Order: opts.orderName(), KeyType: opts.keyName(),
+		SortedFile: filepath.Base(outPath),
 		Chunks: chunks, BytesIn: bytesIn, BytesOut: bytesOut,
 		ElapsedMS: time.Since(start).Milliseconds(),
-	})
+	}
+	if opts.dedup {
+		o.Unique = &emitted
+	}
+	b, _ := json.Marshal(o)
+	return resp.JSONOK(string(b))
+}
+
+// merge-only: cada chunk de names= ya está ordenado, se fusionan con kWayMergeCtx
+func sortPremergedCtx(ctx context.Context, params map[string]string) resp.Result {
+	raw := params["names"]
+	if raw == "" {
+		return resp.BadReq("names", "names required with premerged=true")
+	}
+	var parts []string
+	var bases []string
+	var infos []os.FileInfo
+	var bytesIn int64
+	for _, n := range strings.Split(raw, ",") {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		base, ok := sanitize(n)
+		if !ok {
+			return resp.BadReq("bad_name", "invalid chunk name: "+n)
+		}
+		p := filepath.Join(dataDir, base)
+		info, err := os.Stat(p)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return resp.NotFound("not_found", "chunk does not exist: "+base)
+			}
+			return resp.IntErr("fs_error", "stat failed")
+		}
+		bytesIn += info.Size()
+		parts = append(parts, p)
+		bases = append(bases, base)
+		infos = append(infos, info)
+	}
+	if len(parts) == 0 {
+		return resp.BadReq("names", "names required with premerged=true")
+	}
+	opts, bad, ok := parseSortOpts(params)
+	if !ok {
+		return bad
+	}
+
+	outBase := bases[0]
+	if n := params["name"]; n != "" {
+		b, ok := sanitize(n)
+		if !ok {
+			return resp.BadReq("bad_name", "invalid file name")
+		}
+		outBase = b
+	}
+	outPath := filepath.Join(dataDir, outBase) + ".sorted"
+	// os.Create vaciaría un chunk que todavía se está leyendo
+	if outInfo, err := os.Stat(outPath); err == nil {
+		for _, info := range infos {
+			if os.SameFile(info, outInfo) {
+				return resp.Conflict("output_is_input", "output would overwrite input: "+filepath.Base(outPath))
+			}
+		}
+	}
+
+	unlock, ok := lockFile(ctx, outPath)
+	if !ok {
+		return ctxErrResult(ctx)
+	}
+	defer unlock()
+
+	start := time.Now()
+	emitted, err := kWayMergeCtx(ctx, parts, outPath, opts)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return ctxErrResult(ctx)
+		}
+		return resp.IntErr("sort_error", err.Error())
+	}
+	outInfo, _ := os.Stat(outPath)
+	var bytesOut int64
+	if outInfo != nil {
+		bytesOut = outInfo.Size()
+	}
+
+	type out struct {
+		File       string   `json:"file"`
+		Algo       string   `json:"algo"`
+		Names      []string `json:"names"`
+		SortedFile string   `json:"sorted_file"`
+		Chunks     int      `json:"chunks"`
+		Unique     *int     `json:"unique,omitempty"` // sólo con dedup
+		BytesIn    int64    `json:"bytes_in"`
+		BytesOut   int64    `json:"bytes_out"`
+		ElapsedMS  int64    `json:"elapsed_ms"`
+	}
+	o := out{
+		File: outBase, Algo: "premerged", Names: bases,
+		SortedFile: filepath.Base(outPath), Chunks: len(parts),
+		BytesIn: bytesIn, BytesOut: bytesOut,
+		ElapsedMS: time.Since(start).Milliseconds(),
+	}
+	if opts.dedup {
+		o.Unique = &emitted
+	}
+	b, _ := json.Marshal(o)
 	return resp.JSONOK(string(b))
 }
 
+// sortOpts son las opciones de orden compartidas por quick, merge y el k-way.
+type sortOpts struct {
+	desc  bool // order=desc
+	str   bool // keytype=string: orden lexicográfico, sin ParseInt
+	dedup bool // dedup=1: omite valores repetidos al escribir
+}
+
+// parseSortOpts valida order=asc|desc y keytype=int|string.
+func parseSortOpts(params map[string]string) (sortOpts, resp.Result, bool) {
+	var o sortOpts
+	switch params["order"] {
+	case "", "asc":
+	case "desc":
+		o.desc = true
+	default:
+		return o, resp.BadReq("order", "order must be asc|desc"), false
+	}
+	switch params["keytype"] {
+	case "", "int":
+	case "string":
+		o.str = true
+	default:
+		return o, resp.BadReq("keytype", "keytype must be int|string"), false
+	}
+	dedup, ok := optBool(params["dedup"])
+	if !ok {
+		return o, resp.BadReq("dedup", "dedup must be true|false"), false
+	}
+	o.dedup = dedup
+	return o, resp.Result{}, true
+}
+
+func (o sortOpts) orderName() string {
+	if o.desc {
+		return "desc"
+	}
+	return "asc"
+}
+
+func (o sortOpts) keyName() string {
+	if o.str {
+		return "string"
+	}
+	return "int"
+}
+
+// sortKey guarda la clave según keytype (sólo uno de los campos se usa).
+type sortKey struct {
+	n int64
+	s string
+}
+
+// parse convierte una línea en clave. skip=true para líneas vacías en modo int.
+func (o sortOpts) parse(b []byte) (k sortKey, skip bool, err error) {
+	if o.str {
+		// Quitar BOM y \r (CRLF); el resto de la línea se compara tal cual.
+		if len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF {
+			b = b[3:]
+		}
+		return sortKey{s: strings.TrimSuffix(string(b), "\r")}, false, nil
+	}
+	s := cleanIntLine(b)
+	if s == "" {
+		return k, true, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return k, false, fmt.Errorf("parse int: %w", err)
+	}
+	return sortKey{n: n}, false, nil
+}
+
+func (o sortOpts) less(a, b sortKey) bool {
+	if o.str {
+		if o.desc {
+			return a.s > b.s
+		}
+		return a.s < b.s
+	}
+	if o.desc {
+		return a.n > b.n
+	}
+	return a.n < b.n
+}
+
+func (o sortOpts) format(k sortKey) string {
+	if o.str {
+		return k.s + "\n"
+	}
+	return strconv.FormatInt(k.n, 10) + "\n"
+}
+
 // sort en memoria (rápido si cabe en RAM)
-func sortInMemoryCtx(ctx context.Context, inPath, outPath string) (int, error) {
+func sortInMemoryCtx(ctx context.Context, inPath, outPath string, opts sortOpts) (int, int, error) {
 	f, err := os.Open(inPath)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 	defer f.Close()
 
-	var nums []int64
+	var keys []sortKey
 	sc := bufio.NewScanner(f)
 	sc.Buffer(make([]byte, 0, 1<<20), 1<<20)
 
 	i := 0
 	for sc.Scan() {
 		if i&(checkEvery-1) == 0 && canceled(ctx) {
-			return 0, context.Canceled
+			return 0, 0, context.Canceled
 		}
 		i++
 
-		s := cleanIntLine(sc.Bytes())
-		if s == "" {
-			continue
-		}
-		n, err := strconv.ParseInt(s, 10, 64)
+		k, skip, err := opts.parse(sc.Bytes())
 		if err != nil {
-			return 0, fmt.Errorf("parse int: %w", err)
+			return 0, 0, err
+		}
+		if skip {
+			continue
 		}
-		nums = append(nums, n)
+		keys = append(keys, k)
 	}
 	if err := sc.Err(); err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
-	sort.Slice(nums, func(i, j int) bool { return nums[i] < nums[j] })
+	sort.Slice(keys, func(i, j int) bool { return opts.less(keys[i], keys[j]) })
 
 	out, err := os.Create(outPath)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 	defer out.Close()
-	bw := bufio.NewWriterSize(out, 1<<20)
-	for _, v := range nums {
+	sw := newSortWriter(out, opts)
+	for _, v := range keys {
 		if canceled(ctx) {
-			return 0, context.Canceled
+			return 0, 0, context.Canceled
 		}
-		if _, err := bw.WriteString(strconv.FormatInt(v, 10) + "\n"); err != nil {
-			return 0, err
+		if err := sw.write(v); err != nil {
+			return 0, 0, err
 		}
 	}
-	if err := bw.Flush(); err != nil {
-		return 0, err
+	if err := sw.bw.Flush(); err != nil {
+		return 0, 0, err
 	}
-	return 1, nil // un solo "chunk" lógico
+	return 1, sw.n, nil // un solo "chunk" lógico
 }
 
 // external sort (divide y fusiona k-way)
-func externalSortCtx(ctx context.Context, inPath, outPath string, chunkLines int) (int, error) {
+func externalSortCtx(ctx context.Context, inPath, outPath string, chunkLines int, opts sortOpts) (int, int, error) {
 	in, err := os.Open(inPath)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 	defer in.Close()
 
@@ -454,156 +1017,166 @@ func externalSortCtx(ctx context.Context, inPath, outPath string, chunkLines int
 	sc := bufio.NewScanner(in)
 	sc.Buffer(make([]byte, 0, 4<<20), 4<<20)
 
-	nums := make([]int64, 0, chunkLines)
+	keys := make([]sortKey, 0, chunkLines)
 
+	lastN := 0 // líneas emitidas por el último chunk (caso de chunk único)
 	writeChunk := func() (string, error) {
-		if len(nums) == 0 {
+		if len(keys) == 0 {
 			return "", nil
 		}
-		sort.Slice(nums, func(i, j int) bool { return nums[i] < nums[j] })
+		sort.Slice(keys, func(i, j int) bool { return opts.less(keys[i], keys[j]) })
 
 		tmp, err := os.CreateTemp(dataDir, "sortchunk-*")
 		if err != nil {
 			return "", err
 		}
-		bw := bufio.NewWriterSize(tmp, 1<<20)
-		for _, v := range nums {
+		sw := newSortWriter(tmp, opts)
+		for _, v := range keys {
 			if canceled(ctx) {
 				tmp.Close()
 				return "", context.Canceled
 			}
-			if _, err := bw.WriteString(strconv.FormatInt(v, 10) + "\n"); err != nil {
+			if err := sw.write(v); err != nil {
 				tmp.Close()
 				return "", err
 			}
 		}
-		if err := bw.Flush(); err != nil {
+		if err := sw.bw.Flush(); err != nil {
 			tmp.Close()
 			return "", err
 		}
 		tmp.Close()
+		lastN = sw.n
 		name := tmp.Name()
 		chunkFiles = append(chunkFiles, name)
-		nums = nums[:0]
+		keys = keys[:0]
 		return name, nil
 	}
 
 	i := 0
 	for sc.Scan() {
 		if i&(checkEvery-1) == 0 && canceled(ctx) {
-			return 0, context.Canceled
+			return 0, 0, context.Canceled
 		}
 		i++
 
-		s := cleanIntLine(sc.Bytes())
-		if s == "" {
-			continue
-		}
-		n, err := strconv.ParseInt(s, 10, 64)
+		k, skip, err := opts.parse(sc.Bytes())
 		if err != nil {
-			return 0, fmt.Errorf("parse int: %w", err)
+			return 0, 0, err
+		}
+		if skip {
+			continue
 		}
-		nums = append(nums, n)
-		if len(nums) >= chunkLines {
+		keys = append(keys, k)
+		if len(keys) >= chunkLines {
 			if _, err := writeChunk(); err != nil {
-				return 0, err
+				return 0, 0, err
 			}
 		}
 	}
 	if err := sc.Err(); err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 	if _, err := writeChunk(); err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
 	// si hubo un único chunk, renómbralo
 	if len(chunkFiles) == 1 {
-		return 1, os.Rename(chunkFiles[0], outPath)
+		return 1, lastN, os.Rename(chunkFiles[0], outPath)
 	}
 
-	err = kWayMergeCtx(ctx, chunkFiles, outPath)
+	emitted, err := kWayMergeCtx(ctx, chunkFiles, outPath, opts)
 
 	// limpia temporales
 	for _, p := range chunkFiles {
 		_ = os.Remove(p)
 	}
 	if err != nil {
-		return len(chunkFiles), err
+		return len(chunkFiles), 0, err
 	}
-	return len(chunkFiles), nil
+	return len(chunkFiles), emitted, nil
 }
 
 /*
    ===============================================================
-   k-way merge (min-heap)
+   k-way merge (min-heap; con order=desc el heap entrega el mayor)
    ===============================================================
 */
 
 type chunkReader struct {
 	f   *os.File
 	sc  *bufio.Scanner
-	val int64
+	val sortKey
 	eof bool
 }
 
+// next avanza hasta la próxima clave válida (saltando vacías en modo int).
+func (r *chunkReader) next(opts sortOpts) (bool, error) {
+	for r.sc.Scan() {
+		k, skip, err := opts.parse(r.sc.Bytes())
+		if err != nil {
+			return false, err
+		}
+		if skip {
+			continue
+		}
+		r.val = k
+		return true, nil
+	}
+	return false, r.sc.Err()
+}
+
 type minItem struct {
-	val int64
+	val sortKey
 	idx int
 }
 
-type minHeap []minItem
+type minHeap struct {
+	items []minItem
+	opts  sortOpts
+}
 
-func (h minHeap) Len() int           { return len(h) }
-func (h minHeap) Less(i, j int) bool { return h[i].val < h[j].val }
-func (h minHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
-func (h *minHeap) Push(x any)        { *h = append(*h, x.(minItem)) }
+func (h minHeap) Len() int           { return len(h.items) }
+func (h minHeap) Less(i, j int) bool { return h.opts.less(h.items[i].val, h.items[j].val) }
+func (h minHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *minHeap) Push(x any)        { h.items = append(h.items, x.(minItem)) }
 func (h *minHeap) Pop() any {
-	old := *h
+	old := h.items
 	n := len(old)
 	x := old[n-1]
-	*h = old[:n-1]
+	h.items = old[:n-1]
 	return x
 }
 
-func kWayMergeCtx(ctx context.Context, parts []string, outPath string) error {
+func kWayMergeCtx(ctx context.Context, parts []string, outPath string, opts sortOpts) (int, error) {
 	if len(parts) == 0 {
-		return errors.New("no chunks")
+		return 0, errors.New("no chunks")
+	}
+	readers := make([]*chunkReader, 0, len(parts))
+	closeAll := func() {
+		for _, r := range readers {
+			_ = r.f.Close()
+		}
 	}
-	readers := make([]*chunkReader, len(parts))
-	h := &minHeap{}
+	defer closeAll()
+	h := &minHeap{opts: opts}
 	heap.Init(h)
 
 	for i, p := range parts {
 		f, err := os.Open(p)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		sc := bufio.NewScanner(f)
 		sc.Buffer(make([]byte, 0, 1<<20), 1<<20)
 		cr := &chunkReader{f: f, sc: sc}
-		if cr.sc.Scan() {
-			s := cleanIntLine(cr.sc.Bytes())
-			for s == "" && cr.sc.Scan() {
-				s = cleanIntLine(cr.sc.Bytes())
-			}
-			if s != "" {
-				v, err := strconv.ParseInt(s, 10, 64)
-				if err != nil {
-					f.Close()
-					return err
-				}
-				cr.val = v
-			} else {
-				cr.eof = true
-			}
-		} else if err := cr.sc.Err(); err != nil {
-			f.Close()
-			return err
-		} else {
-			cr.eof = true
+		readers = append(readers, cr)
+		ok, err := cr.next(opts)
+		if err != nil {
+			return 0, err
 		}
-		readers[i] = cr
+		cr.eof = !ok
 		if !cr.eof {
 			heap.Push(h, minItem{val: cr.val, idx: i})
 		}
@@ -611,68 +1184,124 @@ func kWayMergeCtx(ctx context.Context, parts []string, outPath string) error {
 
 	out, err := os.Create(outPath)
 	if err != nil {
-		for _, r := range readers {
-			_ = r.f.Close()
-		}
-		return err
+		return 0, err
 	}
 	defer out.Close()
-	bw := bufio.NewWriterSize(out, 1<<20)
+	// el heap entrega en orden, así que dedup compara contra el último
+	// valor escrito aunque venga de otro chunk.
+	sw := newSortWriter(out, opts)
 
 	step := 0
 	for h.Len() > 0 {
 		if step&(checkEvery-1) == 0 && canceled(ctx) {
-			return context.Canceled
+			return 0, context.Canceled
 		}
 		step++
 
 		it := heap.Pop(h).(minItem)
 		idx := it.idx
-		if _, err := bw.WriteString(strconv.FormatInt(it.val, 10) + "\n"); err != nil {
-			return err
+		if err := sw.write(it.val); err != nil {
+			return 0, err
 		}
 		// avanza ese reader
 		r := readers[idx]
-		if r.sc.Scan() {
-			s := cleanIntLine(r.sc.Bytes())
-			for s == "" && r.sc.Scan() {
-				s = cleanIntLine(r.sc.Bytes())
-			}
-			if s != "" {
-				v, err := strconv.ParseInt(s, 10, 64)
-				if err != nil {
-					return err
-				}
-				r.val = v
-				heap.Push(h, minItem{val: r.val, idx: idx})
-			}
-		} else if err := r.sc.Err(); err != nil {
-			return err
+		ok, err := r.next(opts)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			heap.Push(h, minItem{val: r.val, idx: idx})
+		} else {
+			r.eof = true
 		}
 	}
 
-	if err := bw.Flush(); err != nil {
-		return err
+	return sw.n, sw.bw.Flush()
+}
+
+// sortWriter escribe claves ya ordenadas; con dedup omite las iguales a la
+// anterior. n cuenta las líneas emitidas.
+type sortWriter struct {
+	bw   *bufio.Writer
+	opts sortOpts
+	last sortKey
+	has  bool
+	n    int
+}
+
+func newSortWriter(f *os.File, opts sortOpts) *sortWriter {
+	return &sortWriter{bw: bufio.NewWriterSize(f, 1<<20), opts: opts}
+}
+
+func (w *sortWriter) write(k sortKey) error {
+	if w.opts.dedup && w.has && k == w.last {
+		return nil
 	}
-	for _, r := range readers {
-		_ = r.f.Close()
+	if _, err := w.bw.WriteString(w.opts.format(k)); err != nil {
+		return err
 	}
+	w.last, w.has = k, true
+	w.n++
 	return nil
 }
 
 /*
    ===============================================================
-   /compress?name=FILE&codec=gzip|xz
+   /compress?name=FILE&codec=gzip|xz|zstd[&skip_if_incompressible=true]
    - gzip: usa librería estándar.
    - xz: invoca binario del sistema `xz` (requiere xz-utils).
+   - zstd: invoca binario del sistema `zstd` (salida FILE.zst).
+   - skip_if_incompressible=true: comprime de prueba el primer bloque
+     (compressSampleSize) y, si no baja de compressSkipRatio, no genera
+     salida y responde {"skipped":true,"reason":"incompressible",...}.
    Respuesta (orden estable):
-     {"file":..., "codec":"gzip|xz", "output":..., "bytes_in":N,
+     {"file":..., "codec":"gzip|xz|zstd", "output":..., "bytes_in":N,
       "bytes_out":N, "elapsed_ms":N}
    ===============================================================
 */
 
-func CompressJSON(params map[string]string) resp.Result {
-	return CompressJSONCtx(context.Background(), params)
+// Muestreo de compresibilidad: bloque inicial que se prueba, ratio
+// (salida/entrada) a partir del cual se considera incompresible y tamaño
+// mínimo de muestra (con menos, la cabecera de gzip distorsiona el ratio).
+const (
+	compressSampleSize = 64 << 10
+	compressSkipRatio  = 0.95
+	compressSampleMin  = 4 << 10
+)
+
+// byteCounter es un io.Writer que sólo cuenta bytes.
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+// sampleRatio comprime con gzip (BestSpeed) el primer bloque del archivo y
+// devuelve salida/entrada y el tamaño de la muestra.
+func sampleRatio(path string) (float64, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	buf := make([]byte, compressSampleSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return 0, 0, err
+	}
+	if n == 0 {
+		return 0, 0, nil
+	}
+	var out byteCounter
+	zw, _ := gzip.NewWriterLevel(&out, gzip.BestSpeed)
+	_, _ = zw.Write(buf[:n])
+	_ = zw.Close()
+	return float64(out) / float64(n), n, nil
+}
+
+func CompressJSON(params map[string]string) resp.Result {
+	return CompressJSONCtx(context.Background(), params)
 }
 
 func CompressJSONCtx(ctx context.Context, params map[string]string) resp.Result {
@@ -689,8 +1318,12 @@ func CompressJSONCtx(ctx context.Context, params map[string]string) resp.Result
 	if codec == "" {
 		codec = "gzip"
 	}
-	if codec != "gzip" && codec != "xz" {
-		return resp.BadReq("codec", "codec must be gzip|xz")
+	if codec != "gzip" && codec != "xz" && codec != "zstd" {
+		return resp.BadReq("codec", "codec must be gzip|xz|zstd")
+	}
+	skipIncomp, ok := optBool(params["skip_if_incompressible"])
+	if !ok {
+		return resp.BadReq("skip_if_incompressible", "skip_if_incompressible must be true|false")
 	}
 
 	inPath := filepath.Join(dataDir, base)
@@ -705,6 +1338,32 @@ func CompressJSONCtx(ctx context.Context, params map[string]string) resp.Result
 
 	start := time.Now()
 
+	if skipIncomp {
+		ratio, n, err := sampleRatio(inPath)
+		if err != nil {
+			return resp.IntErr("fs_error", "sample read failed")
+		}
+		if n >= compressSampleMin && ratio >= compressSkipRatio {
+			b, _ := json.Marshal(struct {
+				File        string  `json:"file"`
+				Codec       string  `json:"codec"`
+				Skipped     bool    `json:"skipped"`
+				Reason      string  `json:"reason"`
+				BytesIn     int64   `json:"bytes_in"`
+				SampleRatio float64 `json:"sample_ratio"`
+				ElapsedMS   int64   `json:"elapsed_ms"`
+			}{base, codec, true, "incompressible", bytesIn, math.Round(ratio*1000) / 1000, time.Since(start).Milliseconds()})
+			return resp.JSONOK(string(b))
+		}
+	}
+
+	outSuffix := map[string]string{"gzip": ".gz", "xz": ".xz", "zstd": ".zst"}[codec]
+	unlock, ok := lockFile(ctx, inPath+outSuffix)
+	if !ok {
+		return ctxErrResult(ctx)
+	}
+	defer unlock()
+
 	// Estructura común para salida (mantiene orden estable de campos)
 	type compressOut struct {
 		File      string `json:"file"`
@@ -809,8 +1468,795 @@ func CompressJSONCtx(ctx context.Context, params map[string]string) resp.Result
 		}
 		b, _ := json.Marshal(body)
 		return resp.JSONOK(string(b))
+
+	case "zstd":
+		// Igual que xz pero con el binario `zstd`:
+		//  -T0 : usa todos los hilos
+		//  -k  : conserva el archivo original
+		//  -f  : sobrescribe si existe
+		//  -q  : sin barra de progreso
+		cmd := exec.CommandContext(ctx, "zstd", "-T0", "-k", "-f", "-q", inPath)
+		if err := cmd.Run(); err != nil {
+			if ctx != nil && ctx.Err() != nil { // cancelación/timeout
+				return ctxErrResult(ctx)
+			}
+			return resp.IntErr("compress_error", err.Error())
+		}
+
+		outPath := inPath + ".zst"
+		outInfo, _ := os.Stat(outPath)
+		var bytesOut int64
+		if outInfo != nil {
+			bytesOut = outInfo.Size()
+		}
+
+		body := compressOut{
+			File:      base,
+			Codec:     "zstd",
+			Output:    filepath.Base(outPath),
+			BytesIn:   bytesIn,
+			BytesOut:  bytesOut,
+			ElapsedMS: time.Since(start).Milliseconds(),
+		}
+		b, _ := json.Marshal(body)
+		return resp.JSONOK(string(b))
 	}
 
 	// No debería ejecutarse.
 	return resp.IntErr("codec", "unsupported codec")
 }
+
+/*
+   ===============================================================
+   /compress/probe?name=FILE[&codec=gzip]
+   - Comprime en memoria una muestra inicial del archivo (hasta
+     probeSampleSize) con varios niveles (probeLevels) y reporta
+     ratio (salida/entrada) y tiempo de cada uno, sin escribir salidas.
+   - Sólo gzip: xz/zstd son binarios externos y no se muestrean.
+   Respuesta (orden estable):
+     {"file":..., "codec":"gzip", "bytes_in":N, "sample_bytes":N,
+      "levels":[{"level":1,"bytes_out":N,"ratio":x,"elapsed_ms":x}, ...],
+      "elapsed_ms":N}
+   ===============================================================
+*/
+
+// Muestra de /compress/probe y niveles de gzip que se comparan.
+const probeSampleSize = 1 << 20
+
+var probeLevels = []int{gzip.BestSpeed, 5, gzip.BestCompression}
+
+func CompressProbeJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	name := params["name"]
+	if name == "" {
+		return resp.BadReq("name", "file name required")
+	}
+	base, ok := sanitize(name)
+	if !ok {
+		return resp.BadReq("bad_name", "invalid file name")
+	}
+	codec := params["codec"]
+	if codec == "" {
+		codec = "gzip"
+	}
+	if codec != "gzip" {
+		return resp.BadReq("codec", "probe supports codec=gzip only")
+	}
+
+	inPath := filepath.Join(dataDir, base)
+	f, err := os.Open(inPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return resp.NotFound("not_found", "file does not exist")
+		}
+		return resp.IntErr("fs_error", "open failed")
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return resp.IntErr("fs_error", "stat failed")
+	}
+
+	start := time.Now()
+	buf := make([]byte, probeSampleSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return resp.IntErr("fs_error", "sample read failed")
+	}
+	sample := buf[:n]
+
+	type levelOut struct {
+		Level     int     `json:"level"`
+		BytesOut  int64   `json:"bytes_out"`
+		Ratio     float64 `json:"ratio"`
+		ElapsedMS float64 `json:"elapsed_ms"`
+	}
+	levels := make([]levelOut, 0, len(probeLevels))
+	for _, lvl := range probeLevels {
+		if canceled(ctx) {
+			return ctxErrResult(ctx)
+		}
+		t0 := time.Now()
+		var out byteCounter
+		zw, _ := gzip.NewWriterLevel(&out, lvl)
+		_, _ = zw.Write(sample)
+		_ = zw.Close()
+		ratio := 0.0
+		if n > 0 {
+			ratio = math.Round(float64(out)/float64(n)*1000) / 1000
+		}
+		el := float64(time.Since(t0).Microseconds()) / 1000
+		levels = append(levels, levelOut{lvl, int64(out), ratio, el})
+	}
+
+	b, _ := json.Marshal(struct {
+		File        string     `json:"file"`
+		Codec       string     `json:"codec"`
+		BytesIn     int64      `json:"bytes_in"`
+		SampleBytes int        `json:"sample_bytes"`
+		Levels      []levelOut `json:"levels"`
+		ElapsedMS   int64      `json:"elapsed_ms"`
+	}{base, codec, info.Size(), n, levels, time.Since(start).Milliseconds()})
+	return resp.JSONOK(string(b))
+}
+
+/*
+   ===============================================================
+   /decompress?name=FILE.gz|FILE.xz[&codec=gzip|xz]
+   - Inverso de /compress: escribe la salida junto al archivo, quitando
+     el sufijo (.gz / .xz). Si no se indica codec se infiere del sufijo.
+   - gzip: librería estándar con sonda de cancelación por bloque.
+   - xz: binario del sistema `xz -d` (requiere xz-utils).
+   Respuesta (orden estable):
+     {"file":..., "codec":"gzip|xz", "output":..., "bytes_in":N,
+      "bytes_out":N, "elapsed_ms":N}
+   ===============================================================
+*/
+
+func DecompressJSON(params map[string]string) resp.Result {
+	return DecompressJSONCtx(context.Background(), params)
+}
+
+func DecompressJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	name := params["name"]
+	if name == "" {
+		return resp.BadReq("name", "file name required")
+	}
+	base, ok := sanitize(name)
+	if !ok {
+		return resp.BadReq("bad_name", "invalid file name")
+	}
+
+	suffixes := map[string]string{"gzip": ".gz", "xz": ".xz"}
+	codec := params["codec"]
+	if codec == "" {
+		switch {
+		case strings.HasSuffix(base, ".gz"):
+			codec = "gzip"
+		case strings.HasSuffix(base, ".xz"):
+			codec = "xz"
+		}
+	}
+	suffix, ok := suffixes[codec]
+	if !ok {
+		if params["codec"] == "" {
+			return resp.BadReq("name", "name must end in .gz or .xz")
+		}
+		return resp.BadReq("codec", "codec must be gzip|xz")
+	}
+	if !strings.HasSuffix(base, suffix) || len(base) == len(suffix) {
+		return resp.BadReq("name", "name must end in "+suffix+" for codec="+codec)
+	}
+
+	inPath := filepath.Join(dataDir, base)
+	outPath := strings.TrimSuffix(inPath, suffix)
+	info, err := os.Stat(inPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return resp.NotFound("not_found", "file does not exist")
+		}
+		return resp.IntErr("fs_error", "stat failed")
+	}
+	bytesIn := info.Size()
+
+	unlock, ok := lockFile(ctx, outPath)
+	if !ok {
+		return ctxErrResult(ctx)
+	}
+	defer unlock()
+
+	start := time.Now()
+
+	switch codec {
+	case "gzip":
+		in, err := os.Open(inPath)
+		if err != nil {
+			return resp.IntErr("fs_error", "open failed")
+		}
+		defer in.Close()
+
+		zr, err := gzip.NewReader(in)
+		if err != nil {
+			return resp.IntErr("decompress_error", err.Error())
+		}
+		defer zr.Close()
+
+		fOut, err := os.Create(outPath) // trunca si existe
+		if err != nil {
+			return resp.IntErr("fs_error", "create failed")
+		}
+		defer fOut.Close()
+
+		buf := make([]byte, 1<<20) // 1 MiB
+		for {
+			if canceled(ctx) {
+				return ctxErrResult(ctx)
+			}
+			n, rerr := zr.Read(buf)
+			if n > 0 {
+				if _, werr := fOut.Write(buf[:n]); werr != nil {
+					return resp.IntErr("fs_error", werr.Error())
+				}
+			}
+			if rerr == io.EOF {
+				break
+			}
+			if rerr != nil {
+				return resp.IntErr("decompress_error", rerr.Error())
+			}
+		}
+
+	case "xz":
+		//  -d : descomprimir
+		//  -k : conserva el .xz
+		//  -f : sobrescribe la salida si existe
+		cmd := exec.CommandContext(ctx, "xz", "-d", "-k", "-f", inPath)
+		if err := cmd.Run(); err != nil {
+			if ctx != nil && ctx.Err() != nil { // cancelación/timeout
+				return ctxErrResult(ctx)
+			}
+			return resp.IntErr("decompress_error", err.Error())
+		}
+	}
+
+	outInfo, _ := os.Stat(outPath)
+	var bytesOut int64
+	if outInfo != nil {
+		bytesOut = outInfo.Size()
+	}
+
+	type out struct {
+		File      string `json:"file"`
+		Codec     string `json:"codec"`
+		Output    string `json:"output"`
+		BytesIn   int64  `json:"bytes_in"`
+		BytesOut  int64  `json:"bytes_out"`
+		ElapsedMS int64  `json:"elapsed_ms"`
+	}
+	b, _ := json.Marshal(out{
+		File: base, Codec: codec, Output: filepath.Base(outPath),
+		BytesIn: bytesIn, BytesOut: bytesOut,
+		ElapsedMS: time.Since(start).Milliseconds(),
+	})
+	return resp.JSONOK(string(b))
+}
+
+/*
+   ===============================================================
+   /readfile?name=FILE[&offset=N][&max_bytes=N]
+   - Devuelve hasta max_bytes (default 64 KiB, máx 1 MiB) desde offset.
+   - Sólo texto: si el tramo leído contiene NUL → 400 "binary".
+   Respuesta (orden estable):
+     {"file":..., "offset":N, "bytes_read":N, "truncated":bool,
+      "content":"..."}
+   ===============================================================
+*/
+
+const (
+	readDefaultBytes = 64 << 10
+	readMaxBytes     = 1 << 20
+)
+
+func ReadFileJSON(params map[string]string) resp.Result {
+	return ReadFileJSONCtx(context.Background(), params)
+}
+
+func ReadFileJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	name := params["name"]
+	if name == "" {
+		return resp.BadReq("name", "file name required")
+	}
+	base, ok := sanitize(name)
+	if !ok {
+		return resp.BadReq("bad_name", "invalid file name")
+	}
+	var offset int64
+	if v := params["offset"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return resp.BadReq("offset", "offset must be integer >= 0")
+		}
+		offset = n
+	}
+	maxBytes := readDefaultBytes
+	if v := params["max_bytes"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return resp.BadReq("max_bytes", "max_bytes must be integer >= 1")
+		}
+		if n > readMaxBytes {
+			n = readMaxBytes
+		}
+		maxBytes = n
+	}
+
+	fp := filepath.Join(dataDir, base)
+	f, err := os.Open(fp)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return resp.NotFound("not_found", "file does not exist")
+		}
+		return resp.IntErr("fs_error", "open failed")
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return resp.IntErr("fs_error", "stat failed")
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return resp.IntErr("fs_error", "seek failed")
+	}
+
+	buf := make([]byte, maxBytes)
+	got := 0
+	for got < maxBytes {
+		if canceled(ctx) {
+			return ctxErrResult(ctx)
+		}
+		end := got + 32<<10 // bloques de 32 KiB entre sondas de cancelación
+		if end > maxBytes {
+			end = maxBytes
+		}
+		n, rerr := f.Read(buf[got:end])
+		got += n
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return resp.IntErr("fs_error", "read failed")
+		}
+	}
+	buf = buf[:got]
+	if bytes.IndexByte(buf, 0) >= 0 {
+		return resp.BadReq("binary", "file contains NUL bytes")
+	}
+
+	type out struct {
+		File      string `json:"file"`
+		Offset    int64  `json:"offset"`
+		BytesRead int    `json:"bytes_read"`
+		Truncated bool   `json:"truncated"`
+		Content   string `json:"content"`
+	}
+	b, _ := json.Marshal(out{
+		File: base, Offset: offset, BytesRead: got,
+		Truncated: offset+int64(got) < info.Size(),
+		Content:   string(buf),
+	})
+	return resp.JSONOK(string(b))
+}
+
+/*
+   ===============================================================
+   /copyfile?from=FILE&to=FILE[&conflict=fail|overwrite|autorename]
+   - Copia en streaming (bloques de 1 MiB) con sonda de cancelación.
+   - conflict igual que /createfile: fail (409 + suggested_name),
+     overwrite, autorename (to(1).ext, to(2).ext, ...).
+   - overwrite sobre el mismo archivo (from == to) → 400 same_file.
+   - Si se cancela a mitad, se borra la copia parcial.
+   Respuesta (orden estable):
+     {"from":..., "to":..., "action":..., "bytes":N, "elapsed_ms":N}
+   ===============================================================
+*/
+
+func CopyFileJSON(params map[string]string) resp.Result {
+	return CopyFileJSONCtx(context.Background(), params)
+}
+
+func CopyFileJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	from, ok := sanitize(params["from"])
+	if !ok {
+		return resp.BadReq("bad_name", "invalid from name")
+	}
+	to, ok := sanitize(params["to"])
+	if !ok {
+		return resp.BadReq("bad_name", "invalid to name")
+	}
+	mode := params["conflict"]
+	if mode == "" {
+		mode = "fail"
+	}
+	if mode != "fail" && mode != "overwrite" && mode != "autorename" {
+		return resp.BadReq("conflict", "use conflict=fail|overwrite|autorename")
+	}
+
+	src := filepath.Join(dataDir, from)
+	in, err := os.Open(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return resp.NotFound("not_found", "from does not exist")
+		}
+		return resp.IntErr("fs_error", "open failed")
+	}
+	defer in.Close()
+	var total int64
+	srcInfo, err := in.Stat()
+	if err == nil {
+		total = srcInfo.Size()
+	}
+
+	action := "created"
+	dst := filepath.Join(dataDir, to)
+	unlock, ok := lockFile(ctx, dst)
+	if !ok {
+		return ctxErrResult(ctx)
+	}
+	defer unlock()
+	if dstInfo, err := os.Stat(dst); err == nil {
+		switch mode {
+		case "fail":
+			body := jsonNoEscape(map[string]any{
+				"error":          "exists",
+				"detail":         "file already exists",
+				"file":           to,
+				"suggested_name": suggestNameWithRules(to),
+			})
+			return resp.Result{Status: 409, Body: body, JSON: true}
+		case "autorename":
+			to = firstAvailableByRules(to)
+			dst = filepath.Join(dataDir, to)
+			action = "autorename"
+			unlockNew, ok := lockFile(ctx, dst)
+			if !ok {
+				return ctxErrResult(ctx)
+			}
+			defer unlockNew()
+		case "overwrite":
+			// os.Create truncaría el origen antes de leerlo
+			if srcInfo != nil && os.SameFile(srcInfo, dstInfo) {
+				return resp.BadReq("same_file", "from and to are the same file")
+			}
+			action = "overwritten"
+		}
+	}
+
+	start := time.Now()
+	out, err := os.Create(dst)
+	if err != nil {
+		return resp.IntErr("fs_error", "create failed")
+	}
+
+	var written int64
+	buf := make([]byte, 1<<20) // 1 MiB
+	for {
+		if canceled(ctx) {
+			out.Close()
+			_ = os.Remove(dst) // no dejar copias parciales
+			return ctxErrResult(ctx)
+		}
+		n, rerr := in.Read(buf)
+		if n > 0 {
+			if _, werr := out.Write(buf[:n]); werr != nil {
+				out.Close()
+				_ = os.Remove(dst)
+				return resp.IntErr("fs_error", werr.Error())
+			}
+			written += int64(n)
+			util.ReportProgress(ctx, written, total)
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			out.Close()
+			_ = os.Remove(dst)
+			return resp.IntErr("fs_error", "read error")
+		}
+	}
+	if err := out.Close(); err != nil {
+		return resp.IntErr("fs_error", "close failed")
+	}
+
+	type outT struct {
+		From      string `json:"from"`
+		To        string `json:"to"`
+		Action    string `json:"action"`
+		Bytes     int64  `json:"bytes"`
+		ElapsedMS int64  `json:"elapsed_ms"`
+	}
+	b, _ := json.Marshal(outT{
+		From: from, To: to, Action: action, Bytes: written,
+		ElapsedMS: time.Since(start).Milliseconds(),
+	})
+	return resp.JSONOK(string(b))
+}
+
+/*
+   ===============================================================
+   /archive?names=A,B,...[&name=OUT][&codec=gzip]
+   - Empaqueta los archivos listados en un único .tar.gz
+     (archive/tar + compress/gzip) dentro de dataDir.
+   - Cada nombre se sanitiza; si alguno no existe → 404 (no se crea nada).
+   - name=OUT: nombre de salida (se agrega .tar.gz si falta); por defecto
+     <primer archivo>.tar.gz. Si existe, se sobrescribe (como /compress),
+     salvo que sea una de las entradas → 409 output_is_input.
+   - Streaming en bloques de 1 MiB con sonda de cancelación; ante cancel o
+     error se borra el archivo parcial.
+   Respuesta (orden estable):
+     {"output":..., "files":[...], "bytes_in":N, "bytes_out":N, "elapsed_ms":N}
+   ===============================================================
+*/
+
+func ArchiveJSON(params map[string]string) resp.Result {
+	return ArchiveJSONCtx(context.Background(), params)
+}
+
+func ArchiveJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	codec := params["codec"]
+	if codec == "" {
+		codec = "gzip"
+	}
+	if codec != "gzip" {
+		return resp.BadReq("codec", "codec must be gzip")
+	}
+
+	var bases []string
+	var infos []os.FileInfo
+	var bytesIn int64
+	for _, n := range strings.Split(params["names"], ",") {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		base, ok := sanitize(n)
+		if !ok {
+			return resp.BadReq("bad_name", "invalid file name: "+n)
+		}
+		info, err := os.Stat(filepath.Join(dataDir, base))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return resp.NotFound("not_found", "file does not exist: "+base)
+			}
+			return resp.IntErr("fs_error", "stat failed")
+		}
+		if !info.Mode().IsRegular() {
+			return resp.BadReq("bad_name", "not a regular file: "+base)
+		}
+		bases = append(bases, base)
+		infos = append(infos, info)
+		bytesIn += info.Size()
+	}
+	if len(bases) == 0 {
+		return resp.BadReq("names", "names required")
+	}
+
+	outBase := bases[0]
+	if n := params["name"]; n != "" {
+		b, ok := sanitize(n)
+		if !ok {
+			return resp.BadReq("bad_name", "invalid file name")
+		}
+		outBase = b
+	}
+	if !strings.HasSuffix(outBase, ".tar.gz") {
+		outBase += ".tar.gz"
+	}
+	outPath := filepath.Join(dataDir, outBase)
+	// os.Create truncaría la entrada antes de empaquetarla
+	if outInfo, err := os.Stat(outPath); err == nil {
+		for _, info := range infos {
+			if os.SameFile(info, outInfo) {
+				return resp.Conflict("output_is_input", "output would overwrite input: "+outBase)
+			}
+		}
+	}
+
+	unlock, ok := lockFile(ctx, outPath)
+	if !ok {
+		return ctxErrResult(ctx)
+	}
+	defer unlock()
+
+	start := time.Now()
+	if err := writeTarGzCtx(ctx, outPath, bases, infos, bytesIn); err != nil {
+		_ = os.Remove(outPath) // no dejar archivos parciales
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return ctxErrResult(ctx)
+		}
+		return resp.IntErr("archive_error", err.Error())
+	}
+
+	var bytesOut int64
+	if info, err := os.Stat(outPath); err == nil {
+		bytesOut = info.Size()
+	}
+
+	type outT struct {
+		Output    string   `json:"output"`
+		Files     []string `json:"files"`
+		BytesIn   int64    `json:"bytes_in"`
+		BytesOut  int64    `json:"bytes_out"`
+		ElapsedMS int64    `json:"elapsed_ms"`
+	}
+	b, _ := json.Marshal(outT{
+		Output: outBase, Files: bases, BytesIn: bytesIn, BytesOut: bytesOut,
+		ElapsedMS: time.Since(start).Milliseconds(),
+	})
+	return resp.JSONOK(string(b))
+}
+
+// writeTarGzCtx escribe outPath como tar.gz con los archivos de dataDir
+// indicados. Devuelve ctx.Err() si se cancela a mitad de la copia.
+func writeTarGzCtx(ctx context.Context, outPath string, bases []string, infos []os.FileInfo, total int64) error {
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zw, err := gzip.NewWriterLevel(f, gzip.BestSpeed)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(zw)
+
+	var done int64
+	buf := make([]byte, 1<<20) // 1 MiB
+	for i, base := range bases {
+		hdr, err := tar.FileInfoHeader(infos[i], "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = base
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		in, err := os.Open(filepath.Join(dataDir, base))
+		if err != nil {
+			return err
+		}
+		// tar exige exactamente hdr.Size bytes por entrada
+		r := io.LimitReader(in, hdr.Size)
+		for {
+			if canceled(ctx) {
+				in.Close()
+				return ctx.Err()
+			}
+			n, rerr := r.Read(buf)
+			if n > 0 {
+				if _, werr := tw.Write(buf[:n]); werr != nil {
+					in.Close()
+					return werr
+				}
+				done += int64(n)
+				util.ReportProgress(ctx, done, total)
+			}
+			if rerr == io.EOF {
+				break
+			}
+			if rerr != nil {
+				in.Close()
+				return rerr
+			}
+		}
+		in.Close()
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+/*
+   ===============================================================
+   /head?name=FILE[&n=N]   y   /tail?name=FILE[&n=N]
+   - Primeras / últimas N líneas (default 10, máx headTailMaxN).
+   - head corta el scan al llegar a N; tail recorre el archivo una vez
+     guardando sólo las últimas N líneas en un ring (memoria O(N)).
+   - Si el archivo tiene menos de N líneas, devuelve todas.
+   Respuesta (orden estable):
+     {"file":..., "n":N, "lines":[...]}
+   ===============================================================
+*/
+
+// headTailMaxN acota n en /head y /tail.
+const headTailMaxN = 10000
+
+func HeadFileJSON(params map[string]string) resp.Result {
+	return HeadFileJSONCtx(context.Background(), params)
+}
+
+func TailFileJSON(params map[string]string) resp.Result {
+	return TailFileJSONCtx(context.Background(), params)
+}
+
+func HeadFileJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	return headTailCtx(ctx, params, false)
+}
+
+func TailFileJSONCtx(ctx context.Context, params map[string]string) resp.Result {
+	return headTailCtx(ctx, params, true)
+}
+
+func headTailCtx(ctx context.Context, params map[string]string, tail bool) resp.Result {
+	name := params["name"]
+	if name == "" {
+		return resp.BadReq("name", "file name required")
+	}
+	base, ok := sanitize(name)
+	if !ok {
+		return resp.BadReq("bad_name", "invalid file name")
+	}
+	n := 10
+	if v := params["n"]; v != "" {
+		x, err := strconv.Atoi(v)
+		if err != nil || x < 1 {
+			return resp.BadReq("n", "n must be integer >= 1")
+		}
+		if x > headTailMaxN {
+			x = headTailMaxN
+		}
+		n = x
+	}
+
+	f, err := os.Open(filepath.Join(dataDir, base))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return resp.NotFound("not_found", "file does not exist")
+		}
+		return resp.IntErr("fs_error", "open failed")
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	lines := make([]string, 0, n)
+	next := 0 // tail: posición del ring donde va la próxima línea
+	i := 0
+	for sc.Scan() {
+		if i&(checkEvery-1) == 0 && canceled(ctx) {
+			return ctxErrResult(ctx)
+		}
+		i++
+		if !tail {
+			lines = append(lines, sc.Text())
+			if len(lines) == n {
+				break
+			}
+			continue
+		}
+		if len(lines) < n {
+			lines = append(lines, sc.Text())
+		} else {
+			lines[next] = sc.Text()
+		}
+		next = (next + 1) % n
+	}
+	if err := sc.Err(); err != nil {
+		return resp.IntErr("fs_error", "scan error")
+	}
+	// ring lleno: rotar para que quede en orden de archivo
+	if tail && len(lines) == n && next != 0 {
+		rot := make([]string, 0, n)
+		rot = append(rot, lines[next:]...)
+		lines = append(rot, lines[:next]...)
+	}
+
+	type out struct {
+		File  string   `json:"file"`
+		N     int      `json:"n"`
+		Lines []string `json:"lines"`
+	}
+	b, _ := json.Marshal(out{File: base, N: n, Lines: lines})
+	return resp.JSONOK(string(b))
+}
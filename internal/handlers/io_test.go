@@ -1,19 +1,33 @@
 package handlers
 
 import (
+	"archive/tar"
 	"bufio"
+	"compress/gzip"
 	"context"
+	"crypto/md5"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"math/rand"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"so-http10-demo/internal/resp"
+	"so-http10-demo/internal/util"
 )
 
 /* ---------------- helpers (sin colisión con otros tests) ---------------- */
@@ -188,6 +202,126 @@ func TestWordCountJSON_Validation_And_NotFound(t *testing.T) {
 	}
 }
 
+func TestWordCountJSON_SkipBlank(t *testing.T) {
+	name := ioUnique("wc_blank", ".txt")
+	content := "uno dos\n\n   \ntres\n\t\ncuatro\n"
+	path := ioMustWrite(t, name, content)
+	defer os.Remove(path)
+
+	type out struct {
+		Lines int64 `json:"lines"`
+		Words int64 `json:"words"`
+		Bytes int64 `json:"bytes"`
+	}
+	all := mustJSONIO[out](t, WordCountJSON(map[string]string{"name": name}).Body)
+	if all.Lines != 6 || all.Words != 4 || all.Bytes != int64(len(content)) {
+		t.Fatalf("default: %+v", all)
+	}
+	nb := mustJSONIO[out](t, WordCountJSON(map[string]string{"name": name, "skip_blank": "true"}).Body)
+	if nb.Lines != 3 || nb.Words != all.Words || nb.Bytes != all.Bytes {
+		t.Fatalf("skip_blank: %+v (default %+v)", nb, all)
+	}
+	if r := WordCountJSON(map[string]string{"name": name, "skip_blank": "maybe"}); r.Status != 400 {
+		t.Fatalf("bad skip_blank -> 400: %+v", r)
+	}
+}
+
+func TestWordCountJSON_TopWords_TieBreak(t *testing.T) {
+	name := ioUnique("wc_top", ".txt")
+	// b:3, a:2, c:2, d:1, e:1 → empates por word asc
+	path := ioMustWrite(t, name, "b a c\n  b\tc d\nb a e")
+	defer os.Remove(path)
+
+	type wf struct {
+		Word  string `json:"word"`
+		Count int    `json:"count"`
+	}
+	type out struct {
+		Words    int64 `json:"words"`
+		TopWords []wf  `json:"top_words"`
+	}
+	r := WordCountJSON(map[string]string{"name": name, "top": "4"})
+	if r.Status != 200 {
+		t.Fatalf("wordcount top: %+v", r)
+	}
+	o := mustJSONIO[out](t, r.Body)
+	want := []wf{{"b", 3}, {"a", 2}, {"c", 2}, {"d", 1}}
+	if o.Words != 9 || len(o.TopWords) != len(want) {
+		t.Fatalf("payload: %+v", o)
+	}
+	for i := range want {
+		if o.TopWords[i] != want[i] {
+			t.Fatalf("top[%d]=%+v want %+v (all=%+v)", i, o.TopWords[i], want[i], o.TopWords)
+		}
+	}
+
+	// top mayor que el vocabulario: todos; sin top: no aparece el campo
+	if o := mustJSONIO[out](t, WordCountJSON(map[string]string{"name": name, "top": "1000"}).Body); len(o.TopWords) != 5 {
+		t.Fatalf("top>vocab: %+v", o.TopWords)
+	}
+	if r := WordCountJSON(map[string]string{"name": name}); strings.Contains(r.Body, "top_words") {
+		t.Fatalf("top_words must be opt-in: %s", r.Body)
+	}
+	if r := WordCountJSON(map[string]string{"name": name, "top": "x"}); r.Status != 400 {
+		t.Fatalf("bad top -> 400: %+v", r)
+	}
+}
+
+func TestHeadTailFileJSON_BothEnds(t *testing.T) {
+	name := ioUnique("headtail", ".txt")
+	var sb strings.Builder
+	for i := 1; i <= 25; i++ {
+		fmt.Fprintf(&sb, "l%d\n", i)
+	}
+	path := ioMustWrite(t, name, sb.String())
+	defer os.Remove(path)
+
+	type out struct {
+		File  string   `json:"file"`
+		N     int      `json:"n"`
+		Lines []string `json:"lines"`
+	}
+	h := mustJSONIO[out](t, HeadFileJSON(map[string]string{"name": name, "n": "3"}).Body)
+	if h.N != 3 || strings.Join(h.Lines, ",") != "l1,l2,l3" {
+		t.Fatalf("head: %+v", h)
+	}
+	tl := mustJSONIO[out](t, TailFileJSON(map[string]string{"name": name, "n": "4"}).Body)
+	if strings.Join(tl.Lines, ",") != "l22,l23,l24,l25" {
+		t.Fatalf("tail: %+v", tl)
+	}
+	// default n=10
+	if tl := mustJSONIO[out](t, TailFileJSON(map[string]string{"name": name}).Body); tl.N != 10 || len(tl.Lines) != 10 || tl.Lines[0] != "l16" {
+		t.Fatalf("tail default: %+v", tl)
+	}
+	// n mayor que el archivo: todas las líneas, en orden
+	for _, fn := range []func(map[string]string) resp.Result{HeadFileJSON, TailFileJSON} {
+		o := mustJSONIO[out](t, fn(map[string]string{"name": name, "n": "100"}).Body)
+		if len(o.Lines) != 25 || o.Lines[0] != "l1" || o.Lines[24] != "l25" {
+			t.Fatalf("n > lines: %+v", o)
+		}
+	}
+}
+
+func TestHeadTailFileJSON_Validation_And_Cancel(t *testing.T) {
+	name := ioUnique("headtail_v", ".txt")
+	path := ioMustWrite(t, name, "a\nb\n")
+	defer os.Remove(path)
+
+	for _, p := range []map[string]string{{}, {"name": "../x"}, {"name": name, "n": "0"}, {"name": name, "n": "x"}} {
+		if r := TailFileJSON(p); r.Status != 400 {
+			t.Fatalf("%v -> 400: %+v", p, r)
+		}
+	}
+	if r := HeadFileJSON(map[string]string{"name": ioUnique("nope", ".txt")}); r.Status != 404 {
+		t.Fatalf("missing -> 404: %+v", r)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r := TailFileJSONCtx(ctx, map[string]string{"name": name}); r.Status != 503 {
+		t.Fatalf("canceled -> 503: %+v", r)
+	}
+}
+
 /* ---------------- Grep ---------------- */
 
 func TestGrepJSON_Basic(t *testing.T) {
@@ -229,6 +363,248 @@ func TestGrepJSON_Validation(t *testing.T) {
 	}
 }
 
+func TestGrepJSON_WordExcludesSubstrings(t *testing.T) {
+	name := ioUnique("grep_word", ".txt")
+	path := ioMustWrite(t, name, "cat\ncategory\nthe cat sat\nbobcat\n")
+	defer os.Remove(path)
+
+	type out struct {
+		Matches int      `json:"matches"`
+		First   []string `json:"first"`
+	}
+
+	all := mustJSONIO[out](t, GrepJSON(map[string]string{"name": name, "pattern": "cat"}).Body)
+	if all.Matches != 4 {
+		t.Fatalf("substring grep: %+v", all)
+	}
+
+	r := GrepJSON(map[string]string{"name": name, "pattern": "cat", "word": "true"})
+	if r.Status != 200 {
+		t.Fatalf("word grep: %+v", r)
+	}
+	o := mustJSONIO[out](t, r.Body)
+	if o.Matches != 2 || o.First[0] != "cat" || o.First[1] != "the cat sat" {
+		t.Fatalf("word grep must skip substrings: %+v", o)
+	}
+
+	if r := GrepJSON(map[string]string{"name": name, "pattern": "cat", "word": "maybe"}); r.Status != 400 {
+		t.Fatalf("bad word flag -> 400: %+v", r)
+	}
+}
+
+func TestGrepJSON_IgnoreCase(t *testing.T) {
+	name := ioUnique("grep_icase", ".txt")
+	path := ioMustWrite(t, name, "foo\nbar\nFoO bar\n")
+	defer os.Remove(path)
+
+	type out struct {
+		Matches int      `json:"matches"`
+		First   []string `json:"first"`
+	}
+	if o := mustJSONIO[out](t, GrepJSON(map[string]string{"name": name, "pattern": "FOO"}).Body); o.Matches != 0 {
+		t.Fatalf("case-sensitive by default: %+v", o)
+	}
+	r := GrepJSON(map[string]string{"name": name, "pattern": "FOO", "ignorecase": "1"})
+	if r.Status != 200 {
+		t.Fatalf("ignorecase grep: %+v", r)
+	}
+	o := mustJSONIO[out](t, r.Body)
+	if o.Matches != 2 || o.First[0] != "foo" || o.First[1] != "FoO bar" {
+		t.Fatalf("ignorecase must match foo: %+v", o)
+	}
+}
+
+func TestGrepJSON_FixedLiteral(t *testing.T) {
+	name := ioUnique("grep_fixed", ".txt")
+	path := ioMustWrite(t, name, "a.b\naxb\nA.B end\n(x+y)*\n")
+	defer os.Remove(path)
+
+	type out struct {
+		Matches int      `json:"matches"`
+		First   []string `json:"first"`
+	}
+	// como regex, "." coincide con cualquier carácter
+	if o := mustJSONIO[out](t, GrepJSON(map[string]string{"name": name, "pattern": "a.b"}).Body); o.Matches != 2 {
+		t.Fatalf("regex a.b: %+v", o)
+	}
+	o := mustJSONIO[out](t, GrepJSON(map[string]string{"name": name, "pattern": "a.b", "fixed": "true"}).Body)
+	if o.Matches != 1 || o.First[0] != "a.b" {
+		t.Fatalf("fixed a.b must match only the literal: %+v", o)
+	}
+	// compone con ignorecase
+	o = mustJSONIO[out](t, GrepJSON(map[string]string{"name": name, "pattern": "a.b", "fixed": "true", "ignorecase": "true"}).Body)
+	if o.Matches != 2 || o.First[1] != "A.B end" {
+		t.Fatalf("fixed+ignorecase: %+v", o)
+	}
+	// un patrón que como regex es inválido funciona como literal
+	if r := GrepJSON(map[string]string{"name": name, "pattern": "(x+y"}); r.Status != 400 {
+		t.Fatalf("(x+y como regex debería ser inválido: %+v", r)
+	}
+	if r := GrepJSON(map[string]string{"name": name, "pattern": "(x+y", "fixed": "true"}); r.Status != 200 || mustJSONIO[out](t, r.Body).Matches != 1 {
+		t.Fatalf("fixed con metacaracteres: %+v", r)
+	}
+	if r := GrepJSON(map[string]string{"name": name, "pattern": "a", "fixed": "x"}); r.Status != 400 {
+		t.Fatalf("bad fixed -> 400: %+v", r)
+	}
+}
+
+func TestGrepJSON_CountOnly(t *testing.T) {
+	name := ioUnique("grep_count", ".txt")
+	path := ioMustWrite(t, name, strings.Repeat("hit\nmiss\n", 20))
+	defer os.Remove(path)
+
+	r := GrepJSON(map[string]string{"name": name, "pattern": "hit", "count_only": "true"})
+	if r.Status != 200 {
+		t.Fatalf("count_only grep: %+v", r)
+	}
+	type out struct {
+		Matches int      `json:"matches"`
+		First   []string `json:"first"`
+	}
+	o := mustJSONIO[out](t, r.Body)
+	if o.Matches != 20 || len(o.First) != 0 {
+		t.Fatalf("count_only must count without first: %+v", o)
+	}
+	if r := GrepJSON(map[string]string{"name": name, "pattern": "hit", "count_only": "x"}); r.Status != 400 {
+		t.Fatalf("bad count_only -> 400: %+v", r)
+	}
+}
+
+func TestGrepJSON_MatchRatio(t *testing.T) {
+	// 1 de cada 4 líneas hace match → 0.25
+	name := ioUnique("grep_ratio", ".txt")
+	path := ioMustWrite(t, name, strings.Repeat("hit\nmiss\nmiss\nmiss\n", 5))
+	defer os.Remove(path)
+
+	type out struct {
+		Matches int     `json:"matches"`
+		Scanned int     `json:"lines_scanned"`
+		Ratio   float64 `json:"match_ratio"`
+	}
+	o := mustJSONIO[out](t, GrepJSON(map[string]string{"name": name, "pattern": "hit"}).Body)
+	if o.Matches != 5 || o.Scanned != 20 || o.Ratio != 0.25 {
+		t.Fatalf("ratio payload: %+v", o)
+	}
+	// también con count_only
+	o = mustJSONIO[out](t, GrepJSON(map[string]string{"name": name, "pattern": "i", "count_only": "true"}).Body)
+	if o.Scanned != 20 || o.Ratio != 1 {
+		t.Fatalf("count_only ratio: %+v", o)
+	}
+
+	// archivo vacío: sin división por cero
+	empty := ioUnique("grep_ratio_empty", ".txt")
+	epath := ioMustWrite(t, empty, "")
+	defer os.Remove(epath)
+	r := GrepJSON(map[string]string{"name": empty, "pattern": "x"})
+	if r.Status != 200 {
+		t.Fatalf("empty grep: %+v", r)
+	}
+	if o = mustJSONIO[out](t, r.Body); o.Scanned != 0 || o.Ratio != 0 {
+		t.Fatalf("empty ratio: %+v", o)
+	}
+}
+
+func TestGrepJSON_ContextBlocks_StartAndEnd(t *testing.T) {
+	name := ioUnique("grep_ctx", ".txt")
+	// match en la primera y en la última línea: el contexto se recorta
+	path := ioMustWrite(t, name, "hit a\nl2\nl3\nl4\nl5\nhit b\n")
+	defer os.Remove(path)
+
+	type line struct {
+		LineNo int    `json:"line_no"`
+		Text   string `json:"text"`
+	}
+	type out struct {
+		Matches int      `json:"matches"`
+		Blocks  [][]line `json:"blocks"`
+	}
+	r := GrepJSON(map[string]string{"name": name, "pattern": "^hit", "context": "2"})
+	if r.Status != 200 {
+		t.Fatalf("context grep: %+v", r)
+	}
+	o := mustJSONIO[out](t, r.Body)
+	if o.Matches != 2 || len(o.Blocks) != 2 {
+		t.Fatalf("payload: %+v", o)
+	}
+	want0 := []line{{1, "hit a"}, {2, "l2"}, {3, "l3"}}
+	want1 := []line{{4, "l4"}, {5, "l5"}, {6, "hit b"}}
+	for k, want := range [][]line{want0, want1} {
+		got := o.Blocks[k]
+		if len(got) != len(want) {
+			t.Fatalf("block %d=%+v want %+v", k, got, want)
+		}
+		for j := range want {
+			if got[j] != want[j] {
+				t.Fatalf("block %d=%+v want %+v", k, got, want)
+			}
+		}
+	}
+
+	// sin context no hay "blocks"
+	if r := GrepJSON(map[string]string{"name": name, "pattern": "^hit"}); strings.Contains(r.Body, "blocks") {
+		t.Fatalf("blocks must be omitted by default: %s", r.Body)
+	}
+	if r := GrepJSON(map[string]string{"name": name, "pattern": "^hit", "context": "-1"}); r.Status != 400 {
+		t.Fatalf("negative context -> 400: %+v", r)
+	}
+}
+
+func TestGrepJSON_ContextBlocks_CapKeepsCounting(t *testing.T) {
+	name := ioUnique("grep_ctx_cap", ".txt")
+	path := ioMustWrite(t, name, strings.Repeat("hit\n", 15))
+	defer os.Remove(path)
+
+	type out struct {
+		Matches int                `json:"matches"`
+		Blocks  [][]map[string]any `json:"blocks"`
+	}
+	o := mustJSONIO[out](t, GrepJSON(map[string]string{"name": name, "pattern": "hit", "context": "99"}).Body)
+	if o.Matches != 15 || len(o.Blocks) != 10 {
+		t.Fatalf("matches=%d blocks=%d want 15/10", o.Matches, len(o.Blocks))
+	}
+}
+
+func TestGrepJSON_CaptureGroups(t *testing.T) {
+	name := ioUnique("grep_capture", ".txt")
+	path := ioMustWrite(t, name, "user=ana id=7\nnada\nuser=bob id=42\n")
+	defer os.Remove(path)
+
+	type capt struct {
+		Match  string  `json:"match"`
+		Group1 *string `json:"group1"`
+	}
+	type out struct {
+		Matches  int      `json:"matches"`
+		First    []string `json:"first"`
+		Captures []capt   `json:"captures"`
+	}
+	r := GrepJSON(map[string]string{"name": name, "pattern": `id=(\d+)`, "capture": "true"})
+	if r.Status != 200 {
+		t.Fatalf("capture grep: %+v", r)
+	}
+	o := mustJSONIO[out](t, r.Body)
+	if o.Matches != 2 || len(o.Captures) != 2 || len(o.First) != 2 {
+		t.Fatalf("payload: %+v", o)
+	}
+	want := []struct{ m, g string }{{"id=7", "7"}, {"id=42", "42"}}
+	for i, w := range want {
+		c := o.Captures[i]
+		if c.Match != w.m || c.Group1 == nil || *c.Group1 != w.g {
+			t.Fatalf("capture %d = %+v want %+v", i, c, w)
+		}
+	}
+
+	// sin grupo: sólo "match"
+	o = mustJSONIO[out](t, GrepJSON(map[string]string{"name": name, "pattern": "user=[a-z]+", "capture": "1"}).Body)
+	if len(o.Captures) != 2 || o.Captures[0].Match != "user=ana" || o.Captures[0].Group1 != nil {
+		t.Fatalf("no-group capture: %+v", o.Captures)
+	}
+	// por defecto no hay "captures"
+	if r := GrepJSON(map[string]string{"name": name, "pattern": "id"}); strings.Contains(r.Body, "captures") {
+		t.Fatalf("captures must be opt-in: %s", r.Body)
+	}
+}
+
 /* ---------------- HashFile ---------------- */
 
 func TestHashFileJSON_OK_And_Cancel(t *testing.T) {
@@ -270,7 +646,7 @@ func TestHashFileJSON_Validation(t *testing.T) {
 	if r := HashFileJSON(map[string]string{"name": "../x"}); r.Status != 400 {
 		t.Fatalf("bad_name -> 400: %+v", r)
 	}
-	if r := HashFileJSON(map[string]string{"name": "x", "algo": "md5"}); r.Status != 400 {
+	if r := HashFileJSON(map[string]string{"name": "x", "algo": "crc32"}); r.Status != 400 {
 		t.Fatalf("bad algo -> 400: %+v", r)
 	}
 	if r := HashFileJSON(map[string]string{"name": "nope.txt"}); r.Status != 404 {
@@ -278,6 +654,70 @@ func TestHashFileJSON_Validation(t *testing.T) {
 	}
 }
 
+func TestHashFileJSON_AllAlgos(t *testing.T) {
+	name := ioUnique("hash_algos", ".txt")
+	content := "el veloz murciélago\nhindú\n"
+	fp := ioMustWrite(t, name, content)
+	defer os.Remove(fp)
+
+	md := md5.Sum([]byte(content))
+	s1 := sha1.Sum([]byte(content))
+	s256 := sha256.Sum256([]byte(content))
+	s512 := sha512.Sum512([]byte(content))
+	want := map[string]string{
+		"md5":    hex.EncodeToString(md[:]),
+		"sha1":   hex.EncodeToString(s1[:]),
+		"sha256": hex.EncodeToString(s256[:]),
+		"sha512": hex.EncodeToString(s512[:]),
+	}
+	type out struct {
+		Algo string `json:"algo"`
+		Hex  string `json:"hex"`
+	}
+	for algo, hx := range want {
+		r := HashFileJSON(map[string]string{"name": name, "algo": algo})
+		if r.Status != 200 {
+			t.Fatalf("algo=%s: %+v", algo, r)
+		}
+		o := mustJSONIO[out](t, r.Body)
+		if o.Algo != algo || o.Hex != hx {
+			t.Fatalf("algo=%s got %+v want hex=%s", algo, o, hx)
+		}
+	}
+}
+
+func TestHashFileJSON_Base64Encoding(t *testing.T) {
+	name := ioUnique("hash_b64", ".txt")
+	fp := ioMustWrite(t, name, strings.Repeat("base64 digest\n", 100))
+	defer os.Remove(fp)
+
+	type out struct {
+		Hex      string `json:"hex"`
+		Encoding string `json:"encoding"`
+		Digest   string `json:"digest"`
+	}
+	if r := HashFileJSON(map[string]string{"name": name, "encoding": "base32"}); r.Status != 400 || r.Err.Code != "encoding" {
+		t.Fatalf("bad encoding -> 400: %+v", r)
+	}
+	for _, algo := range []string{"md5", "sha1", "sha256", "sha512"} {
+		hx := mustJSONIO[out](t, HashFileJSON(map[string]string{"name": name, "algo": algo}).Body)
+		b64 := mustJSONIO[out](t, HashFileJSON(map[string]string{"name": name, "algo": algo, "encoding": "base64"}).Body)
+		if hx.Hex == "" || hx.Encoding != "" || hx.Digest != "" {
+			t.Fatalf("[%s] default hex: %+v", algo, hx)
+		}
+		if b64.Hex != "" || b64.Encoding != "base64" || b64.Digest == "" {
+			t.Fatalf("[%s] base64: %+v", algo, b64)
+		}
+		raw, err := base64.StdEncoding.DecodeString(b64.Digest)
+		if err != nil {
+			t.Fatalf("[%s] decode base64: %v", algo, err)
+		}
+		if hex.EncodeToString(raw) != hx.Hex {
+			t.Fatalf("[%s] base64 %s != hex %s", algo, b64.Digest, hx.Hex)
+		}
+	}
+}
+
 /* ---------------- SortFile (quick + merge) ---------------- */
 
 func TestSortFileJSON_Quick_InMemory(t *testing.T) {
@@ -315,6 +755,78 @@ func TestSortFileJSON_Quick_InMemory(t *testing.T) {
 	_ = os.Remove(sortedPath)
 }
 
+func TestSortFileJSON_ConcurrentSameFile_Serialized(t *testing.T) {
+	name := ioUnique("sortconc", ".txt")
+	rng := rand.New(rand.NewSource(3))
+	var sb strings.Builder
+	const n = 20000
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "%d\n", rng.Intn(1000000))
+	}
+	in := ioMustWrite(t, name, sb.String())
+	defer os.Remove(in)
+	defer os.Remove(in + ".sorted")
+
+	var wg sync.WaitGroup
+	res := make([]resp.Result, 2)
+	for i, algo := range []string{"quick", "merge"} {
+		wg.Add(1)
+		go func(i int, algo string) {
+			defer wg.Done()
+			res[i] = SortFileJSON(map[string]string{"name": name, "algo": algo, "chunksize": "1000"})
+		}(i, algo)
+	}
+	wg.Wait()
+	for i, r := range res {
+		if r.Status != 200 {
+			t.Fatalf("sort %d: %+v", i, r)
+		}
+	}
+	ints := ioReadInts(t, in+".sorted")
+	if len(ints) != n || !ioIsSortedAsc(ints) {
+		t.Fatalf("salida corrupta: %d líneas (want %d), sorted=%v", len(ints), n, ioIsSortedAsc(ints))
+	}
+}
+
+func TestLockFile_SerializesAndHonorsCancel(t *testing.T) {
+	path := filepath.Join(dataDir, ioUnique("lock", ".txt"))
+	unlock, ok := lockFile(context.Background(), path)
+	if !ok {
+		t.Fatalf("first lock must succeed")
+	}
+
+	// mientras está tomado, otro pedido espera hasta cancelarse
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, ok := lockFile(ctx, path); ok {
+		t.Fatalf("second lock must wait while held")
+	}
+
+	got := make(chan struct{})
+	go func() {
+		u, ok := lockFile(context.Background(), path)
+		if ok {
+			u()
+		}
+		close(got)
+	}()
+	select {
+	case <-got:
+		t.Fatalf("lock acquired while held")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	<-got
+
+	// liberado por todos, la entrada se borra del mapa
+	fileLocksMu.Lock()
+	_, left := fileLocks[path]
+	fileLocksMu.Unlock()
+	if left {
+		t.Fatalf("fileLocks no debe retener %s", path)
+	}
+}
+
 func TestSortFileJSON_Merge_WithChunks_And_Cancel(t *testing.T) {
 	name := ioUnique("sortm", ".txt")
 	// 9 números, chunksize=3 → 3 chunks
@@ -386,7 +898,7 @@ func TestKWayMergeCtx_OK(t *testing.T) {
 	out := filepath.Join(dataDir, ioUnique("merged", ".txt"))
 	defer os.Remove(out)
 
-	if err := kWayMergeCtx(context.Background(), []string{c1, c2}, out); err != nil {
+	if _, err := kWayMergeCtx(context.Background(), []string{c1, c2}, out, sortOpts{}); err != nil {
 		t.Fatalf("merge: %v", err)
 	}
 	b, err := os.ReadFile(out)
@@ -408,7 +920,7 @@ func TestKWayMergeCtx_CanceledEarly(t *testing.T) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
-	if err := kWayMergeCtx(ctx, []string{c1, c2}, out); err == nil {
+	if _, err := kWayMergeCtx(ctx, []string{c1, c2}, out, sortOpts{}); err == nil {
 		t.Fatalf("expected cancel error")
 	}
 }
@@ -462,6 +974,108 @@ func TestCompressJSON_Gzip_OK_And_Cancel_And_Validation(t *testing.T) {
 	}
 }
 
+func TestCompressJSON_SkipIfIncompressible(t *testing.T) {
+	// alta entropía: bytes pseudoaleatorios
+	rnd := make([]byte, 256<<10)
+	rand.New(rand.NewSource(7)).Read(rnd)
+	noisy := ioUnique("comp_rand", ".bin")
+	pn := ioMustWrite(t, noisy, string(rnd))
+	defer os.Remove(pn)
+
+	type out struct {
+		Skipped     bool    `json:"skipped"`
+		Reason      string  `json:"reason"`
+		Output      string  `json:"output"`
+		BytesIn     int64   `json:"bytes_in"`
+		BytesOut    int64   `json:"bytes_out"`
+		SampleRatio float64 `json:"sample_ratio"`
+	}
+	o := mustJSONIO[out](t, CompressJSON(map[string]string{"name": noisy, "skip_if_incompressible": "true"}).Body)
+	if !o.Skipped || o.Reason != "incompressible" || o.BytesIn != int64(len(rnd)) || o.SampleRatio < 0.95 {
+		t.Fatalf("random data must be skipped: %+v", o)
+	}
+	if _, err := os.Stat(pn + ".gz"); !os.IsNotExist(err) {
+		t.Fatalf("skipped compress must not write output")
+	}
+
+	// texto: con el flag comprime normalmente
+	text := ioUnique("comp_text", ".txt")
+	pt := ioMustWrite(t, text, strings.Repeat("lorem ipsum dolor sit amet\n", 10000))
+	defer os.Remove(pt)
+	defer os.Remove(pt + ".gz")
+	o = mustJSONIO[out](t, CompressJSON(map[string]string{"name": text, "skip_if_incompressible": "true"}).Body)
+	if o.Skipped || o.Output != text+".gz" || o.BytesOut <= 0 || o.BytesOut >= o.BytesIn {
+		t.Fatalf("text must compress: %+v", o)
+	}
+
+	// sin flag, aun con datos aleatorios, comprime
+	defer os.Remove(pn + ".gz")
+	if o := mustJSONIO[out](t, CompressJSON(map[string]string{"name": noisy}).Body); o.Skipped || o.BytesOut == 0 {
+		t.Fatalf("default must compress: %+v", o)
+	}
+	if r := CompressJSON(map[string]string{"name": text, "skip_if_incompressible": "x"}); r.Status != 400 {
+		t.Fatalf("bad flag -> 400: %+v", r)
+	}
+}
+
+func TestCompressProbe_LevelsOnCompressibleFile(t *testing.T) {
+	// texto con palabras al azar de un vocabulario chico: compresible pero no
+	// trivial, así los niveles altos ganan algo sobre BestSpeed
+	words := []string{"alpha", "beta", "gamma", "delta", "kernel", "worker", "queue", "job", "pool", "timeout"}
+	rng := rand.New(rand.NewSource(11))
+	var sb strings.Builder
+	for sb.Len() < 2*probeSampleSize {
+		sb.WriteString(words[rng.Intn(len(words))])
+		sb.WriteString(strconv.Itoa(rng.Intn(100)))
+		sb.WriteByte(' ')
+	}
+	name := ioUnique("probe_text", ".txt")
+	fp := ioMustWrite(t, name, sb.String())
+	defer os.Remove(fp)
+
+	type out struct {
+		File        string `json:"file"`
+		Codec       string `json:"codec"`
+		BytesIn     int64  `json:"bytes_in"`
+		SampleBytes int    `json:"sample_bytes"`
+		Levels      []struct {
+			Level    int     `json:"level"`
+			BytesOut int64   `json:"bytes_out"`
+			Ratio    float64 `json:"ratio"`
+		} `json:"levels"`
+	}
+	r := CompressProbeJSONCtx(context.Background(), map[string]string{"name": name})
+	if r.Status != 200 {
+		t.Fatalf("probe => %+v", r)
+	}
+	o := mustJSONIO[out](t, r.Body)
+	if o.Codec != "gzip" || o.BytesIn != int64(sb.Len()) || o.SampleBytes != probeSampleSize {
+		t.Fatalf("probe header: %+v", o)
+	}
+	if len(o.Levels) != 3 || o.Levels[0].Level != 1 || o.Levels[1].Level != 5 || o.Levels[2].Level != 9 {
+		t.Fatalf("levels: %+v", o.Levels)
+	}
+	for _, l := range o.Levels {
+		if l.BytesOut <= 0 || l.Ratio <= 0 || l.Ratio >= 1 {
+			t.Fatalf("level %d: %+v", l.Level, l)
+		}
+	}
+	if o.Levels[2].Ratio > o.Levels[0].Ratio || o.Levels[1].Ratio > o.Levels[0].Ratio {
+		t.Fatalf("ratio debería bajar al subir el nivel: %+v", o.Levels)
+	}
+	if _, err := os.Stat(fp + ".gz"); !os.IsNotExist(err) {
+		t.Fatalf("probe must not write output")
+	}
+
+	// validación
+	if r := CompressProbeJSONCtx(context.Background(), map[string]string{"name": name, "codec": "xz"}); r.Status != 400 {
+		t.Fatalf("codec=xz -> 400: %+v", r)
+	}
+	if r := CompressProbeJSONCtx(context.Background(), map[string]string{"name": "nope_" + name}); r.Status != 404 {
+		t.Fatalf("missing file -> 404: %+v", r)
+	}
+}
+
 func TestCompressJSONCtx_XZ_Cancel(t *testing.T) {
 	name := ioUnique("comp_xz", ".txt")
 	_ = ioMustWrite(t, name, strings.Repeat("A", 1024))
@@ -526,7 +1140,7 @@ func TestSortInMemoryCtx_ParseError_New(t *testing.T) {
     in := ioMustWrite(t, ioUnique("badint", ".txt"), "1\nx\n3\n")
     out := filepath.Join(dataDir, ioUnique("out_badint", ".txt"))
 
-    _, err := sortInMemoryCtx(context.Background(), in, out)
+    _, _, err := sortInMemoryCtx(context.Background(), in, out, sortOpts{})
     if err == nil || !strings.Contains(err.Error(), "parse int") {
         t.Fatalf("esperaba error de parseo, got: %v", err)
     }
@@ -539,7 +1153,7 @@ func TestSortInMemoryCtx_ScannerErr_TokenTooLong_New(t *testing.T) {
     in := ioMustWrite(t, ioUnique("toolong", ".txt"), hugeLine)
     out := filepath.Join(dataDir, ioUnique("out_toolong", ".txt"))
 
-    _, err := sortInMemoryCtx(context.Background(), in, out)
+    _, _, err := sortInMemoryCtx(context.Background(), in, out, sortOpts{})
     if err == nil {
         t.Fatalf("esperaba error del scanner (token demasiado largo)")
     }
@@ -562,13 +1176,14 @@ func TestSortFileJSONCtx_DefaultsToMerge_WhenAlgoInvalid(t *testing.T) {
 	}
 	var out struct {
 		Algo       string `json:"algo"`
+		AlgoReason string `json:"algo_reason"`
 		SortedFile string `json:"sorted_file"`
 	}
 	if err := json.Unmarshal([]byte(r.Body), &out); err != nil {
 		t.Fatalf("json: %v", err)
 	}
-	if out.Algo != "merge" {
-		t.Fatalf("algo fallback: want 'merge', got %q", out.Algo)
+	if out.Algo != "merge" || out.AlgoReason != "default_invalid" {
+		t.Fatalf("algo fallback: want merge/default_invalid, got %q/%q", out.Algo, out.AlgoReason)
 	}
 	sorted := filepath.Join(dataDir, out.SortedFile)
 	defer os.Remove(sorted)
@@ -579,6 +1194,39 @@ func TestSortFileJSONCtx_DefaultsToMerge_WhenAlgoInvalid(t *testing.T) {
 	}
 }
 
+func TestSortFileJSONCtx_AlgoReason(t *testing.T) {
+	name := ioUnique("sort_algo_reason", ".txt")
+	in := ioMustWrite(t, name, "3\n1\n2\n")
+	defer os.Remove(in)
+
+	cases := []struct {
+		algo, want, reason string
+	}{
+		{"quick", "quick", "requested"},
+		{"merge", "merge", "requested"},
+		{"", "merge", "default"},
+	}
+	for _, c := range cases {
+		params := map[string]string{"name": name}
+		if c.algo != "" {
+			params["algo"] = c.algo
+		}
+		r := SortFileJSONCtx(context.Background(), params)
+		if r.Status != 200 || !r.JSON {
+			t.Fatalf("algo=%q: %+v", c.algo, r)
+		}
+		o := mustJSONIO[struct {
+			Algo       string `json:"algo"`
+			AlgoReason string `json:"algo_reason"`
+			SortedFile string `json:"sorted_file"`
+		}](t, r.Body)
+		_ = os.Remove(filepath.Join(dataDir, o.SortedFile))
+		if o.Algo != c.want || o.AlgoReason != c.reason {
+			t.Fatalf("algo=%q => %q/%q, want %q/%q", c.algo, o.Algo, o.AlgoReason, c.want, c.reason)
+		}
+	}
+}
+
 func TestSortInMemoryCtx_CreateOutPathError(t *testing.T) {
 	in := ioUnique("in_mem_err", ".txt")
 	_ = ioMustWrite(t, in, "3\n2\n1\n")
@@ -588,9 +1236,10 @@ func TestSortInMemoryCtx_CreateOutPathError(t *testing.T) {
 	if err := os.MkdirAll(outDir, 0o755); err != nil {
 		t.Fatalf("mkdir: %v", err)
 	}
-	_, err := sortInMemoryCtx(context.Background(),
+	_, _, err := sortInMemoryCtx(context.Background(),
 		filepath.Join(dataDir, in), // inPath completo
 		outDir,                      // outPath apunta a un directorio
+		sortOpts{},
 	)
 	if err == nil {
 		t.Fatalf("esperaba error al crear archivo de salida (out es directorio)")
@@ -606,7 +1255,7 @@ func TestExternalSortCtx_SingleChunk_RenamesTemp(t *testing.T) {
 	inPath := filepath.Join(dataDir, name)
 	outPath := filepath.Join(dataDir, ioUnique("single_chunk_out", ".sorted"))
 
-	chunks, err := externalSortCtx(context.Background(), inPath, outPath, 1_000_000)
+	chunks, _, err := externalSortCtx(context.Background(), inPath, outPath, 1_000_000, sortOpts{})
 	if err != nil {
 		t.Fatalf("externalSortCtx: %v", err)
 	}
@@ -683,6 +1332,179 @@ func TestSortFileJSONCtx_ReportsBytesInOut(t *testing.T) {
 	_ = os.Remove(filepath.Join(dataDir, out.SortedFile))
 }
 
+func TestSortFileJSONCtx_Premerged_MergesChunks(t *testing.T) {
+	c1 := ioUnique("pre_c1", ".txt")
+	c2 := ioUnique("pre_c2", ".txt")
+	p1 := ioMustWrite(t, c1, "1\n4\n9\n")
+	p2 := ioMustWrite(t, c2, "2\n3\n10\n")
+	defer os.Remove(p1)
+	defer os.Remove(p2)
+
+	r := SortFileJSONCtx(context.Background(), map[string]string{
+		"premerged": "true", "names": c1 + "," + c2,
+	})
+	if r.Status != 200 || !r.JSON {
+		t.Fatalf("premerged: %+v", r)
+	}
+	out := mustJSONIO[struct {
+		Algo       string `json:"algo"`
+		SortedFile string `json:"sorted_file"`
+		Chunks     int    `json:"chunks"`
+	}](t, r.Body)
+	sortedPath := filepath.Join(dataDir, out.SortedFile)
+	defer os.Remove(sortedPath)
+
+	if out.Algo != "premerged" || out.Chunks != 2 || out.SortedFile != c1+".sorted" {
+		t.Fatalf("payload: %+v", out)
+	}
+	got := ioReadInts(t, sortedPath)
+	want := []int64{1, 2, 3, 4, 9, 10}
+	if len(got) != len(want) {
+		t.Fatalf("merged=%v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("merged=%v want %v", got, want)
+		}
+	}
+}
+
+func TestSortFileJSONCtx_Premerged_Validation(t *testing.T) {
+	if r := SortFileJSONCtx(context.Background(), map[string]string{"premerged": "true"}); r.Status != 400 {
+		t.Fatalf("missing names -> 400: %+v", r)
+	}
+	if r := SortFileJSONCtx(context.Background(), map[string]string{
+		"premerged": "true", "names": "../bad,ok.txt",
+	}); r.Status != 400 {
+		t.Fatalf("bad chunk name -> 400: %+v", r)
+	}
+	if r := SortFileJSONCtx(context.Background(), map[string]string{
+		"premerged": "true", "names": ioUnique("nope", ".txt"),
+	}); r.Status != 404 {
+		t.Fatalf("missing chunk -> 404: %+v", r)
+	}
+}
+
+func TestSortFileJSONCtx_Premerged_OutputIsInput(t *testing.T) {
+	c1 := ioUnique("pre_in", ".txt")
+	p1 := ioMustWrite(t, c1, "1\n4\n")
+	p2 := ioMustWrite(t, c1+".sorted", "2\n3\n")
+	defer os.Remove(p1)
+	defer os.Remove(p2)
+
+	for _, params := range []map[string]string{
+		{"premerged": "true", "names": c1 + "," + c1 + ".sorted"},
+		{"premerged": "true", "names": c1 + ".sorted," + c1, "name": c1},
+	} {
+		r := SortFileJSONCtx(context.Background(), params)
+		if r.Status != 409 || r.Err == nil || r.Err.Code != "output_is_input" {
+			t.Fatalf("%v -> %+v", params, r)
+		}
+	}
+	if got := ioReadInts(t, p2); len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Fatalf("el chunk no debe tocarse: %v", got)
+	}
+}
+
+func TestSortFileJSONCtx_DescInt_BothAlgos(t *testing.T) {
+	for _, algo := range []string{"quick", "merge"} {
+		name := ioUnique("sort_desc_"+algo, ".txt")
+		path := ioMustWrite(t, name, "5\n-2\n\n9\n0\n7\n3\n")
+		r := SortFileJSONCtx(context.Background(), map[string]string{
+			"name": name, "algo": algo, "order": "desc", "chunksize": "2",
+		})
+		if r.Status != 200 {
+			t.Fatalf("%s desc: %+v", algo, r)
+		}
+		sorted := filepath.Join(dataDir, name+".sorted")
+		got := ioReadInts(t, sorted)
+		want := []int64{9, 7, 5, 3, 0, -2}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("%s desc=%v want %v", algo, got, want)
+		}
+		_ = os.Remove(path)
+		_ = os.Remove(sorted)
+	}
+}
+
+func TestSortFileJSONCtx_StringKeys_MixedFile(t *testing.T) {
+	name := ioUnique("sort_str", ".txt")
+	// mezcla de palabras y números: en modo int fallaría con "parse int"
+	path := ioMustWrite(t, name, "pera\n10\nbanana\n9\nAzul\nmanzana\n")
+	defer os.Remove(path)
+
+	if r := SortFileJSONCtx(context.Background(), map[string]string{"name": name}); r.Status != 500 {
+		t.Fatalf("int mode on mixed file -> 500: %+v", r)
+	}
+	r := SortFileJSONCtx(context.Background(), map[string]string{
+		"name": name, "algo": "merge", "keytype": "string", "chunksize": "2",
+	})
+	if r.Status != 200 || !strings.Contains(r.Body, `"keytype":"string"`) {
+		t.Fatalf("string sort: %+v", r)
+	}
+	sorted := filepath.Join(dataDir, name+".sorted")
+	defer os.Remove(sorted)
+	b, err := os.ReadFile(sorted)
+	if err != nil {
+		t.Fatalf("read sorted: %v", err)
+	}
+	want := "10\n9\nAzul\nbanana\nmanzana\npera\n"
+	if string(b) != want {
+		t.Fatalf("string asc=%q want %q", b, want)
+	}
+
+	for k, v := range map[string]string{"order": "up", "keytype": "float"} {
+		if r := SortFileJSONCtx(context.Background(), map[string]string{"name": name, k: v}); r.Status != 400 {
+			t.Fatalf("bad %s -> 400: %+v", k, r)
+		}
+	}
+}
+
+func TestSortFileJSONCtx_Dedup_AcrossChunks(t *testing.T) {
+	for _, algo := range []string{"quick", "merge"} {
+		name := ioUnique("sort_dedup_"+algo, ".txt")
+		// muchos repetidos repartidos entre chunks (chunksize=3)
+		path := ioMustWrite(t, name, "3\n1\n3\n2\n1\n3\n2\n2\n1\n3\n")
+		r := SortFileJSONCtx(context.Background(), map[string]string{
+			"name": name, "algo": algo, "dedup": "1", "chunksize": "3",
+		})
+		if r.Status != 200 {
+			t.Fatalf("%s dedup: %+v", algo, r)
+		}
+		o := mustJSONIO[struct {
+			Unique     *int   `json:"unique"`
+			SortedFile string `json:"sorted_file"`
+		}](t, r.Body)
+		sorted := filepath.Join(dataDir, o.SortedFile)
+		got := ioReadInts(t, sorted)
+		if o.Unique == nil || *o.Unique != 3 || fmt.Sprint(got) != "[1 2 3]" {
+			t.Fatalf("%s unique=%v out=%v", algo, o.Unique, got)
+		}
+		for i := 1; i < len(got); i++ {
+			if got[i] == got[i-1] {
+				t.Fatalf("%s adjacent duplicate at %d: %v", algo, i, got)
+			}
+		}
+		_ = os.Remove(path)
+		_ = os.Remove(sorted)
+	}
+}
+
+func TestSortFileJSONCtx_NoDedup_OmitsUnique(t *testing.T) {
+	name := ioUnique("sort_nodedup", ".txt")
+	path := ioMustWrite(t, name, "2\n2\n1\n")
+	defer os.Remove(path)
+	defer os.Remove(path + ".sorted")
+
+	r := SortFileJSONCtx(context.Background(), map[string]string{"name": name, "algo": "quick"})
+	if r.Status != 200 || strings.Contains(r.Body, "unique") {
+		t.Fatalf("unique must be omitted without dedup: %+v", r)
+	}
+	if got := ioReadInts(t, path+".sorted"); fmt.Sprint(got) != "[1 2 2]" {
+		t.Fatalf("duplicates must be kept without dedup: %v", got)
+	}
+}
+
 // ========================= sortInMemoryCtx: más ramas =========================
 
 func TestSortInMemoryCtx_CanceledEarly(t *testing.T) {
@@ -690,8 +1512,8 @@ func TestSortInMemoryCtx_CanceledEarly(t *testing.T) {
 	_ = ioMustWrite(t, in, "3\n2\n1\n")
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel() // cancelado antes de empezar -> se corta en el primer check
-	_, err := sortInMemoryCtx(ctx, filepath.Join(dataDir, in),
-		filepath.Join(dataDir, ioUnique("out_cancel", ".sorted")))
+	_, _, err := sortInMemoryCtx(ctx, filepath.Join(dataDir, in),
+		filepath.Join(dataDir, ioUnique("out_cancel", ".sorted")), sortOpts{})
 	if err == nil || !errors.Is(err, context.Canceled) {
 		t.Fatalf("esperaba context.Canceled, got %v", err)
 	}
@@ -703,8 +1525,8 @@ func TestSortInMemoryCtx_OK_Direct(t *testing.T) {
 	out := filepath.Join(dataDir, ioUnique("in_mem_ok_out", ".sorted"))
 	defer os.Remove(out)
 
-	chunks, err := sortInMemoryCtx(context.Background(),
-		filepath.Join(dataDir, in), out)
+	chunks, _, err := sortInMemoryCtx(context.Background(),
+		filepath.Join(dataDir, in), out, sortOpts{})
 	if err != nil || chunks != 1 {
 		t.Fatalf("sortInMemoryCtx ok: chunks=%d err=%v", chunks, err)
 	}
@@ -720,8 +1542,8 @@ func TestExternalSortCtx_ParseError(t *testing.T) {
 	in := ioUnique("ext_parse_err", ".txt")
 	_ = ioMustWrite(t, in, "1\nx\n2\n")
 	out := filepath.Join(dataDir, ioUnique("ext_parse_err_out", ".sorted"))
-	_, err := externalSortCtx(context.Background(),
-		filepath.Join(dataDir, in), out, 2)
+	_, _, err := externalSortCtx(context.Background(),
+		filepath.Join(dataDir, in), out, 2, sortOpts{})
 	if err == nil || !strings.Contains(err.Error(), "parse int") {
 		t.Fatalf("esperaba error de parseo, got %v", err)
 	}
@@ -733,8 +1555,8 @@ func TestExternalSortCtx_MultiChunks_OK_Direct(t *testing.T) {
 	out := filepath.Join(dataDir, ioUnique("ext_multi_ok_out", ".sorted"))
 	defer os.Remove(out)
 
-	chunks, err := externalSortCtx(context.Background(),
-		filepath.Join(dataDir, in), out, 3) // 9 números -> 3 chunks
+	chunks, _, err := externalSortCtx(context.Background(),
+		filepath.Join(dataDir, in), out, 3, sortOpts{}) // 9 números -> 3 chunks
 	if err != nil || chunks != 3 {
 		t.Fatalf("externalSortCtx ok: chunks=%d err=%v", chunks, err)
 	}
@@ -756,10 +1578,11 @@ func TestExternalSortCtx_CancelInsideWriteChunk(t *testing.T) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel() // cancelado antes — writeChunk detecta cancelación
-	_, err := externalSortCtx(ctx,
+	_, _, err := externalSortCtx(ctx,
 		filepath.Join(dataDir, in),
 		filepath.Join(dataDir, ioUnique("ext_cancel_write_out", ".sorted")),
 		1000, // forzamos varios writeChunk
+		sortOpts{},
 	)
 	if err == nil || !errors.Is(err, context.Canceled) {
 		t.Fatalf("esperaba context.Canceled desde writeChunk, got %v", err)
@@ -771,8 +1594,8 @@ func TestExternalSortCtx_CancelInsideWriteChunk(t *testing.T) {
 
 func TestKWayMergeCtx_OpenError(t *testing.T) {
 	out := filepath.Join(dataDir, ioUnique("out_open_err", ".txt"))
-	if err := kWayMergeCtx(context.Background(),
-		[]string{filepath.Join(dataDir, "__no_such_chunk__.tmp")}, out); err == nil {
+	if _, err := kWayMergeCtx(context.Background(),
+		[]string{filepath.Join(dataDir, "__no_such_chunk__.tmp")}, out, sortOpts{}); err == nil {
 		t.Fatalf("expected open error")
 	}
 }
@@ -781,7 +1604,7 @@ func TestKWayMergeCtx_InitScannerErr_TooLong(t *testing.T) {
 	p := ioMustWrite(t, ioUnique("scan_err", ".tmp"), strings.Repeat("9", (1<<20)+32)+"\n")
 	defer os.Remove(p)
 	out := filepath.Join(dataDir, ioUnique("out_scan_err", ".txt"))
-	if err := kWayMergeCtx(context.Background(), []string{p}, out); err == nil {
+	if _, err := kWayMergeCtx(context.Background(), []string{p}, out, sortOpts{}); err == nil {
 		t.Fatalf("expected scanner too-long error")
 	}
 }
@@ -790,7 +1613,7 @@ func TestKWayMergeCtx_InitParseError(t *testing.T) {
 	p := ioMustWrite(t, ioUnique("parse_err", ".tmp"), "x\n")
 	defer os.Remove(p)
 	out := filepath.Join(dataDir, ioUnique("out_parse_err", ".txt"))
-	if err := kWayMergeCtx(context.Background(), []string{p}, out); err == nil {
+	if _, err := kWayMergeCtx(context.Background(), []string{p}, out, sortOpts{}); err == nil {
 		t.Fatalf("expected parse-int error")
 	}
 }
@@ -804,7 +1627,7 @@ func TestKWayMergeCtx_BlankOnlyChunkIgnored(t *testing.T) {
 	out := filepath.Join(dataDir, ioUnique("out_blank_ignored", ".txt"))
 	defer os.Remove(out)
 
-	if err := kWayMergeCtx(context.Background(), []string{blank, vals}, out); err != nil {
+	if _, err := kWayMergeCtx(context.Background(), []string{blank, vals}, out, sortOpts{}); err != nil {
 		t.Fatalf("merge with blank chunk: %v", err)
 	}
 	got, _ := os.ReadFile(out)
@@ -821,7 +1644,7 @@ func TestKWayMergeCtx_RuntimeScannerErr_OnNextScan(t *testing.T) {
 	defer os.Remove(bad)
 
 	out := filepath.Join(dataDir, ioUnique("out_runtime_scan_err", ".txt"))
-	if err := kWayMergeCtx(context.Background(), []string{ok, bad}, out); err == nil {
+	if _, err := kWayMergeCtx(context.Background(), []string{ok, bad}, out, sortOpts{}); err == nil {
 		t.Fatalf("expected runtime scanner error on next scan")
 	}
 }
@@ -883,7 +1706,7 @@ func TestKWayMergeCtx_CreateOutFileError(t *testing.T) {
 	}
 	defer os.RemoveAll(out)
 
-	if err := kWayMergeCtx(context.Background(), []string{ch1, ch2}, out); err == nil {
+	if _, err := kWayMergeCtx(context.Background(), []string{ch1, ch2}, out, sortOpts{}); err == nil {
 		t.Fatalf("expected create(out) error when outPath is a directory")
 	}
 }
@@ -897,7 +1720,7 @@ func TestKWayMergeCtx_AllBlankChunks_ProducesEmptyFile(t *testing.T) {
 	out := filepath.Join(dataDir, ioUnique("out_all_blank", ".txt"))
 	defer os.Remove(out)
 
-	if err := kWayMergeCtx(context.Background(), []string{c1, c2}, out); err != nil {
+	if _, err := kWayMergeCtx(context.Background(), []string{c1, c2}, out, sortOpts{}); err != nil {
 		t.Fatalf("merge with all-blank chunks: %v", err)
 	}
 	info, err := os.Stat(out)
@@ -915,7 +1738,7 @@ func TestKWayMergeCtx_OpenError_SecondChunk(t *testing.T) {
 
 	missing := filepath.Join(dataDir, "__missing_chunk__.tmp")
 	out := filepath.Join(dataDir, ioUnique("out_open2", ".txt"))
-	if err := kWayMergeCtx(context.Background(), []string{ok, missing}, out); err == nil {
+	if _, err := kWayMergeCtx(context.Background(), []string{ok, missing}, out, sortOpts{}); err == nil {
 		t.Fatalf("expected open error on second chunk")
 	}
 }
@@ -928,7 +1751,7 @@ func TestKWayMergeCtx_NextParseError(t *testing.T) {
 	defer os.Remove(b)
 
 	out := filepath.Join(dataDir, ioUnique("out_next_parse", ".txt"))
-	if err := kWayMergeCtx(context.Background(), []string{a, b}, out); err == nil {
+	if _, err := kWayMergeCtx(context.Background(), []string{a, b}, out, sortOpts{}); err == nil {
 		t.Fatalf("expected parse error on next scan")
 	}
 	_ = os.Remove(out)
@@ -1037,3 +1860,383 @@ func TestCompressJSONCtx_XZ_ErrorOnDirectory(t *testing.T) {
 	}
 }
 
+func TestCompressJSONCtx_Zstd_ErrorOnDirectory(t *testing.T) {
+	// Igual que xz: un directorio como entrada hace fallar `zstd` (no cancelado).
+	name := ioUnique("zstd_dir", "")
+	inDir := filepath.Join(dataDir, name)
+	if err := os.MkdirAll(inDir, 0o755); err != nil {
+		t.Fatalf("mkdir inDir: %v", err)
+	}
+	defer os.RemoveAll(inDir)
+
+	r := CompressJSONCtx(context.Background(), map[string]string{"name": name, "codec": "zstd"})
+	if r.Status != 500 || r.Err == nil || r.Err.Code != "compress_error" {
+		t.Fatalf("expected compress_error from zstd on directory: %+v", r)
+	}
+}
+
+func TestCompressJSONCtx_Zstd_OK(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd no instalado")
+	}
+	name := ioUnique("zstd_ok", ".txt")
+	fp := ioMustWrite(t, name, strings.Repeat("zstd zstd zstd\n", 256))
+	defer os.Remove(fp)
+	defer os.Remove(fp + ".zst")
+
+	r := CompressJSONCtx(context.Background(), map[string]string{"name": name, "codec": "zstd"})
+	if r.Status != 200 {
+		t.Fatalf("zstd: %+v", r)
+	}
+	type out struct {
+		Codec    string `json:"codec"`
+		Output   string `json:"output"`
+		BytesOut int64  `json:"bytes_out"`
+	}
+	o := mustJSONIO[out](t, r.Body)
+	if o.Codec != "zstd" || o.Output != name+".zst" || o.BytesOut <= 0 {
+		t.Fatalf("zstd payload: %+v", o)
+	}
+}
+
+/* ---------------- Decompress ---------------- */
+
+func TestDecompressJSONCtx_RoundTrip(t *testing.T) {
+	codecs := []string{"gzip"}
+	if _, err := exec.LookPath("xz"); err == nil {
+		codecs = append(codecs, "xz")
+	}
+	for _, codec := range codecs {
+		name := ioUnique("roundtrip_"+codec, ".txt")
+		content := strings.Repeat("linea de prueba para "+codec+"\n", 500)
+		fp := ioMustWrite(t, name, content)
+
+		c := CompressJSONCtx(context.Background(), map[string]string{"name": name, "codec": codec})
+		if c.Status != 200 {
+			t.Fatalf("compress %s: %+v", codec, c)
+		}
+		type out struct {
+			File     string `json:"file"`
+			Codec    string `json:"codec"`
+			Output   string `json:"output"`
+			BytesIn  int64  `json:"bytes_in"`
+			BytesOut int64  `json:"bytes_out"`
+		}
+		co := mustJSONIO[out](t, c.Body)
+		_ = os.Remove(fp) // la descompresión debe recrearlo
+
+		// sin codec: se infiere del sufijo
+		d := DecompressJSON(map[string]string{"name": co.Output})
+		if d.Status != 200 {
+			t.Fatalf("decompress %s: %+v", codec, d)
+		}
+		do := mustJSONIO[out](t, d.Body)
+		if do.Codec != codec || do.Output != name || do.BytesOut != int64(len(content)) || do.BytesIn != co.BytesOut {
+			t.Fatalf("decompress %s payload: %+v", codec, do)
+		}
+		got, err := os.ReadFile(fp)
+		if err != nil || string(got) != content {
+			t.Fatalf("decompress %s content mismatch (err=%v)", codec, err)
+		}
+		_ = os.Remove(fp)
+		_ = os.Remove(filepath.Join(dataDir, co.Output))
+	}
+}
+
+func TestDecompressJSONCtx_Validation_And_Cancel(t *testing.T) {
+	if r := DecompressJSON(map[string]string{}); r.Status != 400 {
+		t.Fatalf("missing name -> 400: %+v", r)
+	}
+	if r := DecompressJSON(map[string]string{"name": "../x.gz"}); r.Status != 400 {
+		t.Fatalf("bad_name -> 400: %+v", r)
+	}
+	if r := DecompressJSON(map[string]string{"name": "plain.txt"}); r.Status != 400 {
+		t.Fatalf("no suffix -> 400: %+v", r)
+	}
+	if r := DecompressJSON(map[string]string{"name": "a.gz", "codec": "zip"}); r.Status != 400 || r.Err.Code != "codec" {
+		t.Fatalf("bad codec -> 400: %+v", r)
+	}
+	if r := DecompressJSON(map[string]string{"name": "a.gz", "codec": "xz"}); r.Status != 400 || r.Err.Code != "name" {
+		t.Fatalf("suffix/codec mismatch -> 400: %+v", r)
+	}
+	if r := DecompressJSON(map[string]string{"name": "nope.gz"}); r.Status != 404 {
+		t.Fatalf("not found -> 404: %+v", r)
+	}
+
+	// .gz inválido → decompress_error
+	bad := ioUnique("corrupt", ".gz")
+	bp := ioMustWrite(t, bad, "esto no es gzip")
+	defer os.Remove(bp)
+	if r := DecompressJSON(map[string]string{"name": bad}); r.Status != 500 || r.Err.Code != "decompress_error" {
+		t.Fatalf("corrupt gz -> 500: %+v", r)
+	}
+
+	// cancelado antes del primer bloque
+	name := ioUnique("dec_cancel", ".txt")
+	fp := ioMustWrite(t, name, "abc\n")
+	defer os.Remove(fp)
+	if r := CompressJSON(map[string]string{"name": name}); r.Status != 200 {
+		t.Fatalf("compress: %+v", r)
+	}
+	defer os.Remove(fp + ".gz")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r := DecompressJSONCtx(ctx, map[string]string{"name": name + ".gz"}); r.Status != 503 || r.Err.Code != "canceled" {
+		t.Fatalf("canceled -> 503: %+v", r)
+	}
+}
+
+/* ---------------- ReadFile ---------------- */
+
+func TestReadFileJSONCtx_OffsetMaxBytes_Truncated(t *testing.T) {
+	name := ioUnique("readfile", ".txt")
+	fp := ioMustWrite(t, name, "0123456789abcdef")
+	defer os.Remove(fp)
+
+	type out struct {
+		File      string `json:"file"`
+		Offset    int64  `json:"offset"`
+		BytesRead int    `json:"bytes_read"`
+		Truncated bool   `json:"truncated"`
+		Content   string `json:"content"`
+	}
+
+	r := ReadFileJSONCtx(context.Background(), map[string]string{"name": name, "offset": "4", "max_bytes": "6"})
+	if r.Status != 200 || !r.JSON {
+		t.Fatalf("readfile: %+v", r)
+	}
+	o := mustJSONIO[out](t, r.Body)
+	if o.Content != "456789" || o.BytesRead != 6 || o.Offset != 4 || !o.Truncated {
+		t.Fatalf("slice payload: %+v", o)
+	}
+
+	// hasta el final: no truncado
+	o = mustJSONIO[out](t, ReadFileJSONCtx(context.Background(), map[string]string{"name": name, "offset": "10"}).Body)
+	if o.Content != "abcdef" || o.Truncated {
+		t.Fatalf("tail payload: %+v", o)
+	}
+
+	// offset más allá del final: vacío
+	o = mustJSONIO[out](t, ReadFileJSONCtx(context.Background(), map[string]string{"name": name, "offset": "100"}).Body)
+	if o.BytesRead != 0 || o.Content != "" || o.Truncated {
+		t.Fatalf("past-EOF payload: %+v", o)
+	}
+}
+
+func TestReadFileJSONCtx_Errors(t *testing.T) {
+	bin := ioUnique("readfile_bin", ".bin")
+	fp := ioMustWrite(t, bin, "abc\x00def")
+	defer os.Remove(fp)
+
+	if r := ReadFileJSONCtx(context.Background(), map[string]string{"name": bin}); r.Status != 400 || r.Err.Code != "binary" {
+		t.Fatalf("NUL -> 400 binary: %+v", r)
+	}
+	if r := ReadFileJSONCtx(context.Background(), map[string]string{"name": "../x"}); r.Status != 400 {
+		t.Fatalf("bad name -> 400: %+v", r)
+	}
+	if r := ReadFileJSONCtx(context.Background(), map[string]string{"name": ioUnique("nope", ".txt")}); r.Status != 404 {
+		t.Fatalf("missing -> 404: %+v", r)
+	}
+	if r := ReadFileJSONCtx(context.Background(), map[string]string{"name": bin, "offset": "-1"}); r.Status != 400 {
+		t.Fatalf("negative offset -> 400: %+v", r)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r := ReadFileJSONCtx(ctx, map[string]string{"name": bin}); r.Status != 503 {
+		t.Fatalf("canceled -> 503: %+v", r)
+	}
+}
+
+/* ---------------- CopyFile ---------------- */
+
+func TestCopyFileJSONCtx_LargeCopy(t *testing.T) {
+	from := ioUnique("copy_src", ".bin")
+	to := ioUnique("copy_dst", ".bin")
+	content := strings.Repeat("0123456789abcdef", 3<<16) // 3 MiB → varios bloques
+	src := ioMustWrite(t, from, content)
+	dst := filepath.Join(dataDir, to)
+	defer os.Remove(src)
+	defer os.Remove(dst)
+
+	r := CopyFileJSONCtx(context.Background(), map[string]string{"from": from, "to": to})
+	if r.Status != 200 || !r.JSON {
+		t.Fatalf("copy: %+v", r)
+	}
+	o := mustJSONIO[struct {
+		To     string `json:"to"`
+		Action string `json:"action"`
+		Bytes  int64  `json:"bytes"`
+	}](t, r.Body)
+	got, err := os.ReadFile(dst)
+	if err != nil || string(got) != content || o.Bytes != int64(len(content)) || o.Action != "created" {
+		t.Fatalf("copy payload=%+v err=%v same=%v", o, err, string(got) == content)
+	}
+
+	// colisión: fail por defecto
+	if r := CopyFileJSONCtx(context.Background(), map[string]string{"from": from, "to": to}); r.Status != 409 ||
+		!strings.Contains(r.Body, "suggested_name") {
+		t.Fatalf("collision -> 409: %+v", r)
+	}
+}
+
+func TestCopyFileJSONCtx_AutorenameCollision(t *testing.T) {
+	from := ioUnique("copy_ar_src", ".txt")
+	base := ioUnique("copy_ar_dst", "")
+	to := base + ".txt"
+	src := ioMustWrite(t, from, "nuevo")
+	existing := ioMustWrite(t, to, "viejo")
+	defer os.Remove(src)
+	defer os.Remove(existing)
+
+	r := CopyFileJSONCtx(context.Background(), map[string]string{"from": from, "to": to, "conflict": "autorename"})
+	if r.Status != 200 {
+		t.Fatalf("autorename copy: %+v", r)
+	}
+	o := mustJSONIO[struct {
+		To     string `json:"to"`
+		Action string `json:"action"`
+	}](t, r.Body)
+	defer os.Remove(filepath.Join(dataDir, o.To))
+	if o.Action != "autorename" || o.To != base+"(1).txt" {
+		t.Fatalf("autorename payload: %+v", o)
+	}
+	if b, _ := os.ReadFile(existing); string(b) != "viejo" {
+		t.Fatalf("original must be untouched: %q", b)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dataDir, o.To)); string(b) != "nuevo" {
+		t.Fatalf("renamed copy content: %q", b)
+	}
+}
+
+func TestCopyFileJSONCtx_OverwriteSameFile(t *testing.T) {
+	name := ioUnique("copy_same", ".txt")
+	path := ioMustWrite(t, name, "contenido")
+	defer os.Remove(path)
+
+	r := CopyFileJSONCtx(context.Background(), map[string]string{"from": name, "to": name, "conflict": "overwrite"})
+	if r.Status != 400 || r.Err == nil || r.Err.Code != "same_file" {
+		t.Fatalf("from==to overwrite => %+v, want 400 same_file", r)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "contenido" {
+		t.Fatalf("el origen no debe tocarse: %q", b)
+	}
+}
+
+func TestCopyFileJSONCtx_CancelMidStream(t *testing.T) {
+	from := ioUnique("copy_cancel_src", ".bin")
+	to := ioUnique("copy_cancel_dst", ".bin")
+	src := ioMustWrite(t, from, strings.Repeat("x", 4<<20))
+	defer os.Remove(src)
+
+	// cancela justo después del primer bloque copiado (determinista vía sink de progreso)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = util.WithProgress(ctx, func(done, total int64) { cancel() })
+
+	r := CopyFileJSONCtx(ctx, map[string]string{"from": from, "to": to})
+	if r.Status != 503 || r.Err == nil || r.Err.Code != "canceled" {
+		t.Fatalf("mid-stream cancel -> 503: %+v", r)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, to)); !os.IsNotExist(err) {
+		t.Fatalf("partial copy must be removed: %v", err)
+	}
+
+	if r := CopyFileJSONCtx(context.Background(), map[string]string{"from": ioUnique("nope", ""), "to": to}); r.Status != 404 {
+		t.Fatalf("missing from -> 404: %+v", r)
+	}
+}
+
+func TestArchiveJSONCtx_TwoFiles_TarContents(t *testing.T) {
+	a := ioUnique("arch_a", ".txt")
+	b := ioUnique("arch_b", ".txt")
+	pa := ioMustWrite(t, a, "hola\n")
+	pb := ioMustWrite(t, b, strings.Repeat("b", 3000))
+	defer os.Remove(pa)
+	defer os.Remove(pb)
+	outName := ioUnique("bundle", "")
+
+	r := ArchiveJSON(map[string]string{"names": a + ", " + b, "name": outName})
+	if r.Status != 200 || !r.JSON {
+		t.Fatalf("archive: %+v", r)
+	}
+	type out struct {
+		Output   string   `json:"output"`
+		Files    []string `json:"files"`
+		BytesIn  int64    `json:"bytes_in"`
+		BytesOut int64    `json:"bytes_out"`
+	}
+	o := mustJSONIO[out](t, r.Body)
+	if o.Output != outName+".tar.gz" || len(o.Files) != 2 || o.BytesIn != 3005 || o.BytesOut <= 0 {
+		t.Fatalf("payload: %+v", o)
+	}
+	outPath := filepath.Join(dataDir, o.Output)
+	defer os.Remove(outPath)
+
+	f, err := os.Open(outPath)
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	tr := tar.NewReader(zr)
+	got := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar next: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		got[hdr.Name] = string(data)
+	}
+	if len(got) != 2 || got[a] != "hola\n" || got[b] != strings.Repeat("b", 3000) {
+		t.Fatalf("tar contents: %d entries, a=%q", len(got), got[a])
+	}
+}
+
+func TestArchiveJSONCtx_Validation_And_Cancel(t *testing.T) {
+	a := ioUnique("arch_v", ".txt")
+	pa := ioMustWrite(t, a, strings.Repeat("z", 3<<20))
+	defer os.Remove(pa)
+
+	if r := ArchiveJSON(map[string]string{}); r.Status != 400 {
+		t.Fatalf("missing names -> 400: %+v", r)
+	}
+	if r := ArchiveJSON(map[string]string{"names": "../x"}); r.Status != 400 {
+		t.Fatalf("bad name -> 400: %+v", r)
+	}
+	if r := ArchiveJSON(map[string]string{"names": a, "codec": "xz"}); r.Status != 400 {
+		t.Fatalf("bad codec -> 400: %+v", r)
+	}
+	if r := ArchiveJSON(map[string]string{"names": a + "," + ioUnique("nope", ".txt")}); r.Status != 404 {
+		t.Fatalf("missing file -> 404: %+v", r)
+	}
+
+	// la salida no puede pisar una de las entradas
+	self := ioUnique("arch_self", ".tar.gz")
+	ps := ioMustWrite(t, self, "no soy un tar")
+	defer os.Remove(ps)
+	if r := ArchiveJSON(map[string]string{"names": a + "," + self, "name": self}); r.Status != 409 || r.Err == nil || r.Err.Code != "output_is_input" {
+		t.Fatalf("output == input -> 409: %+v", r)
+	}
+	if b, _ := os.ReadFile(ps); string(b) != "no soy un tar" {
+		t.Fatalf("la entrada no debe tocarse: %q", b)
+	}
+
+	// cancela tras el primer bloque: 503 y sin archivo parcial
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = util.WithProgress(ctx, func(done, total int64) { cancel() })
+	r := ArchiveJSONCtx(ctx, map[string]string{"names": a})
+	if r.Status != 503 || r.Err == nil || r.Err.Code != "canceled" {
+		t.Fatalf("mid-stream cancel -> 503: %+v", r)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, a+".tar.gz")); !os.IsNotExist(err) {
+		t.Fatalf("partial archive must be removed: %v", err)
+	}
+}
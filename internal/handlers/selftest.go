@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"so-http10-demo/internal/resp"
+)
+
+// ============================================================================
+// /selftest — smoke test de los kernels contra vectores conocidos (KAT).
+// - Corre versiones chicas de matrixmul, pi, isprime y sortfile y compara con
+//   salidas fijas; pensado para invocarlo después de un deploy.
+// - Status: 200 si pasan todos; 500 si falla alguno (el cuerpo es el mismo).
+// - JSON: { "pass", "subsystems":[{"name","pass","detail","elapsed_ms"}], "elapsed_ms" }
+// ============================================================================
+
+// Vectores conocidos. El de matrixmul es el hash de C=A·B con size=8, seed=42
+// (si cambia el RNG o el kernel, cambia el hash).
+const (
+	selfMatrixHash = "9408d1085694fb5e3999d11c9927819bc5bcbcd776be53b442c4549a9c13ee31"
+	selfPi50       = "3.14159265358979323846264338327950288419716939937510"
+)
+
+// selfCheck es un subsistema del self-test: devuelve "" si pasa o el motivo.
+type selfCheck struct {
+	name string
+	run  func(ctx context.Context) string
+}
+
+var selfChecks = []selfCheck{
+	{"matrixmul", selfMatrixMul},
+	{"pi", selfPi},
+	{"isprime", selfIsPrime},
+	{"sortfile", selfSortFile},
+}
+
+// SelfTestJSONCtx corre todos los chequeos en orden y arma el reporte.
+func SelfTestJSONCtx(ctx context.Context, _ map[string]string) resp.Result {
+	type subT struct {
+		Name    string `json:"name"`
+		Pass    bool   `json:"pass"`
+		Detail  string `json:"detail,omitempty"`
+		Elapsed int64  `json:"elapsed_ms"`
+	}
+	start := time.Now()
+	pass := true
+	subs := make([]subT, 0, len(selfChecks))
+	for _, c := range selfChecks {
+		t0 := time.Now()
+		detail := c.run(ctx)
+		if detail == "" && ctx.Err() != nil {
+			detail = "canceled"
+		}
+		pass = pass && detail == ""
+		subs = append(subs, subT{Name: c.name, Pass: detail == "", Detail: detail, Elapsed: time.Since(t0).Milliseconds()})
+	}
+
+	b, _ := json.Marshal(struct {
+		Pass       bool   `json:"pass"`
+		Subsystems []subT `json:"subsystems"`
+		Elapsed    int64  `json:"elapsed_ms"`
+	}{pass, subs, time.Since(start).Milliseconds()})
+	if !pass {
+		return resp.Result{Status: 500, Body: string(b), JSON: true}
+	}
+	return resp.JSONOK(string(b))
+}
+
+func selfMatrixMul(ctx context.Context) string {
+	r := MatrixMulHashCtx(ctx, map[string]string{"size": "8", "seed": "42"})
+	var o struct {
+		Hash string `json:"result_sha256"`
+	}
+	if r.Status != 200 || json.Unmarshal([]byte(r.Body), &o) != nil {
+		return fmt.Sprintf("status %d", r.Status)
+	}
+	if o.Hash != selfMatrixHash {
+		return "hash mismatch: " + o.Hash
+	}
+	return ""
+}
+
+// selfPi prueba los dos kernels directamente (sin pasar por la cache de /pi).
+func selfPi(ctx context.Context) string {
+	if s, _, _ := piChudnovskyCtx(ctx, 50); s != selfPi50 {
+		return "chudnovsky: " + s
+	}
+	if s, _, _ := piSpigotCtx(ctx, 50); s != selfPi50 {
+		return "spigot: " + s
+	}
+	return ""
+}
+
+func selfIsPrime(ctx context.Context) string {
+	cases := []struct {
+		n     string
+		prime bool
+	}{{"2", true}, {"91", false}, {"1000003", true}, {"999999000001", true}, {"999999000003", false}}
+	for _, method := range []string{"division", "miller-rabin"} {
+		for _, c := range cases {
+			r := IsPrimeJSONCtx(ctx, map[string]string{"n": c.n, "method": method})
+			var o struct {
+				IsPrime bool `json:"is_prime"`
+			}
+			if r.Status != 200 || json.Unmarshal([]byte(r.Body), &o) != nil {
+				return fmt.Sprintf("%s n=%s: status %d", method, c.n, r.Status)
+			}
+			if o.IsPrime != c.prime {
+				return fmt.Sprintf("%s n=%s: is_prime=%v", method, c.n, o.IsPrime)
+			}
+		}
+	}
+	return ""
+}
+
+// selfSortFile ordena un archivo temporal en dataDir con los dos algoritmos
+// (merge con chunks chicos para ejercitar el k-way merge) y borra todo al final.
+func selfSortFile(ctx context.Context) string {
+	in := []int64{42, -7, 0, 1 << 40, 3, -7, 19, 5, -1 << 33, 8}
+	want := "-8589934592\n-7\n-7\n0\n3\n5\n8\n19\n42\n1099511627776\n"
+
+	var sb strings.Builder
+	for _, v := range in {
+		sb.WriteString(strconv.FormatInt(v, 10))
+		sb.WriteByte('\n')
+	}
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return err.Error()
+	}
+	name := "selftest_" + strconv.FormatInt(time.Now().UnixNano(), 10) + ".txt"
+	path := filepath.Join(dataDir, name)
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		return err.Error()
+	}
+	defer os.Remove(path)
+	defer os.Remove(path + ".sorted")
+
+	for _, p := range []map[string]string{
+		{"name": name, "algo": "quick"},
+		{"name": name, "algo": "merge", "chunksize": "3"},
+	} {
+		if r := SortFileJSONCtx(ctx, p); r.Status != 200 {
+			return fmt.Sprintf("%s: status %d", p["algo"], r.Status)
+		}
+		got, err := os.ReadFile(path + ".sorted")
+		if err != nil {
+			return err.Error()
+		}
+		if string(got) != want {
+			return fmt.Sprintf("%s: unexpected output %q", p["algo"], got)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+type selfTestOut struct {
+	Pass       bool `json:"pass"`
+	Subsystems []struct {
+		Name   string `json:"name"`
+		Pass   bool   `json:"pass"`
+		Detail string `json:"detail"`
+	} `json:"subsystems"`
+}
+
+func TestSelfTestJSONCtx_AllPass(t *testing.T) {
+	r := SelfTestJSONCtx(context.Background(), nil)
+	var o selfTestOut
+	if err := json.Unmarshal([]byte(r.Body), &o); err != nil {
+		t.Fatalf("json: %v (%s)", err, r.Body)
+	}
+	if r.Status != 200 || !o.Pass {
+		t.Fatalf("selftest falló: %d %s", r.Status, r.Body)
+	}
+	want := []string{"matrixmul", "pi", "isprime", "sortfile"}
+	if len(o.Subsystems) != len(want) {
+		t.Fatalf("subsystems: %+v", o.Subsystems)
+	}
+	for i, s := range o.Subsystems {
+		if s.Name != want[i] || !s.Pass || s.Detail != "" {
+			t.Fatalf("subsystem %d: %+v", i, s)
+		}
+	}
+}
+
+func TestSelfTestJSONCtx_FailureReported(t *testing.T) {
+	old := selfChecks
+	defer func() { selfChecks = old }()
+	selfChecks = []selfCheck{
+		{"ok", func(context.Context) string { return "" }},
+		{"broken", func(context.Context) string { return "mismatch" }},
+	}
+
+	r := SelfTestJSONCtx(context.Background(), nil)
+	var o selfTestOut
+	_ = json.Unmarshal([]byte(r.Body), &o)
+	if r.Status != 500 || o.Pass || !o.Subsystems[0].Pass || o.Subsystems[1].Pass || o.Subsystems[1].Detail != "mismatch" {
+		t.Fatalf("esperado 500 con broken fallando: %d %s", r.Status, r.Body)
+	}
+}
@@ -0,0 +1,78 @@
+package http10
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strconv"
+	"strings"
+)
+
+// GzipMinSize es el tamaño mínimo (bytes) de cuerpo a partir del cual vale
+// la pena comprimir la respuesta. Configurable con GZIP_MIN_BYTES.
+var GzipMinSize = 1024
+
+// ParseAcceptEncoding transforma "gzip;q=0.8, identity, *;q=0" en un mapa
+// codificación → q (en minúsculas). Sin q vale 1; un q inválido cuenta como 0.
+func ParseAcceptEncoding(h string) map[string]float64 {
+	out := map[string]float64{}
+	for _, part := range strings.Split(h, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, p := range fields[1:] {
+			k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+			if !ok || strings.ToLower(strings.TrimSpace(k)) != "q" {
+				continue
+			}
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil || f < 0 || f > 1 {
+				f = 0
+			}
+			q = f
+		}
+		out[name] = q
+	}
+	return out
+}
+
+// AcceptsGzip decide si responder con gzip según el header Accept-Encoding:
+// gzip (o x-gzip, o "*" si gzip no aparece) con q > 0, salvo que identity
+// aparezca explícitamente con un q mayor. Sin header, no se comprime.
+func AcceptsGzip(h string) bool {
+	if strings.TrimSpace(h) == "" {
+		return false
+	}
+	enc := ParseAcceptEncoding(h)
+	star, hasStar := enc["*"]
+
+	qGzip, ok := enc["gzip"]
+	if !ok {
+		qGzip, ok = enc["x-gzip"]
+	}
+	if !ok {
+		if !hasStar {
+			return false
+		}
+		qGzip = star
+	}
+
+	// identity sin listar no compite: siempre es aceptable como fallback
+	qIdentity := enc["identity"]
+	return qGzip > 0 && qGzip >= qIdentity
+}
+
+// GzipBody comprime body con gzip (nivel por defecto).
+func GzipBody(body string) (string, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(body)); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
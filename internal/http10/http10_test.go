@@ -6,8 +6,12 @@ import (
 	"io"
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"net/http/httputil"
+	"runtime"
 	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 )
 
@@ -130,6 +134,53 @@ func TestParseQuery_Variants(t *testing.T) {
 	}
 }
 
+func TestParseQuery_PercentDecoding(t *testing.T) {
+	cases := []struct {
+		in   string
+		key  string
+		want string
+	}{
+		{"text=hola%20mundo", "text", "hola mundo"},
+		{"path=a%2Fb", "path", "a/b"},
+		{"q=x%26y", "q", "x&y"},
+		{"text=a+b+c", "text", "a b c"},
+		{"bad=%ZZ", "bad", "%ZZ"},     // escape inválido: se conserva crudo
+		{"half=100%", "half", "100%"}, // '%' suelto al final
+		{"my%20key=v", "my key", "v"}, // la clave también se decodifica
+		{"k%ZZ=v", "k%ZZ", "v"},       // clave inválida: cruda, el par no se pierde
+		{"plus=%2B", "plus", "+"},     // '+' literal codificado
+	}
+	for _, tc := range cases {
+		m := ParseQuery(tc.in)
+		got, ok := m[tc.key]
+		if !ok || got != tc.want {
+			t.Fatalf("ParseQuery(%q)[%q] = %q (ok=%v); want %q", tc.in, tc.key, got, ok, tc.want)
+		}
+	}
+}
+
+func TestParseQueryMulti_OrderAndEmptySegments(t *testing.T) {
+	m := ParseQueryMulti("pattern=a&x=1&&pattern=b%20c&pattern=&&")
+	got := m["pattern"]
+	if len(got) != 3 || got[0] != "a" || got[1] != "b c" || got[2] != "" {
+		t.Fatalf("order/values: %#v", got)
+	}
+	if len(m["x"]) != 1 || m["x"][0] != "1" {
+		t.Fatalf("single key: %#v", m["x"])
+	}
+	if len(m) != 2 {
+		t.Fatalf("empty segments must be skipped: %#v", m)
+	}
+	if len(ParseQueryMulti("")) != 0 {
+		t.Fatalf("empty query should be empty map")
+	}
+
+	// ParseQuery mantiene su contrato: último valor
+	if v := ParseQuery("pattern=a&pattern=b")["pattern"]; v != "b" {
+		t.Fatalf("ParseQuery last wins: %q", v)
+	}
+}
+
 // ---------- write / WritePlainH / WriteJSONH / WriteErrorJSON ----------
 func TestWritePlainH_Basics_And_ExtraOverride(t *testing.T) {
 	var buf bytes.Buffer
@@ -194,6 +245,118 @@ func TestWriteJSONH_ContentType_And_Body(t *testing.T) {
 	}
 }
 
+func TestWriteBinaryH_ContentType_And_RawBytes(t *testing.T) {
+	var buf bytes.Buffer
+	data := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff, '\r', '\n'}
+	WriteBinaryH(&buf, 200, "image/png", data, map[string]string{"X-Test": "1"})
+
+	pr := parseHTTP(buf.String())
+	if pr.Headers["Content-Type"] != "image/png" || pr.Headers["X-Test"] != "1" {
+		t.Fatalf("headers: %+v", pr.Headers)
+	}
+	if pr.Body != string(data) {
+		t.Fatalf("body bytes mismatch: %q", pr.Body)
+	}
+	if pr.Headers["Content-Length"] != strconvItoa(len(data)) {
+		t.Fatalf("content-length mismatch: %v", pr.Headers["Content-Length"])
+	}
+}
+
+func TestWriteStreamH_Chunked_FramingAndDecode(t *testing.T) {
+	old := Chunked
+	Chunked = true
+	defer func() { Chunked = old }()
+
+	// más grande que chunkSize para forzar varios chunks
+	want := strings.Repeat("0123456789abcdef", 5000) // 80000 bytes
+	var buf bytes.Buffer
+	if err := WriteStreamH(&buf, 200, "text/plain; charset=utf-8", strings.NewReader(want), map[string]string{"Content-Length": "1"}); err != nil {
+		t.Fatalf("WriteStreamH: %v", err)
+	}
+
+	p := parseHTTP(buf.String())
+	if p.StatusCode != 200 || p.Headers["Transfer-Encoding"] != "chunked" {
+		t.Fatalf("status/headers: %+v", p)
+	}
+	if _, ok := p.Headers["Content-Length"]; ok {
+		t.Fatalf("chunked no debe llevar Content-Length: %v", p.Headers)
+	}
+
+	// framing: primer chunk de chunkSize en hex y cierre con chunk de largo 0
+	if first := fmt.Sprintf("%x\r\n", chunkSize); !strings.HasPrefix(p.Body, first) {
+		t.Fatalf("primer chunk: %q", p.Body[:16])
+	}
+	if !strings.HasSuffix(p.Body, "\r\n0\r\n\r\n") {
+		t.Fatalf("falta el chunk final: %q", p.Body[len(p.Body)-16:])
+	}
+
+	got, err := io.ReadAll(httputil.NewChunkedReader(strings.NewReader(p.Body)))
+	if err != nil {
+		t.Fatalf("decode chunked: %v", err)
+	}
+	if string(got) != want {
+		t.Fatalf("body decodificado: len=%d want %d", len(got), len(want))
+	}
+}
+
+func TestWriteStreamH_DefaultContentLength_And_ReadError(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteStreamH(&buf, 200, "text/plain", strings.NewReader("hola"), nil); err != nil {
+		t.Fatalf("WriteStreamH: %v", err)
+	}
+	p := parseHTTP(buf.String())
+	if p.Headers["Content-Length"] != "4" || p.Body != "hola" {
+		t.Fatalf("modo por defecto: %+v", p)
+	}
+	if _, ok := p.Headers["Transfer-Encoding"]; ok {
+		t.Fatalf("no debe haber Transfer-Encoding: %v", p.Headers)
+	}
+
+	// error de lectura: sin Chunked no se escribe nada
+	buf.Reset()
+	boom := errors.New("boom")
+	if err := WriteStreamH(&buf, 200, "text/plain", iotest.ErrReader(boom), nil); err != boom || buf.Len() != 0 {
+		t.Fatalf("err=%v out=%q", err, buf.String())
+	}
+}
+
+func TestAcceptsGzip_QValues(t *testing.T) {
+	cases := []struct {
+		h    string
+		want bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"gzip;q=1.0", true},
+		{"GZIP ; Q=0.5", true},
+		{"gzip;q=0", false},
+		{"gzip;q=0, identity", false},
+		{"gzip;q=0.000", false},
+		{"x-gzip", true},
+		{"identity", false},
+		{"deflate, br", false},
+		{"*", true},
+		{"*;q=0", false},
+		{"*;q=0.5, gzip;q=0", false},
+		{"gzip;q=0.5, identity", false},    // identity listado con q=1 es preferido
+		{"gzip;q=0.5, identity;q=0.2", true},
+		{"gzip;q=0.5", true},                // identity sin listar no compite
+		{"gzip;q=0.5, *;q=0", true},
+		{"gzip;q=abc", false},               // q inválido cuenta como 0
+		{"br;q=1, gzip;q=0.8", true},
+	}
+	for _, c := range cases {
+		if got := AcceptsGzip(c.h); got != c.want {
+			t.Errorf("AcceptsGzip(%q) = %v, want %v", c.h, got, c.want)
+		}
+	}
+
+	enc := ParseAcceptEncoding("gzip;q=0.3, identity, ,br;level=5;q=0.7")
+	if enc["gzip"] != 0.3 || enc["identity"] != 1 || enc["br"] != 0.7 || len(enc) != 3 {
+		t.Fatalf("ParseAcceptEncoding: %v", enc)
+	}
+}
+
 func TestWriteErrorJSON_EscapesAndFormat(t *testing.T) {
 	var buf bytes.Buffer
 	WriteErrorJSON(&buf, 400, "bad_input", `detalle con "comillas"`, map[string]string{
@@ -227,12 +390,18 @@ func TestWriteErrorJSON_EscapesAndFormat(t *testing.T) {
 func TestStatusText_AllKnownCodes(t *testing.T) {
 	cases := map[int]string{
 		200: "OK",
+		202: "Accepted",
 		400: "Bad Request",
 		404: "Not Found",
+		408: "Request Timeout",
 		409: "Conflict",
+		410: "Gone",
+		413: "Request Entity Too Large",
 		429: "Too Many Requests",
 		500: "Internal Server Error",
 		503: "Service Unavailable",
+		504: "Gateway Timeout",
+		507: "Insufficient Storage",
 	}
 	for code, want := range cases {
 		if got := statusText(code); got != want {
@@ -432,3 +601,105 @@ func TestParseRequest_EmptyReader_PropagatesEOF(t *testing.T) {
 		t.Fatalf("want io.EOF, got %v", err)
 	}
 }
+
+func TestParseRequest_TooManyHeaders(t *testing.T) {
+	old := MaxHeaders
+	MaxHeaders = 3
+	defer func() { MaxHeaders = old }()
+
+	// justo en el límite: OK
+	raw := "GET / HTTP/1.0\r\nA: 1\r\nB: 2\r\nC: 3\r\n\r\n"
+	if _, err := ParseRequest(bufio.NewReader(strings.NewReader(raw))); err != nil {
+		t.Fatalf("at limit should parse, got %v", err)
+	}
+
+	// uno más: ErrBadRequest
+	raw = "GET / HTTP/1.0\r\nA: 1\r\nB: 2\r\nC: 3\r\nD: 4\r\n\r\n"
+	_, err := ParseRequest(bufio.NewReader(strings.NewReader(raw)))
+	if !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("want ErrBadRequest, got %v", err)
+	}
+}
+
+func TestRequestKeepAlive(t *testing.T) {
+	cases := []struct {
+		hdr  map[string]string
+		want bool
+	}{
+		{map[string]string{}, false},
+		{map[string]string{"connection": "close"}, false},
+		{map[string]string{"connection": "Keep-Alive"}, true},
+		{map[string]string{"connection": "foo, keep-alive"}, true},
+		{map[string]string{"connection": "keep-alive", "content-length": "5"}, true},
+	}
+	for _, c := range cases {
+		req := &Request{Method: "GET", Target: "/", Proto: "HTTP/1.0", Header: c.hdr}
+		if got := req.KeepAlive(); got != c.want {
+			t.Fatalf("KeepAlive(%v) = %v, want %v", c.hdr, got, c.want)
+		}
+	}
+}
+
+func TestParseRequest_DeclaredBodyNotPreallocated(t *testing.T) {
+	old := MaxBodySize
+	MaxBodySize = 1 << 30
+	defer func() { MaxBodySize = old }()
+
+	// declara 1 GiB y manda 3 bytes: 400 sin reservar el GiB
+	raw := "POST /upload HTTP/1.0\r\nContent-Length: 1073741824\r\n\r\nabc"
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := ParseRequest(bufio.NewReader(strings.NewReader(raw)))
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("err=%v, want ErrBadRequest", err)
+	}
+	if d := after.TotalAlloc - before.TotalAlloc; d > 1<<20 {
+		t.Fatalf("se reservaron %d bytes para un cuerpo de 3", d)
+	}
+}
+
+func TestParseRequest_BodyCap(t *testing.T) {
+	old := MaxBodySize
+	MaxBodySize = 16
+	defer func() { MaxBodySize = old }()
+
+	parse := func(cl, body string) (*Request, *bufio.Reader, error) {
+		raw := "POST /upload HTTP/1.0\r\nContent-Length: " + cl + "\r\n\r\n" + body
+		r := bufio.NewReader(strings.NewReader(raw))
+		req, err := ParseRequest(r)
+		return req, r, err
+	}
+
+	// justo en el límite: se acepta y se lee completo
+	req, _, err := parse("16", strings.Repeat("x", 16))
+	if err != nil || string(req.Body) != strings.Repeat("x", 16) {
+		t.Fatalf("at limit: req=%+v err=%v", req, err)
+	}
+
+	// un byte más: 413 sin leer el cuerpo; el request vuelve igual (sin
+	// Body) para saber cuánto quedó pendiente
+	req, r, err := parse("17", strings.Repeat("x", 17))
+	if !errors.Is(err, ErrBodyTooLarge) || req == nil || req.Header["content-length"] != "17" || req.Body != nil {
+		t.Fatalf("over limit: req=%+v err=%v, want ErrBodyTooLarge", req, err)
+	}
+	if rest, _ := io.ReadAll(r); len(rest) != 17 {
+		t.Fatalf("el cuerpo no debía leerse: quedaron %d bytes", len(rest))
+	}
+
+	// Content-Length menor que lo enviado: sólo se leen los bytes declarados
+	req, r, err = parse("4", "abcdEXTRA")
+	if err != nil || string(req.Body) != "abcd" {
+		t.Fatalf("short CL: req=%+v err=%v", req, err)
+	}
+	if rest, _ := io.ReadAll(r); string(rest) != "EXTRA" {
+		t.Fatalf("leftover=%q", rest)
+	}
+
+	// cuerpo más corto que Content-Length o CL inválido: 400
+	for _, c := range [][2]string{{"10", "abc"}, {"x", ""}, {"-1", ""}} {
+		if _, _, err := parse(c[0], c[1]); !errors.Is(err, ErrBadRequest) {
+			t.Fatalf("CL=%q body=%q: err=%v, want ErrBadRequest", c[0], c[1], err)
+		}
+	}
+}
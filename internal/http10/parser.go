@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"errors"
 	"io"
+	"strconv"
 	"strings"
 )
 
@@ -14,6 +15,7 @@ type Request struct {
 	Target string
 	Proto  string
 	Header map[string]string
+	Body   []byte // según Content-Length (nil sin cuerpo)
 }
 
 var (
@@ -22,13 +24,29 @@ var (
 	ErrBadRequest = errors.New("malformed request (CRLF/fields)")
 	// ErrBadProto se usa cuando la versión no es HTTP/1.0.
 	ErrBadProto = errors.New("unsupported protocol (HTTP/1.0 only)")
+	// ErrBodyTooLarge se usa cuando Content-Length supera MaxBodySize.
+	ErrBodyTooLarge = errors.New("request body too large")
 )
 
+// MaxHeaders limita cuántas líneas de header acepta ParseRequest.
+// Evita que una avalancha de headers haga crecer el mapa sin control.
+// Exceder el límite devuelve ErrBadRequest (400).
+var MaxHeaders = 100
+
+// MaxBodySize limita el cuerpo (Content-Length) que acepta ParseRequest.
+// Exceder el límite devuelve ErrBodyTooLarge (413). Configurable con
+// SERVER_MAX_BODY (bytes).
+var MaxBodySize int64 = 10 << 20
+
 // ParseRequest lee una petición HTTP/1.0 estricta desde r.
 // Formato requerido:
 //   request-line: "METHOD SP target SP HTTP/1.0 CRLF"
 //   0..N header-lines terminadas en CRLF
 //   línea en blanco CRLF que cierra los headers
+//   cuerpo opcional de exactamente Content-Length bytes
+// Se aceptan como máximo MaxHeaders líneas de header y MaxBodySize bytes de
+// cuerpo. Con ErrBodyTooLarge también se devuelve el request (sin Body) para
+// que el llamador sepa cuánto cuerpo quedó sin leer.
 func ParseRequest(r *bufio.Reader) (*Request, error) {
 	// request-line
 	line, err := r.ReadString('\n')
@@ -49,6 +67,7 @@ func ParseRequest(r *bufio.Reader) (*Request, error) {
 
 	// headers
 	h := map[string]string{}
+	count := 0
 	for {
 		l, err := r.ReadString('\n')
 		if err != nil {
@@ -63,6 +82,10 @@ func ParseRequest(r *bufio.Reader) (*Request, error) {
 		if !strings.HasSuffix(l, "\r\n") {
 			return nil, ErrBadRequest
 		}
+		count++
+		if count > MaxHeaders {
+			return nil, ErrBadRequest
+		}
 		l = strings.TrimRight(l, "\r\n")
 		kv := strings.SplitN(l, ":", 2)
 		if len(kv) != 2 {
@@ -73,5 +96,55 @@ func ParseRequest(r *bufio.Reader) (*Request, error) {
 		h[key] = val
 	}
 
-	return &Request{Method: method, Target: target, Proto: proto, Header: h}, nil
+	req := &Request{Method: method, Target: target, Proto: proto, Header: h}
+	body, err := readBody(r, h["content-length"])
+	if err == ErrBodyTooLarge {
+		return req, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.Body = body
+	return req, nil
+}
+
+// readBody lee el cuerpo declarado en Content-Length. El límite se chequea
+// antes de leer y la lectura va por un LimitReader de n (<= MaxBodySize):
+// nunca se leen más bytes que los declarados.
+func readBody(r *bufio.Reader, cl string) ([]byte, error) {
+	if cl == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(cl, 10, 64)
+	if err != nil || n < 0 {
+		return nil, ErrBadRequest
+	}
+	if n > MaxBodySize {
+		return nil, ErrBodyTooLarge
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	// se lee de a poco (no se reserva n de entrada): un header que declara
+	// mucho y no manda nada no cuesta memoria
+	body, err := io.ReadAll(io.LimitReader(r, n))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) < n {
+		return nil, ErrBadRequest // cuerpo más corto que Content-Length
+	}
+	return body, nil
+}
+
+// KeepAlive indica si el cliente pidió reusar la conexión ("Connection:
+// keep-alive", extensión de HTTP/1.0). ParseRequest ya consumió el cuerpo,
+// así que lo que sigue en el reader es el request siguiente.
+func (req *Request) KeepAlive() bool {
+	for _, tok := range strings.Split(req.Header["connection"], ",") {
+		if strings.EqualFold(strings.TrimSpace(tok), "keep-alive") {
+			return true
+		}
+	}
+	return false
 }
@@ -1,6 +1,9 @@
 package http10
 
-import "strings"
+import (
+	"net/url"
+	"strings"
+)
 
 // SplitTarget separa path y query string de un target (p. ej., "/path?x=1&y=2").
 // No realiza decodificación; eso se agrega si el proyecto lo requiere.
@@ -13,13 +16,29 @@ func SplitTarget(t string) (path string, query string) {
 	return
 }
 
-// ParseQuery transforma "a=1&b=2" en un mapa simple sin percent-decoding.
-// Suficiente para la primera etapa del proyecto.
+// ParseQuery transforma "a=1&b=2" en un mapa simple.
+// Claves y valores se decodifican con url.QueryUnescape (semántica de
+// formulario): "%20" y "+" pasan a espacio, "%2F" a "/", "%26" a "&".
+// Si una parte trae un escape inválido (p. ej., "%ZZ") se conserva tal cual
+// en lugar de descartar el par. Segmentos vacíos se ignoran y, ante claves
+// repetidas, gana la última (ver ParseQueryMulti para conservarlas todas).
 func ParseQuery(q string) map[string]string {
+	multi := ParseQueryMulti(q)
+	m := make(map[string]string, len(multi))
+	for k, vs := range multi {
+		m[k] = vs[len(vs)-1]
+	}
+	return m
+}
+
+// ParseQueryMulti es como ParseQuery pero conserva cada ocurrencia de una
+// clave repetida, en el orden en que aparece ("k=1&k=2" → {"k": ["1","2"]}).
+// Misma decodificación y mismo tratamiento de segmentos vacíos.
+func ParseQueryMulti(q string) map[string][]string {
+	m := make(map[string][]string)
 	if q == "" {
-		return map[string]string{}
+		return m
 	}
-	m := make(map[string]string)
 	for _, kv := range strings.Split(q, "&") {
 		if kv == "" {
 			continue
@@ -29,7 +48,16 @@ func ParseQuery(q string) map[string]string {
 		if len(p) == 2 {
 			v = p[1]
 		}
-		m[k] = v
+		k = unescape(k)
+		m[k] = append(m[k], unescape(v))
 	}
 	return m
 }
+
+// unescape decodifica un componente de query; ante error devuelve el crudo.
+func unescape(s string) string {
+	if d, err := url.QueryUnescape(s); err == nil {
+		return d
+	}
+	return s
+}
@@ -7,7 +7,8 @@ import (
 	"time"
 )
 
-// write compone una respuesta HTTP/1.0 incluyendo Content-Length y Connection: close.
+// write compone una respuesta HTTP/1.0 incluyendo Content-Length y Connection: close
+// (el servidor lo pisa con keep-alive vía extra si el cliente lo pidió).
 // Acepta cabeceras adicionales (p. ej., trazabilidad) que se mezclan con las estándar.
 func write(w io.Writer, status int, contentType string, body string, extra map[string]string) {
 	headers := map[string]string{
@@ -21,12 +22,71 @@ func write(w io.Writer, status int, contentType string, body string, extra map[s
 		maps.Copy(headers, extra)
 	}
 
+	writeHead(w, status, headers)
+	io.WriteString(w, body)
+}
+
+// writeHead escribe la línea de estado, las cabeceras y la línea en blanco.
+func writeHead(w io.Writer, status int, headers map[string]string) {
 	io.WriteString(w, fmt.Sprintf("HTTP/1.0 %d %s\r\n", status, statusText(status)))
 	for k, v := range headers {
 		io.WriteString(w, fmt.Sprintf("%s: %s\r\n", k, v))
 	}
 	io.WriteString(w, "\r\n")
-	io.WriteString(w, body)
+}
+
+// Chunked habilita Transfer-Encoding: chunked en WriteStreamH. HTTP/1.0 no lo
+// define, así que queda apagado por defecto (sólo para clientes que lo toleran).
+var Chunked = false
+
+// chunkSize es el tamaño máximo de cada chunk al transmitir.
+const chunkSize = 32 << 10
+
+// WriteStreamH escribe un cuerpo de largo desconocido leído de src.
+// Con Chunked lo transmite en chunks ("<hex>\r\n<datos>\r\n" y "0\r\n\r\n" al
+// final) sin Content-Length; si no, lo lee completo y usa Content-Length como
+// el resto de los writers. Devuelve el error de lectura de src, si hubo.
+func WriteStreamH(w io.Writer, status int, contentType string, src io.Reader, extra map[string]string) error {
+	if !Chunked {
+		data, err := io.ReadAll(src)
+		if err != nil {
+			return err
+		}
+		write(w, status, contentType, string(data), extra)
+		return nil
+	}
+
+	headers := map[string]string{
+		"Date":              time.Now().UTC().Format(time.RFC1123),
+		"Content-Type":      contentType,
+		"Transfer-Encoding": "chunked",
+		"Connection":        "close",
+		"Server":            "so-http10/0.2",
+	}
+	if extra != nil {
+		maps.Copy(headers, extra)
+	}
+	delete(headers, "Content-Length") // no se mezcla con chunked
+
+	writeHead(w, status, headers)
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			io.WriteString(w, fmt.Sprintf("%x\r\n", n))
+			w.Write(buf[:n])
+			io.WriteString(w, "\r\n")
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// se cierra sin el chunk final: el cliente ve la respuesta truncada
+			return err
+		}
+	}
+	io.WriteString(w, "0\r\n\r\n")
+	return nil
 }
 
 // WritePlainH escribe una respuesta de texto plano con cabeceras extra.
@@ -39,6 +99,12 @@ func WriteJSONH(w io.Writer, status int, json string, extra map[string]string) {
 	write(w, status, "application/json", json, extra)
 }
 
+// WriteBinaryH escribe un cuerpo binario (p. ej. image/png) con su
+// Content-Type; Content-Length es el tamaño exacto en bytes.
+func WriteBinaryH(w io.Writer, status int, contentType string, data []byte, extra map[string]string) {
+	write(w, status, contentType, string(data), extra)
+}
+
 // WriteErrorJSON serializa un payload uniforme de error:
 // {"error":"<code>","detail":"<detalle>"} con el status indicado.
 func WriteErrorJSON(w io.Writer, status int, code, detail string, extra map[string]string) {
@@ -64,18 +130,30 @@ func statusText(code int) string {
 	switch code {
 	case 200:
 		return "OK"
+	case 202:
+		return "Accepted"
 	case 400:
 		return "Bad Request"
 	case 404:
 		return "Not Found"
+	case 408:
+		return "Request Timeout"
 	case 409:
 		return "Conflict"
+	case 410:
+		return "Gone"
+	case 413:
+		return "Request Entity Too Large"
 	case 429:
 		return "Too Many Requests"
 	case 500:
 		return "Internal Server Error"
 	case 503:
 		return "Service Unavailable"
+	case 504:
+		return "Gateway Timeout"
+	case 507:
+		return "Insufficient Storage"
 	default:
 		return "OK"
 	}
@@ -2,14 +2,23 @@ package jobs
 
 import (
     "bufio"
+    "bytes"
     "context"
     "encoding/json"
     "errors"
+    "math"
+    "net/http"
+    "net/url"
     "os"
     "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
     "sync"
+    "sync/atomic"
     "time"
 
+    "so-http10-demo/internal/handlers"
     "so-http10-demo/internal/resp"
     "so-http10-demo/internal/sched"
     "so-http10-demo/internal/util"
@@ -41,8 +50,50 @@ type Job struct {
     Progress *int   `json:"progress,omitempty"`
     ETAMs    *int64 `json:"eta_ms,omitempty"`
 
+    // Webhook opcional: al terminar se hace POST con el resultado.
+    CallbackURL string `json:"callback_url,omitempty"`
+
+    // Grupo de concurrencia (group=NAME&group_limit=K), si se pidió.
+    Group      string `json:"group,omitempty"`
+    GroupLimit int    `json:"group_limit,omitempty"`
+
+    // Job del que se heredaron los params (parent=JOBID), si se pidió.
+    ParentID string `json:"parent_id,omitempty"`
+
+    // Envío periódico que lo creó (repeat_every_ms/repeat_count), si aplica.
+    ScheduleID string `json:"schedule_id,omitempty"`
+
+    // Etiquetas libres (tags=a,b) para agrupar envíos; filtra /jobs/list?tag=.
+    Tags []string `json:"tags,omitempty"`
+
     // Cancelación cooperativa
     cancel context.CancelFunc `json:"-"`
+
+    // execTimeout con el que se envió (lo reusa Resubmit; 0 tras rehidratar
+    // del journal ⇒ el timeout por defecto del pool).
+    execTimeout time.Duration
+
+    // done se cierra cuando la goroutine del job termina (ver Wait).
+    done chan struct{}
+
+    // prog recibe el avance reportado por el handler (util.ReportProgress).
+    prog *progressSink
+}
+
+// progressSink guarda el último (done, total) reportado; acceso atómico porque
+// escribe el worker y lee SnapshotJSON.
+type progressSink struct {
+    done  int64
+    total int64
+}
+
+func (s *progressSink) report(done, total int64) {
+    atomic.StoreInt64(&s.total, total)
+    atomic.StoreInt64(&s.done, done)
+}
+
+func (s *progressSink) load() (done, total int64) {
+    return atomic.LoadInt64(&s.done), atomic.LoadInt64(&s.total)
 }
 
 
@@ -53,20 +104,43 @@ type Manager struct {
 	journal string      // ruta del journal JSONL
 	mu      sync.RWMutex
 	jobs    map[string]*Job
+	expired expiredSet // ids ya limpiados por el GC (bajo mu)
 
-	ttl   time.Duration
-	stopC chan struct{}
+	ttl     time.Duration
+	gcEvery time.Duration // cada cuánto corre cleanup (<= 0: cada minuto)
+	stopC   chan struct{}
+
+	// groups: semáforos por nombre (group=NAME) para limitar cuántos jobs
+	// del grupo corren a la vez, independiente de los workers del pool. Se
+	// borran cuando el grupo no tiene jobs en vuelo.
+	gmu    sync.Mutex
+	groups map[string]*groupState
+
+	// schedules: envíos periódicos en curso o terminados (ver SubmitRepeat).
+	smu       sync.Mutex
+	schedules map[string]*schedule
 }
 
 // NewManager crea un Job Manager con TTL de limpieza y persiste en /app/data.
+// El GC corre cada minuto.
 func NewManager(s *sched.Manager, ttl time.Duration) *Manager {
-	jdir := "/app/data"
+	return NewManagerWithGC(s, ttl, time.Minute)
+}
+
+// NewManagerWithGC es NewManager con el intervalo del GC explícito.
+func NewManagerWithGC(s *sched.Manager, ttl, gcEvery time.Duration) *Manager {
+	return NewManagerAt(s, ttl, gcEvery, "/app/data")
+}
+
+// NewManagerAt es NewManagerWithGC con el directorio del journal explícito.
+func NewManagerAt(s *sched.Manager, ttl, gcEvery time.Duration, jdir string) *Manager {
 	m := &Manager{
 		sched:   s,
 		jobsDir: jdir,
 		journal: filepath.Join(jdir, "jobs.journal"),
 		jobs:    make(map[string]*Job),
 		ttl:     ttl,
+		gcEvery: gcEvery,
 		stopC:   make(chan struct{}),
 	}
 	_ = os.MkdirAll(m.jobsDir, 0o755)
@@ -86,6 +160,13 @@ type journalRecord struct {
 	ID   string `json:"id,omitempty"`
 }
 
+// JournalFsync fuerza f.Sync() tras cada registro del journal (JOURNAL_FSYNC=1).
+// Apagado por defecto: da durabilidad ante caídas a costa de throughput.
+var JournalFsync = false
+
+// syncFile es el punto de inyección de f.Sync() (tests).
+var syncFile = func(f *os.File) error { return f.Sync() }
+
 func (m *Manager) appendJournal(rec journalRecord) {
 	f, err := os.OpenFile(m.journal, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
 	if err != nil {
@@ -93,7 +174,12 @@ func (m *Manager) appendJournal(rec journalRecord) {
 	}
 	defer f.Close()
 	enc, _ := json.Marshal(rec)
-	_, _ = f.Write(append(enc, '\n'))
+	if _, err := f.Write(append(enc, '\n')); err != nil {
+		return
+	}
+	if JournalFsync {
+		_ = syncFile(f)
+	}
 }
 
 func (m *Manager) loadJournal() {
@@ -123,15 +209,59 @@ func (m *Manager) loadJournal() {
 				m.jobs[j.ID] = &j
 			}
 		case "delete":
+			if _, ok := m.jobs[rec.ID]; ok {
+				m.expired.add(rec.ID)
+			}
 			delete(m.jobs, rec.ID)
 		}
 	}
 }
 
+// ---------- Resultados en disco (jobsDir/results/<id>.json) ----------
+
+func (m *Manager) resultPath(id string) string {
+	return filepath.Join(m.jobsDir, "results", id+".json")
+}
+
+// saveResult escribe el resp.Result completo de un job (tmp + rename, para
+// no dejar archivos a medias).
+func (m *Manager) saveResult(id string, res *resp.Result) error {
+	path := m.resultPath(id)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadResult lee el resultado persistido; ok=false si no hay.
+func (m *Manager) loadResult(id string) (*resp.Result, bool) {
+	b, err := os.ReadFile(m.resultPath(id))
+	if err != nil {
+		return nil, false
+	}
+	var res resp.Result
+	if json.Unmarshal(b, &res) != nil {
+		return nil, false
+	}
+	return &res, true
+}
+
 // ---------- GC (limpieza de finalizados por TTL) ----------
 
 func (m *Manager) gcLoop() {
-	t := time.NewTicker(time.Minute)
+	every := m.gcEvery
+	if every <= 0 {
+		every = time.Minute
+	}
+	t := time.NewTicker(every)
 	defer t.Stop()
 	for {
 		select {
@@ -143,15 +273,111 @@ func (m *Manager) gcLoop() {
 	}
 }
 
+// MaxJobs es el tope blando de jobs terminales retenidos en memoria
+// (MAX_JOBS). Si se supera, el GC desaloja los que terminaron hace más tiempo
+// aunque no hayan vencido el TTL. 0 = sin tope. Queued/running nunca se tocan.
+var MaxJobs = 10000
+
+func isTerminal(st Status) bool {
+	return st == StatusDone || st == StatusFailed || st == StatusTimeout || st == StatusCanceled
+}
+
 func (m *Manager) cleanup() {
 	cut := time.Now().Add(-m.ttl)
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	var terminal []*Job
 	for id, j := range m.jobs {
-		if (j.Status == StatusDone || j.Status == StatusFailed || j.Status == StatusTimeout || j.Status == StatusCanceled) &&
-			j.EndedAt != nil && j.EndedAt.Before(cut) {
-			delete(m.jobs, id)
-			m.appendJournal(journalRecord{Type: "delete", ID: id})
+		if !isTerminal(j.Status) {
+			continue
+		}
+		if j.EndedAt != nil && j.EndedAt.Before(cut) {
+			m.evictLocked(id)
+			continue
+		}
+		terminal = append(terminal, j)
+	}
+	if MaxJobs > 0 && len(terminal) > MaxJobs {
+		// los más viejos primero (sin EndedAt cuentan como más viejos)
+		sort.Slice(terminal, func(a, b int) bool {
+			ea, eb := terminal[a].EndedAt, terminal[b].EndedAt
+			if ea == nil || eb == nil {
+				return ea == nil && eb != nil
+			}
+			return ea.Before(*eb)
+		})
+		for _, j := range terminal[:len(terminal)-MaxJobs] {
+			m.evictLocked(j.ID)
+		}
+	}
+	m.mu.Unlock()
+	m.cleanupSchedules()
+}
+
+// evictLocked quita un job (con m.mu tomado), lo journalea y borra su resultado.
+func (m *Manager) evictLocked(id string) {
+	delete(m.jobs, id)
+	m.expired.add(id)
+	m.appendJournal(journalRecord{Type: "delete", ID: id})
+	_ = os.Remove(m.resultPath(id))
+}
+
+// MaxExpiredIDs acota cuántos ids limpiados se recuerdan para responder 410
+// en vez de 404; al llenarse se olvidan los más viejos.
+var MaxExpiredIDs = 4096
+
+// expiredSet es un conjunto FIFO acotado de ids de jobs ya limpiados.
+type expiredSet struct {
+	ids   map[string]struct{}
+	order []string
+}
+
+func (s *expiredSet) add(id string) {
+	if s.ids == nil {
+		s.ids = make(map[string]struct{})
+	}
+	if _, ok := s.ids[id]; ok {
+		return
+	}
+	s.ids[id] = struct{}{}
+	s.order = append(s.order, id)
+	for MaxExpiredIDs > 0 && len(s.order) > MaxExpiredIDs {
+		delete(s.ids, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+func (s *expiredSet) has(id string) bool {
+	_, ok := s.ids[id]
+	return ok
+}
+
+// Expired indica si id fue un job que existió y ya lo limpió el GC.
+func (m *Manager) Expired(id string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.expired.has(id)
+}
+
+// cleanupSchedules olvida los schedules que ya no van a enviar nada y cuyos
+// jobs ya fueron limpiados.
+func (m *Manager) cleanupSchedules() {
+	m.smu.Lock()
+	defer m.smu.Unlock()
+	for sid, sc := range m.schedules {
+		if !sc.canceled && !sc.finished {
+			continue
+		}
+		alive := false
+		m.mu.RLock()
+		for _, id := range sc.jobIDs[:sc.created] {
+			if _, ok := m.jobs[id]; ok {
+				alive = true
+				break
+			}
+		}
+		m.mu.RUnlock()
+		if !alive {
+			delete(m.schedules, sid)
 		}
 	}
 }
@@ -160,25 +386,79 @@ func (m *Manager) cleanup() {
 
 // Submit encola la ejecución en el Pool del "task" y devuelve ID.
 // Si el pool no existe, devuelve "".
+// Si params trae "callback_url", no se pasa a la tarea: se guarda en el Job
+// y al terminar se notifica el resultado por POST (ver notifyCallback).
+// Con "parent"=JOBID los params del job padre sirven de base y los explícitos
+// los pisan; el vínculo queda en Job.ParentID.
 func (m *Manager) Submit(task string, params map[string]string, execTimeout time.Duration) string {
+    return m.submit(util.NewReqID(), "", task, params, execTimeout)
+}
+
+// submit es Submit con el id ya elegido y el schedule que lo origina ("" si
+// no viene de SubmitRepeat).
+func (m *Manager) submit(id, scheduleID, task string, params map[string]string, execTimeout time.Duration) string {
     if _, ok := m.sched.Pool(task); !ok {
         return ""
     }
 
-    id := util.NewReqID()
     now := time.Now()
 
+    // Opciones del manager (no llegan al handler): callback_url, group, group_limit, parent, tags.
+    cb := params["callback_url"]
+    group := params["group"]
+    parent := params["parent"]
+    tags := parseTags(params["tags"])
+    var sem chan struct{}
+    var limit int
+    if group != "" {
+        var err error
+        limit, err = strconv.Atoi(params["group_limit"])
+        if err != nil || limit < 1 {
+            limit = 1
+        }
+        sem = m.groupSem(group, limit)
+        limit = cap(sem) // el efectivo, si el grupo ya existía
+    }
+    if cb != "" || group != "" || params["group_limit"] != "" || parent != "" || params["tags"] != "" {
+        cp := make(map[string]string, len(params))
+        if parent != "" {
+            m.mu.RLock()
+            if pj, ok := m.jobs[parent]; ok {
+                for k, v := range pj.Params {
+                    cp[k] = v
+                }
+            }
+            m.mu.RUnlock()
+        }
+        for k, v := range params {
+            if k != "callback_url" && k != "group" && k != "group_limit" && k != "parent" && k != "tags" {
+                cp[k] = v
+            }
+        }
+        params = cp
+    }
+
     // contexto + cancel por job
     ctx, cancel := context.WithCancel(context.Background())
 
     job := &Job{
-        ID:         id,
-        Task:       task,
-        Params:     params,
-        Status:     StatusQueued,
-        EnqueuedAt: now,
-        cancel:     cancel,
+        ID:          id,
+        Task:        task,
+        Params:      params,
+        Status:      StatusQueued,
+        EnqueuedAt:  now,
+        CallbackURL: cb,
+        Group:       group,
+        GroupLimit:  limit,
+        ParentID:    parent,
+        ScheduleID:  scheduleID,
+        Tags:        tags,
+        cancel:      cancel,
+        execTimeout: execTimeout,
+        done:        make(chan struct{}),
+        prog:        &progressSink{},
     }
+    ctx = util.WithProgress(ctx, job.prog.report)
     m.mu.Lock()
     m.jobs[id] = job
     m.mu.Unlock()
@@ -186,8 +466,22 @@ func (m *Manager) Submit(task string, params map[string]string, execTimeout time
 
     // Ejecuta en background.
     go func() {
+        defer close(job.done)
+        if sem != nil {
+            defer m.releaseGroup(group)
+        }
         p, _ := m.sched.Pool(task)
 
+        // Con group: espera turno en el semáforo (el job sigue "queued").
+        if sem != nil {
+            select {
+            case sem <- struct{}{}:
+                defer func() { <-sem }()
+            case <-ctx.Done():
+                // lo resuelve el chequeo de cancelación de abajo
+            }
+        }
+
         // Si fue cancelado antes de arrancar, cerrar como canceled.
         select {
         case <-ctx.Done():
@@ -211,6 +505,8 @@ func (m *Manager) Submit(task string, params map[string]string, execTimeout time
         // Ejecuta respetando el contexto (scheduler debe pasar ctx a la TaskFunc)
         res, enq := p.SubmitAndWaitCtx(ctx, id, params, execTimeout)
         end := time.Now()
+        // el resultado completo va a results/<id>.json, no al journal
+        saved := m.saveResult(id, &res) == nil
 
         m.mu.Lock()
         defer m.mu.Unlock()
@@ -219,7 +515,9 @@ func (m *Manager) Submit(task string, params map[string]string, execTimeout time
 
         switch {
         case !enq:
-            job.Status = StatusFailed // backpressure al encolar
+            // no se encoló: Result.Err.Code dice por qué ("backpressure"
+            // o "draining" si el pool se está drenando)
+            job.Status = StatusFailed
         case res.Err != nil && res.Err.Code == "canceled":
             job.Status = StatusCanceled
         case res.Err != nil && res.Err.Code == "timeout":
@@ -229,12 +527,283 @@ func (m *Manager) Submit(task string, params map[string]string, execTimeout time
         default:
             job.Status = StatusFailed
         }
-        m.appendJournal(journalRecord{Type: "upsert", Job: job})
+        rec := job
+        if saved {
+            cp := *job
+            cp.Result = nil
+            rec = &cp
+        }
+        m.appendJournal(journalRecord{Type: "upsert", Job: rec})
+
+        // El webhook corre aparte: un receptor lento no debe frenar al worker.
+        if job.CallbackURL != "" {
+            payload := resultPayload(job)
+            payload["id"] = job.ID
+            payload["task"] = job.Task
+            b, _ := json.Marshal(payload)
+            go notifyCallback(job.CallbackURL, b)
+        }
     }()
 
     return id
 }
 
+// parseTags separa "a, b,,a" en ["a","b"]: sin vacíos ni repetidos, en orden.
+func parseTags(s string) []string {
+    var out []string
+    seen := map[string]bool{}
+    for _, t := range strings.Split(s, ",") {
+        t = strings.TrimSpace(t)
+        if t == "" || seen[t] {
+            continue
+        }
+        seen[t] = true
+        out = append(out, t)
+    }
+    return out
+}
+
+// hasTag indica si el job lleva la etiqueta tag.
+func (j *Job) hasTag(tag string) bool {
+    for _, t := range j.Tags {
+        if t == tag {
+            return true
+        }
+    }
+    return false
+}
+
+// SubmitBatch encola count jobs idénticos de task (cada uno con su copia de
+// params) y devuelve sus ids en orden. nil si el pool no existe: no se crea
+// ninguno.
+func (m *Manager) SubmitBatch(task string, params map[string]string, count int, execTimeout time.Duration) []string {
+    if _, ok := m.sched.Pool(task); !ok {
+        return nil
+    }
+    ids := make([]string, 0, count)
+    for i := 0; i < count; i++ {
+        cp := make(map[string]string, len(params))
+        for k, v := range params {
+            cp[k] = v
+        }
+        if id := m.Submit(task, cp, execTimeout); id != "" {
+            ids = append(ids, id)
+        }
+    }
+    return ids
+}
+
+// Resubmit vuelve a ejecutar un job finalizado (done/failed/timeout/canceled)
+// como job nuevo, con la misma task, params y opciones (callback, grupo,
+// parent, tags), y devuelve el id nuevo.
+// Si no se puede, devuelve el código de error con ok=false: "not_found",
+// "not_retryable" (sigue queued/running) o "no_pool" (el pool ya no existe).
+func (m *Manager) Resubmit(id string) (string, bool) {
+    m.mu.RLock()
+    j, ok := m.jobs[id]
+    if !ok {
+        m.mu.RUnlock()
+        return "not_found", false
+    }
+    if j.Status == StatusQueued || j.Status == StatusRunning {
+        m.mu.RUnlock()
+        return "not_retryable", false
+    }
+    task, timeout := j.Task, j.execTimeout
+    params := make(map[string]string, len(j.Params)+5)
+    for k, v := range j.Params {
+        params[k] = v
+    }
+    // submit sacó las opciones de params y las guardó en el job
+    if j.CallbackURL != "" {
+        params["callback_url"] = j.CallbackURL
+    }
+    if j.Group != "" {
+        params["group"] = j.Group
+        if j.GroupLimit > 0 {
+            params["group_limit"] = strconv.Itoa(j.GroupLimit)
+        }
+    }
+    if j.ParentID != "" {
+        params["parent"] = j.ParentID
+    }
+    if len(j.Tags) > 0 {
+        params["tags"] = strings.Join(j.Tags, ",")
+    }
+    m.mu.RUnlock()
+
+    nid := m.Submit(task, params, timeout)
+    if nid == "" {
+        return "no_pool", false
+    }
+    return nid, true
+}
+
+// ---------- Envíos periódicos (repeat_every_ms / repeat_count) ----------
+
+// schedule re-envía el mismo job count veces cada every. Los ids se eligen al
+// crearlo: los primeros created ya existen, el resto está planeado.
+type schedule struct {
+    id       string
+    task     string
+    every    time.Duration
+    jobIDs   []string
+    created  int
+    canceled bool
+    finished bool // no quedan envíos (completo o el pool desapareció)
+    stop     chan struct{}
+}
+
+// SubmitRepeat crea un schedule: envía el primer job en el acto y los
+// count-1 restantes cada every, cada uno como job distinto con ScheduleID.
+// Devuelve el id del schedule y todos los ids (creados y planeados).
+// Si el pool no existe devuelve "" y nil sin crear nada.
+func (m *Manager) SubmitRepeat(task string, params map[string]string, every time.Duration, count int, execTimeout time.Duration) (string, []string) {
+    if _, ok := m.sched.Pool(task); !ok || count < 1 {
+        return "", nil
+    }
+    sc := &schedule{
+        id:     util.NewReqID(),
+        task:   task,
+        every:  every,
+        jobIDs: make([]string, count),
+        stop:   make(chan struct{}),
+    }
+    for i := range sc.jobIDs {
+        sc.jobIDs[i] = util.NewReqID()
+    }
+    m.smu.Lock()
+    if m.schedules == nil {
+        m.schedules = make(map[string]*schedule)
+    }
+    m.schedules[sc.id] = sc
+    m.smu.Unlock()
+
+    // cada envío con su copia de params
+    fire := func(i int) bool {
+        cp := make(map[string]string, len(params))
+        for k, v := range params {
+            cp[k] = v
+        }
+        m.smu.Lock()
+        defer m.smu.Unlock()
+        if sc.canceled {
+            return false
+        }
+        if m.submit(sc.jobIDs[i], sc.id, task, cp, execTimeout) == "" {
+            return false // el pool desapareció
+        }
+        sc.created = i + 1
+        return true
+    }
+    finish := func() {
+        m.smu.Lock()
+        sc.finished = true
+        m.smu.Unlock()
+    }
+    ids := append([]string(nil), sc.jobIDs...)
+    if !fire(0) || count == 1 {
+        finish()
+        return sc.id, ids
+    }
+
+    go func() {
+        defer finish()
+        t := time.NewTicker(every)
+        defer t.Stop()
+        for i := 1; i < count; i++ {
+            select {
+            case <-t.C:
+                if !fire(i) {
+                    return
+                }
+            case <-sc.stop:
+                return
+            case <-m.stopC:
+                return
+            }
+        }
+    }()
+    return sc.id, ids
+}
+
+// CancelSchedule frena los envíos futuros del schedule (los jobs ya creados
+// siguen su curso; se cancelan con Cancel). Devuelve cuántos se llegaron a
+// crear; ok=false si el schedule no existe.
+func (m *Manager) CancelSchedule(id string) (created int, ok bool) {
+    m.smu.Lock()
+    defer m.smu.Unlock()
+    sc, ok := m.schedules[id]
+    if !ok {
+        return 0, false
+    }
+    if !sc.canceled {
+        sc.canceled = true
+        close(sc.stop)
+    }
+    return sc.created, true
+}
+
+// ScheduleJSON describe el schedule:
+//   {"schedule_id","task","every_ms","count","created","canceled","finished","job_ids":[...]}
+// job_ids incluye sólo los ya creados.
+func (m *Manager) ScheduleJSON(id string) (string, bool) {
+    m.smu.Lock()
+    defer m.smu.Unlock()
+    sc, ok := m.schedules[id]
+    if !ok {
+        return "", false
+    }
+    b, _ := json.Marshal(map[string]any{
+        "schedule_id": sc.id,
+        "task":        sc.task,
+        "every_ms":    sc.every.Milliseconds(),
+        "count":       len(sc.jobIDs),
+        "created":     sc.created,
+        "canceled":    sc.canceled,
+        "finished":    sc.finished,
+        "job_ids":     sc.jobIDs[:sc.created],
+    })
+    return string(b), true
+}
+
+// groupState es el semáforo de un grupo y cuántos jobs suyos están en vuelo
+// (queued o running).
+type groupState struct {
+    sem  chan struct{}
+    jobs int
+}
+
+// groupSem devuelve (creando si hace falta) el semáforo del grupo y cuenta un
+// job en vuelo; cada llamada se compensa con releaseGroup. El límite lo fija
+// el job que crea el grupo: mientras tenga jobs en vuelo, otro group_limit se
+// ignora.
+func (m *Manager) groupSem(name string, limit int) chan struct{} {
+    m.gmu.Lock()
+    defer m.gmu.Unlock()
+    if m.groups == nil {
+        m.groups = make(map[string]*groupState)
+    }
+    g, ok := m.groups[name]
+    if !ok {
+        g = &groupState{sem: make(chan struct{}, limit)}
+        m.groups[name] = g
+    }
+    g.jobs++
+    return g.sem
+}
+
+// releaseGroup descuenta un job en vuelo del grupo y lo borra si queda vacío.
+func (m *Manager) releaseGroup(name string) {
+    m.gmu.Lock()
+    defer m.gmu.Unlock()
+    if g, ok := m.groups[name]; ok {
+        if g.jobs--; g.jobs <= 0 {
+            delete(m.groups, name)
+        }
+    }
+}
+
 // Cancel intenta cancelar: si está queued → canceled; si running/done → not_cancelable.
 func (m *Manager) Cancel(id string) (string, bool) {
     m.mu.Lock()
@@ -273,48 +842,105 @@ func (m *Manager) Cancel(id string) (string, bool) {
 }
 
 
+// Wait bloquea hasta que el job termine o venza timeout.
+// Devuelve false si el id no existe.
+func (m *Manager) Wait(id string, timeout time.Duration) bool {
+    m.mu.RLock()
+    j, ok := m.jobs[id]
+    m.mu.RUnlock()
+    if !ok {
+        return false
+    }
+    if j.done == nil { // re-hidratado del journal: ya es terminal
+        return true
+    }
+    t := time.NewTimer(timeout)
+    defer t.Stop()
+    select {
+    case <-j.done:
+    case <-t.C:
+    }
+    return true
+}
+
 // SnapshotJSON devuelve el estado del job con progress/eta si es posible.
 func (m *Manager) SnapshotJSON(id string) (string, bool) {
+	// copia con el lock: el worker escribe Status/Result/... bajo m.mu
 	m.mu.RLock()
 	j, ok := m.jobs[id]
+	var cp Job
+	if ok {
+		cp = *j
+	}
 	m.mu.RUnlock()
 	if !ok {
 		return "", false
 	}
-	cp := *j
 	cp.Progress, cp.ETAMs = deriveProgressETA(&cp)
 	b, _ := json.Marshal(cp)
 	return string(b), true
 }
 
+// SnapshotsJSON devuelve un arreglo con el snapshot de cada id (en el mismo
+// orden). Los ids desconocidos aparecen como {"id":..., "error":"not_found"}.
+func (m *Manager) SnapshotsJSON(ids []string) string {
+	out := make([]json.RawMessage, 0, len(ids))
+	for _, id := range ids {
+		if js, ok := m.SnapshotJSON(id); ok {
+			out = append(out, json.RawMessage(js))
+			continue
+		}
+		b, _ := json.Marshal(map[string]string{"id": id, "error": "not_found"})
+		out = append(out, b)
+	}
+	b, _ := json.Marshal(out)
+	return string(b)
+}
+
 // ResultJSON devuelve el JSON del resultado si el job terminó.
 func (m *Manager) ResultJSON(id string) (string, bool, error) {
+    // copia con el lock: el worker escribe Status/Result bajo m.mu
     m.mu.RLock()
     j, ok := m.jobs[id]
+    var cp Job
+    if ok {
+        cp = *j
+    }
     m.mu.RUnlock()
     if !ok {
         return "", false, nil
     }
-    if j.Status == StatusDone || j.Status == StatusFailed || j.Status == StatusTimeout || j.Status == StatusCanceled {
-        // construir respuesta con error si existe
-        out := map[string]any{
-            "status": string(j.Status),
-        }
-        if j.Result != nil {
-            // cuerpo del comando (si lo hubo)
-            if j.Result.Body != "" {
-                out["result"] = j.Result.Body
-            }
-            if j.Result.Err != nil && j.Result.Err.Detail != "" {
-                out["error"] = j.Result.Err.Detail
+    if isTerminal(cp.Status) {
+        // tras un reinicio el journal no trae el Result: se lee de disco
+        if cp.Result == nil {
+            if res, ok := m.loadResult(id); ok {
+                cp.Result = res
             }
         }
-        b, _ := json.Marshal(out)
+        b, _ := json.Marshal(resultPayload(&cp))
         return string(b), true, nil
     }
     return "", true, errors.New("not_ready")
 }
 
+// resultPayload construye {"status","result","error"} de un job finalizado.
+func resultPayload(j *Job) map[string]any {
+    // construir respuesta con error si existe
+    out := map[string]any{
+        "status": string(j.Status),
+    }
+    if j.Result != nil {
+        // cuerpo del comando (si lo hubo)
+        if j.Result.Body != "" {
+            out["result"] = j.Result.Body
+        }
+        if j.Result.Err != nil && j.Result.Err.Detail != "" {
+            out["error"] = j.Result.Err.Detail
+        }
+    }
+    return out
+}
+
 // ListJSON lista jobs activos y recientes.
 func (m *Manager) ListJSON() string {
 	m.mu.RLock()
@@ -332,11 +958,207 @@ func (m *Manager) ListJSON() string {
 	return string(b)
 }
 
+// ListDefaultLimit es el tamaño de página de ListJSONFiltered con limit <= 0.
+const ListDefaultLimit = 100
+
+// ListJSONFiltered lista jobs filtrando por status, task y/o tag (vacío = todos),
+// ordenados por EnqueuedAt descendente (más nuevos primero; a igual instante,
+// por id) y paginados con offset/limit. total cuenta los que pasan el filtro:
+//   {"total":N,"jobs":[{"id","task","status","enqueued_at","tags"}, ...]}
+func (m *Manager) ListJSONFiltered(status, task, tag string, offset, limit int) string {
+	m.mu.RLock()
+	type lite struct {
+		ID         string    `json:"id"`
+		Task       string    `json:"task"`
+		Status     Status    `json:"status"`
+		EnqueuedAt time.Time `json:"enqueued_at"`
+		Tags       []string  `json:"tags,omitempty"`
+	}
+	out := make([]lite, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		if (status != "" && string(j.Status) != status) || (task != "" && j.Task != task) || (tag != "" && !j.hasTag(tag)) {
+			continue
+		}
+		out = append(out, lite{ID: j.ID, Task: j.Task, Status: j.Status, EnqueuedAt: j.EnqueuedAt, Tags: j.Tags})
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(out, func(a, b int) bool {
+		if !out[a].EnqueuedAt.Equal(out[b].EnqueuedAt) {
+			return out[a].EnqueuedAt.After(out[b].EnqueuedAt)
+		}
+		return out[a].ID < out[b].ID
+	})
+	total := len(out)
+	if limit <= 0 {
+		limit = ListDefaultLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	b, _ := json.Marshal(map[string]any{"total": total, "jobs": out[offset:end]})
+	return string(b)
+}
+
+// MetricsJSON resume, por task, los tiempos de punta a punta de los jobs
+// terminados que siguen en memoria (el GC los quita tras el TTL):
+// espera = started_at - enqueued_at, ejecución = ended_at - started_at.
+// Incluye el overhead del manager, no sólo el tiempo dentro del pool.
+//   {"<task>":{"count":N,"avg_wait_ms":x,"avg_run_ms":y}, ...}
+func (m *Manager) MetricsJSON() string {
+	type acc struct {
+		n         int
+		wait, run time.Duration
+	}
+	per := map[string]*acc{}
+	m.mu.RLock()
+	for _, j := range m.jobs {
+		if j.StartedAt == nil || j.EndedAt == nil {
+			continue
+		}
+		a := per[j.Task]
+		if a == nil {
+			a = &acc{}
+			per[j.Task] = a
+		}
+		a.n++
+		a.wait += j.StartedAt.Sub(j.EnqueuedAt)
+		a.run += j.EndedAt.Sub(*j.StartedAt)
+	}
+	m.mu.RUnlock()
+
+	type taskStats struct {
+		Count     int     `json:"count"`
+		AvgWaitMS float64 `json:"avg_wait_ms"`
+		AvgRunMS  float64 `json:"avg_run_ms"`
+	}
+	ms := func(d time.Duration, n int) float64 {
+		return math.Round(float64(d)/float64(n)/1e3) / 1e3 // ms con 3 decimales
+	}
+	out := make(map[string]taskStats, len(per))
+	for task, a := range per {
+		out[task] = taskStats{Count: a.n, AvgWaitMS: ms(a.wait, a.n), AvgRunMS: ms(a.run, a.n)}
+	}
+	b, _ := json.Marshal(out)
+	return string(b)
+}
+
+// ExportMax limita cuántos jobs devuelve ExportJSON (los más recientes).
+var ExportMax = 10000
+
+// ExportJSON vuelca los jobs completos (params, timestamps y result) como
+// arreglo JSON ordenado por enqueued_at. Si hay más de ExportMax, se
+// conservan los más recientes y truncated=true.
+func (m *Manager) ExportJSON() (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]*Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		out = append(out, j)
+	}
+	sort.Slice(out, func(a, b int) bool {
+		if out[a].EnqueuedAt.Equal(out[b].EnqueuedAt) {
+			return out[a].ID < out[b].ID
+		}
+		return out[a].EnqueuedAt.Before(out[b].EnqueuedAt)
+	})
+	truncated := false
+	if ExportMax > 0 && len(out) > ExportMax {
+		out = out[len(out)-ExportMax:]
+		truncated = true
+	}
+	b, _ := json.Marshal(out)
+	return string(b), truncated
+}
+
+// ---------- Webhook de finalización ----------
+
+// callbackClient es el cliente saliente para webhooks (timeout por intento).
+var callbackClient = &http.Client{Timeout: 5 * time.Second}
+
+// callbackRetries y callbackBackoff controlan los reintentos del POST.
+var (
+    callbackRetries = 3
+    callbackBackoff = 500 * time.Millisecond
+)
+
+// ValidateCallbackURL exige una URL absoluta http/https con host.
+func ValidateCallbackURL(raw string) error {
+    u, err := url.Parse(raw)
+    if err != nil {
+        return err
+    }
+    if u.Scheme != "http" && u.Scheme != "https" {
+        return errors.New("callback_url must be http or https")
+    }
+    if u.Host == "" {
+        return errors.New("callback_url must include a host")
+    }
+    return nil
+}
+
+// notifyCallback hace POST del JSON a target; reintenta ante error de red
+// o respuesta no-2xx con backoff lineal. Los fallos finales se descartan.
+func notifyCallback(target string, body []byte) {
+    for attempt := 1; attempt <= callbackRetries; attempt++ {
+        res, err := callbackClient.Post(target, "application/json", bytes.NewReader(body))
+        if err == nil {
+            res.Body.Close()
+            if res.StatusCode >= 200 && res.StatusCode < 300 {
+                return
+            }
+        }
+        if attempt < callbackRetries {
+            time.Sleep(time.Duration(attempt) * callbackBackoff)
+        }
+    }
+}
+
 // ---------- util de progreso/ETA ----------
 
+// Modelos empíricos de duración (ms) para estimar progreso de tareas CPU que
+// no reportan avance: pi es ~cuadrático en digits y sieve ~lineal en limit.
+// Medidos en una máquina de desarrollo; sólo sirven como orden de magnitud.
+const (
+	piChudnovskyMsPerDigit2 = 1.0 / 2.5e6 // 10000 dígitos ≈ 40ms
+	piSpigotMsPerDigit2     = 1.0 / 4e4   // 10000 dígitos ≈ 2.5s
+	sieveMsPerN             = 1.0 / 1.8e5 // limit=1e7 ≈ 55ms
+)
+
 // deriveProgressETA intenta estimar progreso para tareas conocidas.
-// Para "sleep": usa seconds; para otras: nil.
+// Para "sleep": usa seconds. Para "pi" y "sieve" es una estimación por tiempo
+// (transcurrido vs. el modelo de arriba): los handlers no reportan avance
+// intermedio, así que puede errar; nunca pasa de 99 mientras corre.
+// Para otras (o params inválidos): nil.
 func deriveProgressETA(j *Job) (*int, *int64) {
+	// Progreso reportado por el handler (bytes leídos / total, etc.).
+	if j.prog != nil {
+		if done, total := j.prog.load(); total > 0 {
+			switch {
+			case j.Status == StatusDone:
+				p, eta := 100, int64(0)
+				return &p, &eta
+			case j.Status == StatusRunning && j.StartedAt != nil:
+				if done > total {
+					done = total
+				}
+				pct := int(done * 100 / total)
+				var eta int64
+				if done > 0 {
+					el := time.Since(*j.StartedAt)
+					eta = int64(float64(el.Milliseconds()) * float64(total-done) / float64(done))
+				}
+				return &pct, &eta
+			}
+		}
+	}
 	if j.Status != StatusRunning || j.StartedAt == nil {
 		return nil, nil
 	}
@@ -360,8 +1182,56 @@ func deriveProgressETA(j *Job) (*int, *int64) {
 		remain := d - el
 		eta := remain.Milliseconds()
 		return &pct, &eta
+	case "pi":
+		// bbp (index) calcula un solo dígito: no hay modelo
+		if j.Params["method"] == "bbp" || (j.Params["method"] == "" && j.Params["index"] != "") {
+			return nil, nil
+		}
+		d, err := strconv.Atoi(j.Params["digits"])
+		if err != nil || d < 1 {
+			return nil, nil
+		}
+		if d > handlers.PiMaxDigits { // el handler calcula a lo sumo el tope
+			d = handlers.PiMaxDigits
+		}
+		k := piChudnovskyMsPerDigit2
+		if j.Params["method"] == "spigot" {
+			k = piSpigotMsPerDigit2
+		}
+		return estimateProgress(*j.StartedAt, k*float64(d)*float64(d))
+	case "sieve":
+		n, err := strconv.Atoi(j.Params["limit"])
+		if err != nil || n < 2 {
+			return nil, nil
+		}
+		if n > handlers.SieveMaxLimit {
+			n = handlers.SieveMaxLimit
+		}
+		return estimateProgress(*j.StartedAt, sieveMsPerN*float64(n))
 	default:
 		return nil, nil
 	}
 }
 
+// estimateProgress compara lo transcurrido desde started con una duración
+// estimada (ms). El porcentaje queda en [0,99] (el 100 lo da el fin real) y
+// el ETA en 0 si la estimación ya se cumplió.
+func estimateProgress(started time.Time, estMs float64) (*int, *int64) {
+	if estMs < 1 {
+		estMs = 1
+	}
+	el := float64(time.Since(started).Milliseconds())
+	if el < 0 {
+		el = 0
+	}
+	pct := int(el / estMs * 100)
+	if pct > 99 {
+		pct = 99
+	}
+	eta := int64(estMs - el)
+	if eta < 0 {
+		eta = 0
+	}
+	return &pct, &eta
+}
+
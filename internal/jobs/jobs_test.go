@@ -2,16 +2,25 @@ package jobs
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 	"context"
 
+	"so-http10-demo/internal/handlers"
 	"so-http10-demo/internal/resp"
 	"so-http10-demo/internal/sched"
+	"so-http10-demo/internal/util"
 )
 
 /* ------------ helpers ------------ */
@@ -356,6 +365,81 @@ func TestCleanupTTL_RemovesExpired(t *testing.T) {
 	}
 }
 
+func TestCleanup_MaxJobs_EvictsOldestTerminal(t *testing.T) {
+	old := MaxJobs
+	MaxJobs = 3
+	defer func() { MaxJobs = old }()
+
+	m := newMgrForTest(t)
+	m.ttl = time.Hour // que el TTL no intervenga: sólo el tope
+	base := time.Now().Add(-time.Minute)
+	for i := 0; i < 8; i++ {
+		end := base.Add(time.Duration(i) * time.Second)
+		st := StatusDone
+		if i%2 == 1 {
+			st = StatusFailed
+		}
+		id := fmt.Sprintf("t%d", i)
+		m.jobs[id] = &Job{ID: id, Task: "x", Status: st, EndedAt: &end}
+	}
+	m.jobs["q"] = &Job{ID: "q", Task: "x", Status: StatusQueued}
+	m.jobs["r"] = &Job{ID: "r", Task: "x", Status: StatusRunning}
+
+	m.cleanup()
+
+	for _, id := range []string{"t5", "t6", "t7", "q", "r"} {
+		if _, ok := m.jobs[id]; !ok {
+			t.Fatalf("cleanup eliminó %s y no debía", id)
+		}
+	}
+	if len(m.jobs) != 5 {
+		t.Fatalf("quedaron %d jobs, want 5", len(m.jobs))
+	}
+
+	b, err := os.ReadFile(m.journal)
+	if err != nil {
+		t.Fatalf("read journal: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		want := fmt.Sprintf(`"type":"delete","id":"t%d"`, i)
+		if !strings.Contains(string(b), want) {
+			t.Fatalf("journal sin delete de t%d:\n%s", i, b)
+		}
+	}
+}
+
+func TestCleanup_RemembersExpiredIDs_Bounded(t *testing.T) {
+	old := MaxExpiredIDs
+	MaxExpiredIDs = 2
+	defer func() { MaxExpiredIDs = old }()
+
+	m := newMgrForTest(t)
+	end := time.Now().Add(-2 * time.Second)
+	for _, id := range []string{"e1", "e2", "e3"} {
+		j := &Job{ID: id, Task: "x", Status: StatusDone, EndedAt: &end}
+		m.jobs[id] = j
+		m.appendJournal(journalRecord{Type: "upsert", Job: j})
+		m.cleanup()
+	}
+	if m.Expired("e1") {
+		t.Fatalf("e1 debió olvidarse (tope 2)")
+	}
+	if !m.Expired("e2") || !m.Expired("e3") {
+		t.Fatalf("e2/e3 deben figurar como expirados")
+	}
+	if m.Expired("nunca") {
+		t.Fatalf("id desconocido no es expirado")
+	}
+
+	// tras un reinicio, los delete del journal también cuentan
+	m2 := newMgrForTest(t)
+	m2.journal = m.journal
+	m2.loadJournal()
+	if !m2.Expired("e3") {
+		t.Fatalf("delete del journal no se recordó al recargar")
+	}
+}
+
 func TestListJSON(t *testing.T) {
 	m := newMgrForTest(t)
 	m.jobs["a"] = &Job{ID: "a", Task: "sleep", Status: StatusQueued}
@@ -388,6 +472,495 @@ func TestListJSON(t *testing.T) {
 	}
 }
 
+func TestListJSONFiltered_StatusTaskAndPaging(t *testing.T) {
+	m := newMgrForTest(t)
+	t0 := time.Now()
+	// j0 es el más viejo; j9 el más nuevo. Pares done/sleep, impares failed/work.
+	for i := 0; i < 10; i++ {
+		id := fmt.Sprintf("j%d", i)
+		st, task := StatusDone, "sleep"
+		if i%2 == 1 {
+			st, task = StatusFailed, "work"
+		}
+		m.jobs[id] = &Job{ID: id, Task: task, Status: st, EnqueuedAt: t0.Add(time.Duration(i) * time.Second)}
+	}
+
+	type page struct {
+		Total int `json:"total"`
+		Jobs  []struct {
+			ID     string `json:"id"`
+			Status Status `json:"status"`
+		} `json:"jobs"`
+	}
+	list := func(status, task string, offset, limit int) (int, []string) {
+		var p page
+		if err := json.Unmarshal([]byte(m.ListJSONFiltered(status, task, "", offset, limit)), &p); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		ids := make([]string, 0, len(p.Jobs))
+		for _, j := range p.Jobs {
+			ids = append(ids, j.ID)
+		}
+		return p.Total, ids
+	}
+
+	if total, ids := list("failed", "", 0, 0); total != 5 || strings.Join(ids, ",") != "j9,j7,j5,j3,j1" {
+		t.Fatalf("status=failed: total=%d ids=%v", total, ids)
+	}
+	if total, ids := list("done", "work", 0, 0); total != 0 || len(ids) != 0 {
+		t.Fatalf("done+work: total=%d ids=%v", total, ids)
+	}
+	if total, ids := list("", "sleep", 0, 0); total != 5 || ids[0] != "j8" {
+		t.Fatalf("task=sleep: total=%d ids=%v", total, ids)
+	}
+
+	// paginado: total no depende de offset/limit
+	if total, ids := list("", "", 3, 4); total != 10 || strings.Join(ids, ",") != "j6,j5,j4,j3" {
+		t.Fatalf("offset=3 limit=4: total=%d ids=%v", total, ids)
+	}
+	if total, ids := list("", "", 8, 5); total != 10 || strings.Join(ids, ",") != "j1,j0" {
+		t.Fatalf("última página: total=%d ids=%v", total, ids)
+	}
+	if total, ids := list("", "", 50, 5); total != 10 || len(ids) != 0 {
+		t.Fatalf("offset fuera de rango: total=%d ids=%v", total, ids)
+	}
+	// jobs siempre es un arreglo (no null) aunque la página venga vacía
+	if js := m.ListJSONFiltered("timeout", "", "", 0, 0); !strings.Contains(js, `"jobs":[]`) {
+		t.Fatalf("página vacía: %s", js)
+	}
+}
+
+func TestSubmit_TagsStoredFilteredAndJournaled(t *testing.T) {
+	m := newMgrForTest(t)
+	m.sched = mkSchedWithPool(t, "work", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.PlainOK("ok")
+	}, 1, 8, true)
+
+	a := m.Submit("work", map[string]string{"tags": "batch1"}, time.Second)
+	b := m.Submit("work", map[string]string{"tags": " batch1 , other,batch1,"}, time.Second)
+	c := m.Submit("work", map[string]string{"tags": "batch2"}, time.Second)
+	d := m.Submit("work", map[string]string{}, time.Second)
+	for _, id := range []string{a, b, c, d} {
+		m.Wait(id, time.Second)
+	}
+
+	m.mu.RLock()
+	tagsB, paramsB := m.jobs[b].Tags, m.jobs[b].Params
+	m.mu.RUnlock()
+	if strings.Join(tagsB, ",") != "batch1,other" {
+		t.Fatalf("tags=%v want [batch1 other]", tagsB)
+	}
+	if _, ok := paramsB["tags"]; ok {
+		t.Fatalf("tags no debería quedar en params: %v", paramsB)
+	}
+
+	var p struct {
+		Total int `json:"total"`
+		Jobs  []struct {
+			ID string `json:"id"`
+		} `json:"jobs"`
+	}
+	if err := json.Unmarshal([]byte(m.ListJSONFiltered("", "", "batch1", 0, 0)), &p); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	ids := map[string]bool{}
+	for _, j := range p.Jobs {
+		ids[j.ID] = true
+	}
+	if p.Total != 2 || !ids[a] || !ids[b] {
+		t.Fatalf("tag=batch1 => %+v, want %s y %s", p, a, b)
+	}
+
+	// el upsert del journal lleva los tags: sobreviven a un reinicio
+	m2 := newMgrForTest(t)
+	m2.journal = m.journal
+	m2.loadJournal()
+	if j, ok := m2.jobs[b]; !ok || strings.Join(j.Tags, ",") != "batch1,other" {
+		t.Fatalf("tags tras rehidratar: %#v", j)
+	}
+}
+
+func TestExportJSON_FullDetailAndCap(t *testing.T) {
+	m := newMgrForTest(t)
+	t0 := time.Now().Add(-time.Minute)
+	end := t0.Add(time.Second)
+	m.jobs["a"] = &Job{ID: "a", Task: "isprime", Params: map[string]string{"n": "97"}, Status: StatusDone,
+		EnqueuedAt: t0, StartedAt: &t0, EndedAt: &end, Result: &resp.Result{Status: 200, Body: `{"is_prime":true}`, JSON: true}}
+	m.jobs["b"] = &Job{ID: "b", Task: "factor", Params: map[string]string{"n": "x"}, Status: StatusFailed,
+		EnqueuedAt: t0.Add(time.Second), Result: &resp.Result{Status: 400, JSON: true, Err: &resp.ErrObj{Code: "n", Detail: "bad"}}}
+
+	js, truncated := m.ExportJSON()
+	if truncated {
+		t.Fatalf("no debería truncar")
+	}
+	var arr []Job
+	if err := json.Unmarshal([]byte(js), &arr); err != nil {
+		t.Fatalf("unmarshal export: %v", err)
+	}
+	if len(arr) != 2 || arr[0].ID != "a" || arr[1].ID != "b" {
+		t.Fatalf("orden/len inesperado: %+v", arr)
+	}
+	a := arr[0]
+	if a.Params["n"] != "97" || a.StartedAt == nil || a.EndedAt == nil || !a.EnqueuedAt.Equal(t0) {
+		t.Fatalf("faltan params/timestamps: %+v", a)
+	}
+	if a.Result == nil || a.Result.Body != `{"is_prime":true}` {
+		t.Fatalf("falta result: %+v", a.Result)
+	}
+	if b := arr[1]; b.Result == nil || b.Result.Err == nil || b.Result.Err.Code != "n" || b.Params["n"] != "x" {
+		t.Fatalf("falta detalle del fallido: %+v", b)
+	}
+
+	// Con tope: sólo los más recientes
+	old := ExportMax
+	ExportMax = 1
+	defer func() { ExportMax = old }()
+	js, truncated = m.ExportJSON()
+	arr = nil
+	_ = json.Unmarshal([]byte(js), &arr)
+	if !truncated || len(arr) != 1 || arr[0].ID != "b" {
+		t.Fatalf("cap: truncated=%v arr=%+v", truncated, arr)
+	}
+}
+
+func TestSubmit_PoolDraining_FailsWithDrainingCode(t *testing.T) {
+	m := newMgrForTest(t)
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	m.sched = mkSchedWithPool(t, "slow", func(ctx context.Context, _ map[string]string) resp.Result {
+		started <- struct{}{}
+		<-release
+		return resp.PlainOK("ok")
+	}, 1, 4, true)
+	p, _ := m.sched.Pool("slow")
+
+	// un job en ejecución mantiene el Drain abierto
+	first := m.Submit("slow", map[string]string{}, 2*time.Second)
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatalf("first job never started")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	drained := make(chan error, 1)
+	go func() { drained <- p.Drain(ctx) }()
+	if !waitUntil(t, time.Second, func() bool { return p.Draining() }) {
+		t.Fatalf("draining flag not set")
+	}
+
+	late := m.Submit("slow", map[string]string{}, time.Second)
+	if !m.Wait(late, time.Second) {
+		t.Fatalf("late job did not finish")
+	}
+	m.mu.RLock()
+	j := m.jobs[late]
+	st, res := j.Status, j.Result
+	m.mu.RUnlock()
+	if st != StatusFailed || res == nil || res.Err == nil || res.Err.Code != "draining" {
+		t.Fatalf("late job => status=%s result=%#v", st, res)
+	}
+
+	close(release)
+	if err := <-drained; err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if !m.Wait(first, time.Second) {
+		t.Fatalf("first job did not finish")
+	}
+}
+
+func TestSubmitBatch_IdsTrackedAndMissingPool(t *testing.T) {
+	m := newMgrForTest(t)
+	m.sched = mkSchedWithPool(t, "echo", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.PlainOK(p["x"])
+	}, 2, 16, true)
+
+	if ids := m.SubmitBatch("nope", map[string]string{}, 3, time.Second); ids != nil || len(m.jobs) != 0 {
+		t.Fatalf("missing pool => ids=%v jobs=%d", ids, len(m.jobs))
+	}
+
+	params := map[string]string{"x": "1"}
+	ids := m.SubmitBatch("echo", params, 4, time.Second)
+	if len(ids) != 4 {
+		t.Fatalf("ids: %v", ids)
+	}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			t.Fatalf("id repetido: %s", id)
+		}
+		seen[id] = true
+		if !m.Wait(id, time.Second) {
+			t.Fatalf("job %s no terminó", id)
+		}
+		m.mu.RLock()
+		j, ok := m.jobs[id]
+		st := j.Status
+		m.mu.RUnlock()
+		if !ok || st != StatusDone {
+			t.Fatalf("job %s: tracked=%v status=%s", id, ok, st)
+		}
+	}
+
+	// cada job tiene su propia copia de params
+	m.mu.RLock()
+	j0, j1 := m.jobs[ids[0]], m.jobs[ids[1]]
+	m.mu.RUnlock()
+	j0.Params["x"] = "changed"
+	if j1.Params["x"] != "1" || params["x"] != "1" {
+		t.Fatalf("params compartidos entre jobs")
+	}
+}
+
+func TestSubmitRepeat_CreatesOverTimeAndCancelStops(t *testing.T) {
+	m := newMgrForTest(t)
+	m.sched = mkSchedWithPool(t, "tick", func(ctx context.Context, _ map[string]string) resp.Result {
+		return resp.PlainOK("ok")
+	}, 1, 8, true)
+
+	if sid, ids := m.SubmitRepeat("nope", map[string]string{}, 20*time.Millisecond, 3, time.Second); sid != "" || ids != nil {
+		t.Fatalf("missing pool => %q %v", sid, ids)
+	}
+
+	byschedule := func(sid string) int {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		n := 0
+		for _, j := range m.jobs {
+			if j.ScheduleID == sid {
+				n++
+			}
+		}
+		return n
+	}
+
+	// count=3: el primero sale en el acto, los otros dos con el intervalo
+	sid, ids := m.SubmitRepeat("tick", map[string]string{"x": "1"}, 30*time.Millisecond, 3, time.Second)
+	if sid == "" || len(ids) != 3 {
+		t.Fatalf("SubmitRepeat => %q %v", sid, ids)
+	}
+	if n := byschedule(sid); n != 1 {
+		t.Fatalf("inmediatamente debe haber 1 job, hay %d", n)
+	}
+	if !waitUntil(t, time.Second, func() bool { return byschedule(sid) == 3 }) {
+		t.Fatalf("jobs creados: %d", byschedule(sid))
+	}
+	for _, id := range ids {
+		if !m.Wait(id, time.Second) {
+			t.Fatalf("job %s no terminó", id)
+		}
+	}
+	time.Sleep(80 * time.Millisecond)
+	if n := byschedule(sid); n != 3 {
+		t.Fatalf("no deben crearse más de 3: %d", n)
+	}
+	var st struct {
+		Created  int      `json:"created"`
+		Finished bool     `json:"finished"`
+		JobIDs   []string `json:"job_ids"`
+	}
+	js, _ := m.ScheduleJSON(sid)
+	_ = json.Unmarshal([]byte(js), &st)
+	if st.Created != 3 || !st.Finished || strings.Join(st.JobIDs, ",") != strings.Join(ids, ",") {
+		t.Fatalf("schedule: %s", js)
+	}
+
+	// cancelación: frena los envíos que faltan
+	sid2, _ := m.SubmitRepeat("tick", map[string]string{}, 40*time.Millisecond, 10, time.Second)
+	if !waitUntil(t, time.Second, func() bool { return byschedule(sid2) >= 2 }) {
+		t.Fatalf("segundo schedule no avanzó")
+	}
+	created, ok := m.CancelSchedule(sid2)
+	if !ok || created < 2 {
+		t.Fatalf("CancelSchedule => %d %v", created, ok)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if n := byschedule(sid2); n != created {
+		t.Fatalf("tras cancelar: creados %d, antes %d", n, created)
+	}
+	if _, ok := m.CancelSchedule("nope"); ok {
+		t.Fatalf("schedule inexistente debe dar ok=false")
+	}
+}
+
+func TestResubmit_FailedRetriesRunningRejected(t *testing.T) {
+	m := newMgrForTest(t)
+	var calls int32
+	release := make(chan struct{})
+	m.sched = mkSchedWithPool(t, "flaky", func(ctx context.Context, p map[string]string) resp.Result {
+		if p["block"] == "1" {
+			<-release
+			return resp.PlainOK("ok")
+		}
+		// falla la primera vez, anda la segunda
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return resp.IntErr("boom", "first run fails")
+		}
+		return resp.PlainOK("n=" + p["n"])
+	}, 2, 8, true)
+
+	if code, ok := m.Resubmit("nope"); ok || code != "not_found" {
+		t.Fatalf("unknown => %q %v", code, ok)
+	}
+
+	id := m.Submit("flaky", map[string]string{"n": "7"}, time.Second)
+	m.Wait(id, time.Second)
+	m.mu.RLock()
+	st := m.jobs[id].Status
+	m.mu.RUnlock()
+	if st != StatusFailed {
+		t.Fatalf("primer intento: %s", st)
+	}
+
+	nid, ok := m.Resubmit(id)
+	if !ok || nid == "" || nid == id {
+		t.Fatalf("Resubmit => %q %v", nid, ok)
+	}
+	if !m.Wait(nid, time.Second) {
+		t.Fatalf("retry no terminó")
+	}
+	m.mu.RLock()
+	nj := m.jobs[nid]
+	st, task, n, body := nj.Status, nj.Task, nj.Params["n"], nj.Result.Body
+	m.mu.RUnlock()
+	if st != StatusDone || task != "flaky" || n != "7" || body != "n=7" {
+		t.Fatalf("retry: status=%s task=%s n=%s body=%q", st, task, n, body)
+	}
+
+	// uno en ejecución no se puede reintentar
+	rid := m.Submit("flaky", map[string]string{"block": "1"}, time.Second)
+	if !waitUntil(t, time.Second, func() bool {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		return m.jobs[rid].Status == StatusRunning
+	}) {
+		t.Fatalf("job no pasó a running")
+	}
+	if code, ok := m.Resubmit(rid); ok || code != "not_retryable" {
+		t.Fatalf("running => %q %v", code, ok)
+	}
+	close(release)
+	m.Wait(rid, time.Second)
+}
+
+func TestResultPersistedAcrossRestart(t *testing.T) {
+	m := newMgrForTest(t)
+	big := strings.Repeat("x", 4096)
+	m.sched = mkSchedWithPool(t, "echo", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.JSONOK(`{"v":"` + big + `"}`)
+	}, 1, 4, true)
+
+	id := m.Submit("echo", map[string]string{}, time.Second)
+	if !m.Wait(id, time.Second) {
+		t.Fatalf("job no terminó")
+	}
+	if _, err := os.Stat(m.resultPath(id)); err != nil {
+		t.Fatalf("result file: %v", err)
+	}
+	// el journal no carga con el cuerpo del resultado
+	if jb, _ := os.ReadFile(m.journal); strings.Contains(string(jb), big) {
+		t.Fatalf("el journal no debe incluir el resultado")
+	}
+
+	// "reinicio": Manager nuevo sobre el mismo directorio
+	m2 := &Manager{
+		jobsDir: m.jobsDir,
+		journal: m.journal,
+		jobs:    make(map[string]*Job),
+		ttl:     m.ttl,
+		stopC:   make(chan struct{}),
+	}
+	m2.loadJournal()
+	body, ok, err := m2.ResultJSON(id)
+	if !ok || err != nil {
+		t.Fatalf("ResultJSON tras reinicio: ok=%v err=%v", ok, err)
+	}
+	var out struct {
+		Status string `json:"status"`
+		Result string `json:"result"`
+	}
+	_ = json.Unmarshal([]byte(body), &out)
+	if out.Status != "done" || !strings.Contains(out.Result, big) {
+		t.Fatalf("resultado tras reinicio: status=%s len=%d", out.Status, len(out.Result))
+	}
+
+	// al limpiarlo por TTL se borra también el archivo
+	m2.mu.Lock()
+	old := time.Now().Add(-time.Hour)
+	m2.jobs[id].EndedAt = &old
+	m2.mu.Unlock()
+	m2.cleanup()
+	if _, err := os.Stat(m2.resultPath(id)); !os.IsNotExist(err) {
+		t.Fatalf("result file tras cleanup: %v", err)
+	}
+}
+
+func TestMetricsJSON_PerTaskAverages(t *testing.T) {
+	m := newMgrForTest(t)
+	t0 := time.Now().Add(-time.Minute)
+	at := func(ms int) *time.Time { x := t0.Add(time.Duration(ms) * time.Millisecond); return &x }
+	// isprime: esperas 10 y 30 ms, ejecuciones 100 y 300 ms => 20 / 200
+	m.jobs["a"] = &Job{ID: "a", Task: "isprime", Status: StatusDone, EnqueuedAt: t0, StartedAt: at(10), EndedAt: at(110)}
+	m.jobs["b"] = &Job{ID: "b", Task: "isprime", Status: StatusFailed, EnqueuedAt: t0, StartedAt: at(30), EndedAt: at(330)}
+	m.jobs["c"] = &Job{ID: "c", Task: "sleep", Status: StatusDone, EnqueuedAt: t0, StartedAt: at(5), EndedAt: at(1005)}
+	// sin terminar o cancelado antes de arrancar: no cuentan
+	m.jobs["d"] = &Job{ID: "d", Task: "isprime", Status: StatusRunning, EnqueuedAt: t0, StartedAt: at(1)}
+	m.jobs["e"] = &Job{ID: "e", Task: "isprime", Status: StatusCanceled, EnqueuedAt: t0, EndedAt: at(1)}
+
+	type st struct {
+		Count     int     `json:"count"`
+		AvgWaitMS float64 `json:"avg_wait_ms"`
+		AvgRunMS  float64 `json:"avg_run_ms"`
+	}
+	var out map[string]st
+	if err := json.Unmarshal([]byte(m.MetricsJSON()), &out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got := out["isprime"]; got != (st{2, 20, 200}) {
+		t.Fatalf("isprime = %+v", got)
+	}
+	if got := out["sleep"]; got != (st{1, 5, 1000}) {
+		t.Fatalf("sleep = %+v", got)
+	}
+	if len(out) != 2 {
+		t.Fatalf("tasks = %v", out)
+	}
+	if js := newMgrForTest(t).MetricsJSON(); js != "{}" {
+		t.Fatalf("sin jobs => {}, got %s", js)
+	}
+}
+
+func TestMetricsJSON_FromRealJobs(t *testing.T) {
+	m := newMgrForTest(t)
+	m.sched = mkSchedWithPool(t, "slow", func(ctx context.Context, _ map[string]string) resp.Result {
+		time.Sleep(30 * time.Millisecond)
+		return resp.PlainOK("ok")
+	}, 1, 8, true)
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		ids = append(ids, m.Submit("slow", map[string]string{}, time.Second))
+	}
+	for _, id := range ids {
+		if !m.Wait(id, 2*time.Second) {
+			t.Fatalf("job %s did not finish", id)
+		}
+	}
+
+	var out map[string]struct {
+		Count     int     `json:"count"`
+		AvgWaitMS float64 `json:"avg_wait_ms"`
+		AvgRunMS  float64 `json:"avg_run_ms"`
+	}
+	_ = json.Unmarshal([]byte(m.MetricsJSON()), &out)
+	got := out["slow"]
+	// un solo worker: cada job corre ~30ms y los siguientes esperan en cola
+	if got.Count != 3 || got.AvgRunMS < 30 || got.AvgRunMS > 1000 || got.AvgWaitMS < 0 {
+		t.Fatalf("slow = %+v", got)
+	}
+}
+
 func TestCancel_NotFoundAndNotCancelable(t *testing.T) {
 	m := newMgrForTest(t)
 
@@ -579,6 +1152,37 @@ func TestAppendJournal_CreateAndAppend(t *testing.T) {
     }
 }
 
+func TestAppendJournal_FsyncFlag(t *testing.T) {
+	m := newMgrForTest(t)
+
+	oldFlag, oldSync := JournalFsync, syncFile
+	defer func() { JournalFsync, syncFile = oldFlag, oldSync }()
+
+	// el seam ve el registro ya escrito y delega en el Sync real
+	var calls []int
+	syncFile = func(f *os.File) error {
+		calls = append(calls, len(readAllLines(t, f.Name())))
+		return f.Sync()
+	}
+
+	// apagado (default): no se sincroniza
+	JournalFsync = false
+	m.appendJournal(journalRecord{Type: "delete", ID: "a"})
+	if len(calls) != 0 {
+		t.Fatalf("Sync no debería invocarse con el flag apagado: %v", calls)
+	}
+
+	JournalFsync = true
+	m.appendJournal(journalRecord{Type: "delete", ID: "b"})
+	m.appendJournal(journalRecord{Type: "delete", ID: "c"})
+	if len(calls) != 2 || calls[0] != 2 || calls[1] != 3 {
+		t.Fatalf("Sync por registro tras escribir: %v", calls)
+	}
+	if lines := readAllLines(t, m.journal); len(lines) != 3 {
+		t.Fatalf("esperaba 3 líneas en journal, got %d", len(lines))
+	}
+}
+
 // ---------- appendJournal: ruta de error (no debe panic/afectar) ----------
 func TestAppendJournal_ErrorPath_NoPanic(t *testing.T) {
     // hacemos que m.journal apunte a un DIRECTORIO para que OpenFile falle.
@@ -1015,3 +1619,393 @@ func TestDeriveProgressETA_Completed(t *testing.T) {
 	}
 }
 
+
+func TestDeriveProgressETA_PiAndSieve_TimeBased(t *testing.T) {
+	at := func(ago time.Duration) *time.Time { x := time.Now().Add(-ago); return &x }
+	cases := []struct {
+		name    string
+		task    string
+		params  map[string]string
+		started *time.Time
+		minPct  int
+		maxPct  int
+	}{
+		// spigot 10000 dígitos ≈ 2.5s: a 1s va ~40%
+		{"pi spigot mitad", "pi", map[string]string{"digits": "10000", "method": "spigot"}, at(time.Second), 20, 60},
+		// chudnovsky 10000 ≈ 40ms: a 1s la estimación se pasó → tope 99
+		{"pi chudnovsky vencido", "pi", map[string]string{"digits": "10000"}, at(time.Second), 99, 99},
+		// sieve 1e7 ≈ 55ms: recién arrancado
+		{"sieve inicio", "sieve", map[string]string{"limit": "10000000"}, at(0), 0, 10},
+		{"sieve vencido", "sieve", map[string]string{"limit": "10000000"}, at(time.Hour), 99, 99},
+		// por encima del tope del handler se estima con el tope (no queda en 0)
+		{"pi spigot sobre el tope", "pi", map[string]string{"digits": "1000000000", "method": "spigot"}, at(time.Second), 20, 60},
+		{"sieve sobre el tope", "sieve", map[string]string{"limit": "1000000000"}, at(time.Hour), 99, 99},
+	}
+	for _, c := range cases {
+		j := &Job{Task: c.task, Status: StatusRunning, StartedAt: c.started, Params: c.params}
+		p, eta := deriveProgressETA(j)
+		if p == nil || eta == nil {
+			t.Fatalf("%s: esperado progreso, got nil", c.name)
+		}
+		if *p < 0 || *p > 100 || *p < c.minPct || *p > c.maxPct {
+			t.Fatalf("%s: pct=%d fuera de [%d,%d]", c.name, *p, c.minPct, c.maxPct)
+		}
+		if *eta < 0 || (*p == 99 && *eta != 0) {
+			t.Fatalf("%s: eta=%d", c.name, *eta)
+		}
+	}
+
+	// params faltantes/inválidos o bbp → nil, como sleep
+	for _, params := range []map[string]string{
+		{},
+		{"digits": "x"},
+		{"digits": "0"},
+		{"method": "bbp", "index": "5"},
+		{"index": "5"},
+	} {
+		j := &Job{Task: "pi", Status: StatusRunning, StartedAt: at(time.Second), Params: params}
+		if p, eta := deriveProgressETA(j); p != nil || eta != nil {
+			t.Fatalf("pi %v: esperado nil", params)
+		}
+	}
+	j := &Job{Task: "sieve", Status: StatusRunning, StartedAt: at(time.Second), Params: map[string]string{"limit": "1"}}
+	if p, eta := deriveProgressETA(j); p != nil || eta != nil {
+		t.Fatalf("sieve limit=1: esperado nil")
+	}
+}
+
+func TestSubmit_CallbackURL_ReceivesResult(t *testing.T) {
+    got := make(chan map[string]any, 1)
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var body map[string]any
+        if r.Method == http.MethodPost && json.NewDecoder(r.Body).Decode(&body) == nil {
+            got <- body
+        }
+        w.WriteHeader(http.StatusNoContent)
+    }))
+    defer srv.Close()
+
+    m := newMgrForTest(t)
+    taskName := "cb"
+    var seen map[string]string
+    m.sched = mkSchedWithPool(t, taskName, func(ctx context.Context, params map[string]string) resp.Result {
+        seen = params
+        return resp.PlainOK("hecho")
+    }, 1, 1, true)
+
+    id := m.Submit(taskName, map[string]string{"x": "1", "callback_url": srv.URL}, time.Second)
+    if id == "" {
+        t.Fatalf("id vacío")
+    }
+
+    select {
+    case body := <-got:
+        if body["id"] != id || body["task"] != taskName || body["status"] != "done" || body["result"] != "hecho" {
+            t.Fatalf("payload del webhook inesperado: %+v", body)
+        }
+    case <-time.After(2 * time.Second):
+        t.Fatalf("el webhook no recibió el POST")
+    }
+    if _, ok := seen["callback_url"]; ok || seen["x"] != "1" {
+        t.Fatalf("callback_url no debe llegar a la tarea: %+v", seen)
+    }
+}
+
+func TestValidateCallbackURL(t *testing.T) {
+    for _, ok := range []string{"http://localhost:9000/hook", "https://example.com/x"} {
+        if err := ValidateCallbackURL(ok); err != nil {
+            t.Fatalf("%q debería ser válida: %v", ok, err)
+        }
+    }
+    for _, bad := range []string{"ftp://x/y", "/relativa", "http://", "::"} {
+        if err := ValidateCallbackURL(bad); err == nil {
+            t.Fatalf("%q debería ser inválida", bad)
+        }
+    }
+}
+
+func TestSubmit_HashFileReportsProgress(t *testing.T) {
+    m := newMgrForTest(t)
+
+    // archivo de 24 MiB en /app/data → 24 lecturas de 1 MiB
+    name := "progress_hash_" + util.NewReqID() + ".bin"
+    fp := filepath.Join("/app/data", name)
+    if err := os.WriteFile(fp, bytes.Repeat([]byte("x"), 24<<20), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    defer os.Remove(fp)
+
+    sm := mkSchedWithPool(t, "hashfile", func(ctx context.Context, params map[string]string) resp.Result {
+        // frena cada reporte para poder observar el avance desde afuera
+        slow := util.WithProgress(ctx, func(done, total int64) {
+            util.ReportProgress(ctx, done, total)
+            time.Sleep(10 * time.Millisecond)
+        })
+        return handlers.HashFileJSONCtx(slow, params)
+    }, 1, 1, true)
+    m.sched = sm
+
+    id := m.Submit("hashfile", map[string]string{"name": name}, 10*time.Second)
+    progressOf := func() (int, string) {
+        js, _ := m.SnapshotJSON(id)
+        var snap struct {
+            Status   string `json:"status"`
+            Progress *int   `json:"progress"`
+        }
+        _ = json.Unmarshal([]byte(js), &snap)
+        if snap.Progress == nil {
+            return -1, snap.Status
+        }
+        return *snap.Progress, snap.Status
+    }
+
+    var seen []int
+    ok := waitUntil(t, 5*time.Second, func() bool {
+        p, st := progressOf()
+        if st == string(StatusRunning) && p >= 0 {
+            seen = append(seen, p)
+        }
+        return st == string(StatusDone)
+    })
+    if !ok {
+        t.Fatalf("job no terminó")
+    }
+    if len(seen) < 2 || seen[len(seen)-1] <= seen[0] {
+        t.Fatalf("progress debería avanzar mientras corre: %v", seen)
+    }
+    if p, _ := progressOf(); p != 100 {
+        t.Fatalf("progress final=%d want 100", p)
+    }
+}
+
+func TestSubmit_MandelbrotReportsRowProgress(t *testing.T) {
+    m := newMgrForTest(t)
+
+    sm := mkSchedWithPool(t, "mandelbrot", func(ctx context.Context, params map[string]string) resp.Result {
+        // frena cada fila para poder observar el avance desde afuera
+        slow := util.WithProgress(ctx, func(done, total int64) {
+            util.ReportProgress(ctx, done, total)
+            time.Sleep(5 * time.Millisecond)
+        })
+        return handlers.MandelbrotJSONCtx(slow, params)
+    }, 1, 1, true)
+    m.sched = sm
+
+    id := m.Submit("mandelbrot", map[string]string{"width": "32", "height": "64", "max_iter": "50"}, 10*time.Second)
+    progressOf := func() (int, string) {
+        js, _ := m.SnapshotJSON(id)
+        var snap struct {
+            Status   string `json:"status"`
+            Progress *int   `json:"progress"`
+        }
+        _ = json.Unmarshal([]byte(js), &snap)
+        if snap.Progress == nil {
+            return -1, snap.Status
+        }
+        return *snap.Progress, snap.Status
+    }
+
+    var seen []int
+    ok := waitUntil(t, 5*time.Second, func() bool {
+        p, st := progressOf()
+        if st == string(StatusRunning) && p >= 0 {
+            seen = append(seen, p)
+        }
+        return st == string(StatusDone)
+    })
+    if !ok {
+        t.Fatalf("job no terminó")
+    }
+    if len(seen) < 2 || seen[len(seen)-1] <= seen[0] {
+        t.Fatalf("progress debería avanzar mientras corre: %v", seen)
+    }
+    for i := 1; i < len(seen); i++ {
+        if seen[i] < seen[i-1] {
+            t.Fatalf("progress retrocedió: %v", seen)
+        }
+    }
+    if p, _ := progressOf(); p != 100 {
+        t.Fatalf("progress final=%d want 100", p)
+    }
+}
+
+func TestSubmit_GroupLimitRunsOneAtATime(t *testing.T) {
+    m := newMgrForTest(t)
+
+    var mu sync.Mutex
+    running, maxRunning := 0, 0
+    sm := mkSchedWithPool(t, "grp", func(ctx context.Context, params map[string]string) resp.Result {
+        mu.Lock()
+        running++
+        if running > maxRunning {
+            maxRunning = running
+        }
+        mu.Unlock()
+        time.Sleep(40 * time.Millisecond)
+        mu.Lock()
+        running--
+        mu.Unlock()
+        return resp.PlainOK("ok")
+    }, 3, 8, true) // el pool admitiría 3 en paralelo
+    m.sched = sm
+
+    var ids []string
+    for i := 0; i < 3; i++ {
+        ids = append(ids, m.Submit("grp", map[string]string{"group": "g1", "group_limit": "1"}, time.Second))
+    }
+
+    ok := waitUntil(t, 2*time.Second, func() bool {
+        m.mu.RLock()
+        defer m.mu.RUnlock()
+        for _, id := range ids {
+            if m.jobs[id].Status != StatusDone {
+                return false
+            }
+        }
+        return true
+    })
+    if !ok {
+        t.Fatalf("jobs del grupo no terminaron")
+    }
+    if maxRunning != 1 {
+        t.Fatalf("group_limit=1 => max concurrentes 1, got %d", maxRunning)
+    }
+    m.mu.RLock()
+    j := m.jobs[ids[0]]
+    m.mu.RUnlock()
+    if j.Group != "g1" || j.Params["group"] != "" || j.Params["group_limit"] != "" {
+        t.Fatalf("group debe registrarse en el job y no llegar al handler: %+v", j)
+    }
+}
+
+func TestSubmit_GroupPrunedWhenIdle_FirstLimitWins(t *testing.T) {
+    m := newMgrForTest(t)
+    release := make(chan struct{})
+    m.sched = mkSchedWithPool(t, "grp", func(ctx context.Context, p map[string]string) resp.Result {
+        if p["block"] == "1" {
+            <-release
+        }
+        return resp.PlainOK("ok")
+    }, 3, 8, true)
+    groups := func() int {
+        m.gmu.Lock()
+        defer m.gmu.Unlock()
+        return len(m.groups)
+    }
+
+    a := m.Submit("grp", map[string]string{"group": "gp", "group_limit": "1", "block": "1"}, 5*time.Second)
+    // mientras el grupo tiene jobs en vuelo, otro group_limit se ignora
+    b := m.Submit("grp", map[string]string{"group": "gp", "group_limit": "3"}, time.Second)
+    m.mu.RLock()
+    limit := m.jobs[b].GroupLimit
+    m.mu.RUnlock()
+    if limit != 1 || groups() != 1 {
+        t.Fatalf("group_limit efectivo=%d grupos=%d", limit, groups())
+    }
+
+    close(release)
+    m.Wait(a, time.Second)
+    m.Wait(b, time.Second)
+    if n := groups(); n != 0 {
+        t.Fatalf("el grupo sin jobs en vuelo debe borrarse, quedan %d", n)
+    }
+
+    // recreado con otro límite
+    c := m.Submit("grp", map[string]string{"group": "gp", "group_limit": "3"}, time.Second)
+    m.mu.RLock()
+    limit = m.jobs[c].GroupLimit
+    m.mu.RUnlock()
+    m.Wait(c, time.Second)
+    if limit != 3 {
+        t.Fatalf("grupo recreado: group_limit=%d", limit)
+    }
+}
+
+func TestResubmit_KeepsGroupLimitAndOptions(t *testing.T) {
+    m := newMgrForTest(t)
+    release := make(chan struct{})
+    m.sched = mkSchedWithPool(t, "grp", func(ctx context.Context, p map[string]string) resp.Result {
+        if p["block"] == "1" {
+            <-release
+        }
+        return resp.PlainOK("ok")
+    }, 3, 8, true) // el pool admitiría 3 en paralelo
+
+    id := m.Submit("grp", map[string]string{"group": "gr", "group_limit": "1", "tags": "a,b"}, time.Second)
+    if !m.Wait(id, time.Second) {
+        t.Fatalf("primer job no terminó")
+    }
+    // otro job del grupo ocupa el único cupo
+    hid := m.Submit("grp", map[string]string{"group": "gr", "group_limit": "1", "block": "1"}, 5*time.Second)
+    if !waitUntil(t, time.Second, func() bool {
+        m.mu.RLock()
+        defer m.mu.RUnlock()
+        return m.jobs[hid].Status == StatusRunning
+    }) {
+        t.Fatalf("job del grupo no pasó a running")
+    }
+
+    nid, ok := m.Resubmit(id)
+    if !ok {
+        t.Fatalf("Resubmit => %q", nid)
+    }
+    time.Sleep(100 * time.Millisecond) // el pool tiene workers libres: sólo el grupo lo frena
+    m.mu.RLock()
+    nj := m.jobs[nid]
+    st, group, limit, tags := nj.Status, nj.Group, nj.GroupLimit, strings.Join(nj.Tags, ",")
+    _, leaked := nj.Params["group"]
+    m.mu.RUnlock()
+    if st != StatusQueued || group != "gr" || limit != 1 || tags != "a,b" || leaked {
+        t.Fatalf("retry: status=%s group=%q limit=%d tags=%q leaked=%v", st, group, limit, tags, leaked)
+    }
+    close(release)
+    m.Wait(hid, time.Second)
+    m.Wait(nid, time.Second)
+}
+
+func TestSubmit_ParentInheritsParams(t *testing.T) {
+    m := newMgrForTest(t)
+
+    seen := make(chan map[string]string, 4)
+    sm := mkSchedWithPool(t, "pi", func(ctx context.Context, params map[string]string) resp.Result {
+        seen <- params
+        return resp.PlainOK("ok")
+    }, 1, 8, true)
+    m.sched = sm
+
+    pid := m.Submit("pi", map[string]string{"digits": "50", "method": "spigot", "callback_url": "http://127.0.0.1:1/x"}, time.Second)
+    <-seen
+    cid := m.Submit("pi", map[string]string{"parent": pid, "method": "chudnovsky"}, time.Second)
+
+    var got map[string]string
+    select {
+    case got = <-seen:
+    case <-time.After(2 * time.Second):
+        t.Fatalf("child job did not run")
+    }
+    want := map[string]string{"digits": "50", "method": "chudnovsky"}
+    if len(got) != len(want) || got["digits"] != "50" || got["method"] != "chudnovsky" {
+        t.Fatalf("params del hijo = %v, want %v", got, want)
+    }
+
+    m.mu.RLock()
+    child := m.jobs[cid]
+    m.mu.RUnlock()
+    if child.ParentID != pid || child.Params["parent"] != "" || child.Params["digits"] != "50" {
+        t.Fatalf("child job: %+v", child)
+    }
+    if s, _ := m.SnapshotJSON(cid); !strings.Contains(s, `"parent_id":"`+pid+`"`) {
+        t.Fatalf("snapshot debe incluir parent_id: %s", s)
+    }
+
+    // padre inexistente: se registra el vínculo, sin params heredados
+    oid := m.Submit("pi", map[string]string{"parent": "nope", "digits": "7"}, time.Second)
+    if got := <-seen; len(got) != 1 || got["digits"] != "7" {
+        t.Fatalf("params sin padre = %v", got)
+    }
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    if m.jobs[oid].ParentID != "nope" {
+        t.Fatalf("ParentID = %q", m.jobs[oid].ParentID)
+    }
+}
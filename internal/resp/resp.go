@@ -9,12 +9,16 @@ type ErrObj struct {
 // Result es el contrato de salida del router.
 // Si JSON=true, Body ya es un JSON serializado.
 // Si Err!=nil, el servidor enviará {"error","detail"} con Status.
+// Si Bytes!=nil, el cuerpo es binario y se envía tal cual con ContentType.
 type Result struct {
 	Status  int
 	Body    string
 	JSON    bool
 	Err     *ErrObj
 	Headers map[string]string // headers extra (X-Worker-Id, etc.)
+
+	Bytes       []byte `json:",omitempty"`
+	ContentType string `json:",omitempty"`
 }
 
 // WithHeader devuelve una copia de Result con un header adicional.
@@ -30,9 +34,16 @@ func (r Result) WithHeader(k, v string) Result {
 
 func PlainOK(body string) Result        { return Result{Status: 200, Body: body, JSON: false} }
 func JSONOK(json string) Result         { return Result{Status: 200, Body: json, JSON: true} }
+func Binary(contentType string, data []byte) Result {
+	return Result{Status: 200, Bytes: data, ContentType: contentType}
+}
 func BadReq(code, d string) Result      { return Result{Status: 400, JSON: true, Err: &ErrObj{code, d}} }
 func NotFound(code, d string) Result    { return Result{Status: 404, JSON: true, Err: &ErrObj{code, d}} }
 func Conflict(code, d string) Result    { return Result{Status: 409, JSON: true, Err: &ErrObj{code, d}} }
+func Gone(code, d string) Result        { return Result{Status: 410, JSON: true, Err: &ErrObj{code, d}} }
+func TooLarge(code, d string) Result    { return Result{Status: 413, JSON: true, Err: &ErrObj{code, d}} }
 func TooMany(code, d string) Result     { return Result{Status: 429, JSON: true, Err: &ErrObj{code, d}} }
 func IntErr(code, d string) Result      { return Result{Status: 500, JSON: true, Err: &ErrObj{code, d}} }
 func Unavail(code, d string) Result     { return Result{Status: 503, JSON: true, Err: &ErrObj{code, d}} }
+func Timeout(code, d string) Result     { return Result{Status: 504, JSON: true, Err: &ErrObj{code, d}} }
+func NoStorage(code, d string) Result   { return Result{Status: 507, JSON: true, Err: &ErrObj{code, d}} }
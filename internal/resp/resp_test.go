@@ -24,6 +24,16 @@ func TestPlainOK_And_JSONOK(t *testing.T) {
 	}
 }
 
+func TestBinary_CarriesBytesAndContentType(t *testing.T) {
+	r := Binary("image/png", []byte{1, 2, 3})
+	if r.Status != 200 || r.JSON || r.Err != nil || r.Body != "" {
+		t.Fatalf("Binary mismatch: %+v", r)
+	}
+	if r.ContentType != "image/png" || len(r.Bytes) != 3 || r.Bytes[2] != 3 {
+		t.Fatalf("Binary payload mismatch: %+v", r)
+	}
+}
+
 // ---------- Constructores: errores ----------
 
 func TestErrorConstructors_Status_JSON_Err(t *testing.T) {
@@ -39,9 +49,13 @@ func TestErrorConstructors_Status_JSON_Err(t *testing.T) {
 		{"BadReq", BadReq("bad", "x"), 400, "bad", "x"},
 		{"NotFound", NotFound("nf", "missing"), 404, "nf", "missing"},
 		{"Conflict", Conflict("conf", "dup"), 409, "conf", "dup"},
+		{"Gone", Gone("expired", "job cleaned up"), 410, "expired", "job cleaned up"},
+		{"TooLarge", TooLarge("big", "too long"), 413, "big", "too long"},
 		{"TooMany", TooMany("rate", "slow down"), 429, "rate", "slow down"},
 		{"IntErr", IntErr("panic", "boom"), 500, "panic", "boom"},
 		{"Unavail", Unavail("canceled", "ctx done"), 503, "canceled", "ctx done"},
+		{"Timeout", Timeout("still_running", "job not finished"), 504, "still_running", "job not finished"},
+		{"NoStorage", NoStorage("insufficient_storage", "disk full"), 507, "insufficient_storage", "disk full"},
 	}
 
 	for _, tt := range tests {
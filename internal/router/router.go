@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"so-http10-demo/internal/handlers"
@@ -22,6 +25,9 @@ import (
 var (
 	cpuTimeout = getDurEnv("TIMEOUT_CPU", 60*time.Second)
 	ioTimeout  = getDurEnv("TIMEOUT_IO", 120*time.Second)
+
+	// syncWaitDefault: espera de /jobs/submit?sync=true sin timeout_ms.
+	syncWaitDefault = 5 * time.Second
 )
 
 func getDurEnv(key string, def time.Duration) time.Duration {
@@ -33,12 +39,33 @@ func getDurEnv(key string, def time.Duration) time.Duration {
 	return def
 }
 
+// rootMessage es el texto de "/" (env ROOT_MESSAGE, default "hola mundo").
+// Se lee en cada request para poder cambiarlo sin recompilar.
+func rootMessage() string {
+	msg := os.Getenv("ROOT_MESSAGE")
+	if msg == "" {
+		return "hola mundo\n"
+	}
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+	return msg
+}
+
 // Manager global para pools.
 var manager = sched.NewManager()
 
 var jobman = jobs.NewManager(manager, 10*time.Minute)
 
-// InitPools registra pools con configuración.
+// ready pasa a 1 cuando InitPools terminó de registrar (y arrancar) los pools;
+// /readyz responde 503 hasta entonces.
+var ready int32
+
+// Ready indica si el router puede atender trabajo (pools + Job Manager).
+func Ready() bool { return atomic.LoadInt32(&ready) == 1 && jobman != nil }
+
+// InitPools registra pools con configuración. Cada pool lleva como timeout por
+// defecto el de su tipo (cpuTimeout/ioTimeout), para envíos sin timeout propio.
 func InitPools(cfg map[string]int) {
 	wSleep := cfg["workers.sleep"]
 	qSleep := cfg["queue.sleep"]
@@ -46,55 +73,283 @@ func InitPools(cfg map[string]int) {
 	qSpin := cfg["queue.spin"]
 
 	// Pools básicos (sleep/spin) que llaman a handlers.* con TaskFunc
-	_ = manager.Register("sleep", sched.NewPool("sleep",
+	_ = manager.Register("sleep", sched.NewPoolWithTimeout("sleep",
 		func(_ context.Context, p map[string]string) resp.Result { return handlers.SleepTask(p) },
-		wSleep, qSleep))
+		wSleep, qSleep, ioTimeout))
 
-	_ = manager.Register("spin", sched.NewPool("spin",
+	_ = manager.Register("spin", sched.NewPoolWithTimeout("spin",
 		func(_ context.Context, p map[string]string) resp.Result { return handlers.SpinTask(p) },
-		wSpin, qSpin))
+		wSpin, qSpin, cpuTimeout))
 
 	// CPU
-	_ = manager.Register("isprime", sched.NewPool("isprime",
+	_ = manager.Register("isprime", sched.NewPoolWithTimeout("isprime",
 		func(ctx context.Context, p map[string]string) resp.Result { return handlers.IsPrimeJSONCtx(ctx, p) },
-		cfg["workers.isprime"], cfg["queue.isprime"]))
+		cfg["workers.isprime"], cfg["queue.isprime"], cpuTimeout))
 
-	_ = manager.Register("factor", sched.NewPool("factor",
+	_ = manager.Register("factor", sched.NewPoolWithTimeout("factor",
 		func(ctx context.Context, p map[string]string) resp.Result { return handlers.FactorJSONCtx(ctx, p) },
-		cfg["workers.factor"], cfg["queue.factor"]))
+		cfg["workers.factor"], cfg["queue.factor"], cpuTimeout))
 
-	_ = manager.Register("pi", sched.NewPool("pi",
+	_ = manager.Register("pi", sched.NewPoolWithTimeout("pi",
 		func(ctx context.Context, p map[string]string) resp.Result { return handlers.PiJSONCtx(ctx, p) },
-		cfg["workers.pi"], cfg["queue.pi"]))
+		cfg["workers.pi"], cfg["queue.pi"], cpuTimeout))
+
+	_ = manager.Register("pidigit", sched.NewPoolWithTimeout("pidigit",
+		func(ctx context.Context, p map[string]string) resp.Result { return handlers.PiDigitJSONCtx(ctx, p) },
+		cfg["workers.pidigit"], cfg["queue.pidigit"], cpuTimeout))
 
-	_ = manager.Register("mandelbrot", sched.NewPool("mandelbrot",
+	_ = manager.Register("mandelbrot", sched.NewPoolWithTimeout("mandelbrot",
 		func(ctx context.Context, p map[string]string) resp.Result { return handlers.MandelbrotJSONCtx(ctx, p) },
-		cfg["workers.mandelbrot"], cfg["queue.mandelbrot"]))
+		cfg["workers.mandelbrot"], cfg["queue.mandelbrot"], cpuTimeout))
 
-	_ = manager.Register("matrixmul", sched.NewPool("matrixmul",
+	_ = manager.Register("julia", sched.NewPoolWithTimeout("julia",
+		func(ctx context.Context, p map[string]string) resp.Result { return handlers.JuliaJSONCtx(ctx, p) },
+		cfg["workers.julia"], cfg["queue.julia"], cpuTimeout))
+
+	_ = manager.Register("matrixmul", sched.NewPoolWithTimeout("matrixmul",
 		func(ctx context.Context, p map[string]string) resp.Result { return handlers.MatrixMulHashCtx(ctx, p) },
-		cfg["workers.matrixmul"], cfg["queue.matrixmul"]))
+		cfg["workers.matrixmul"], cfg["queue.matrixmul"], cpuTimeout))
+
+	_ = manager.Register("determinant", sched.NewPoolWithTimeout("determinant",
+		func(ctx context.Context, p map[string]string) resp.Result { return handlers.DeterminantJSONCtx(ctx, p) },
+		cfg["workers.determinant"], cfg["queue.determinant"], cpuTimeout))
+
+	_ = manager.Register("collatz", sched.NewPoolWithTimeout("collatz",
+		func(ctx context.Context, p map[string]string) resp.Result { return handlers.CollatzJSONCtx(ctx, p) },
+		cfg["workers.collatz"], cfg["queue.collatz"], cpuTimeout))
+
+	_ = manager.Register("sieve", sched.NewPoolWithTimeout("sieve",
+		func(ctx context.Context, p map[string]string) resp.Result { return handlers.SieveJSONCtx(ctx, p) },
+		cfg["workers.sieve"], cfg["queue.sieve"], cpuTimeout))
+
+	_ = manager.Register("primesieve", sched.NewPoolWithTimeout("primesieve",
+		func(ctx context.Context, p map[string]string) resp.Result { return handlers.PrimeSieveJSONCtx(ctx, p) },
+		cfg["workers.primesieve"], cfg["queue.primesieve"], cpuTimeout))
+
+	_ = manager.Register("selftest", sched.NewPoolWithTimeout("selftest",
+		func(ctx context.Context, p map[string]string) resp.Result { return handlers.SelfTestJSONCtx(ctx, p) },
+		cfg["workers.selftest"], cfg["queue.selftest"], cpuTimeout))
+
+	_ = manager.Register("ackermann", sched.NewPoolWithTimeout("ackermann",
+		func(ctx context.Context, p map[string]string) resp.Result { return handlers.AckermannJSONCtx(ctx, p) },
+		cfg["workers.ackermann"], cfg["queue.ackermann"], cpuTimeout))
 
 	// IO
-	_ = manager.Register("wordcount", sched.NewPool("wordcount",
+	_ = manager.Register("wordcount", sched.NewPoolWithTimeout("wordcount",
 		func(ctx context.Context, p map[string]string) resp.Result { return handlers.WordCountJSONCtx(ctx, p) },
-		cfg["workers.wordcount"], cfg["queue.wordcount"]))
+		cfg["workers.wordcount"], cfg["queue.wordcount"], ioTimeout))
 
-	_ = manager.Register("grep", sched.NewPool("grep",
+	_ = manager.Register("grep", sched.NewPoolWithTimeout("grep",
 		func(ctx context.Context, p map[string]string) resp.Result { return handlers.GrepJSONCtx(ctx, p) },
-		cfg["workers.grep"], cfg["queue.grep"]))
+		cfg["workers.grep"], cfg["queue.grep"], ioTimeout))
 
-	_ = manager.Register("hashfile", sched.NewPool("hashfile",
+	_ = manager.Register("hashfile", sched.NewPoolWithTimeout("hashfile",
 		func(ctx context.Context, p map[string]string) resp.Result { return handlers.HashFileJSONCtx(ctx, p) },
-		cfg["workers.hashfile"], cfg["queue.hashfile"]))
+		cfg["workers.hashfile"], cfg["queue.hashfile"], ioTimeout))
 
-	_ = manager.Register("sortfile", sched.NewPool("sortfile",
+	_ = manager.Register("sortfile", sched.NewPoolWithTimeout("sortfile",
 		func(ctx context.Context, p map[string]string) resp.Result { return handlers.SortFileJSONCtx(ctx, p) },
-		cfg["workers.sortfile"], cfg["queue.sortfile"]))
+		cfg["workers.sortfile"], cfg["queue.sortfile"], ioTimeout))
 
-	_ = manager.Register("compress", sched.NewPool("compress",
+	_ = manager.Register("compress", sched.NewPoolWithTimeout("compress",
 		func(ctx context.Context, p map[string]string) resp.Result { return handlers.CompressJSONCtx(ctx, p) },
-		cfg["workers.compress"], cfg["queue.compress"]))
+		cfg["workers.compress"], cfg["queue.compress"], ioTimeout))
+
+	_ = manager.Register("compressprobe", sched.NewPoolWithTimeout("compressprobe",
+		func(ctx context.Context, p map[string]string) resp.Result { return handlers.CompressProbeJSONCtx(ctx, p) },
+		cfg["workers.compressprobe"], cfg["queue.compressprobe"], ioTimeout))
+
+	_ = manager.Register("decompress", sched.NewPoolWithTimeout("decompress",
+		func(ctx context.Context, p map[string]string) resp.Result { return handlers.DecompressJSONCtx(ctx, p) },
+		cfg["workers.decompress"], cfg["queue.decompress"], ioTimeout))
+
+	_ = manager.Register("readfile", sched.NewPoolWithTimeout("readfile",
+		func(ctx context.Context, p map[string]string) resp.Result { return handlers.ReadFileJSONCtx(ctx, p) },
+		cfg["workers.readfile"], cfg["queue.readfile"], ioTimeout))
+
+	_ = manager.Register("copyfile", sched.NewPoolWithTimeout("copyfile",
+		func(ctx context.Context, p map[string]string) resp.Result { return handlers.CopyFileJSONCtx(ctx, p) },
+		cfg["workers.copyfile"], cfg["queue.copyfile"], ioTimeout))
+
+	_ = manager.Register("archive", sched.NewPoolWithTimeout("archive",
+		func(ctx context.Context, p map[string]string) resp.Result { return handlers.ArchiveJSONCtx(ctx, p) },
+		cfg["workers.archive"], cfg["queue.archive"], ioTimeout))
+
+	_ = manager.Register("head", sched.NewPoolWithTimeout("head",
+		func(ctx context.Context, p map[string]string) resp.Result { return handlers.HeadFileJSONCtx(ctx, p) },
+		cfg["workers.head"], cfg["queue.head"], ioTimeout))
+
+	_ = manager.Register("tail", sched.NewPoolWithTimeout("tail",
+		func(ctx context.Context, p map[string]string) resp.Result { return handlers.TailFileJSONCtx(ctx, p) },
+		cfg["workers.tail"], cfg["queue.tail"], ioTimeout))
+
+	atomic.StoreInt32(&ready, 1)
+}
+
+// MaxPoolWorkers acota /pools/resize (cada worker es una goroutine).
+var MaxPoolWorkers = 256
+
+// MaxBatchJobs acota count en /jobs/submit_batch.
+const MaxBatchJobs = 1000
+
+// MaxListLimit acota limit en /jobs/list.
+const MaxListLimit = 1000
+
+// MaxJobWait acota timeout_ms en /jobs/wait (valores mayores se recortan).
+const MaxJobWait = 60 * time.Second
+
+// numericParams lista, por task, los params numéricos que /jobs/submit
+// limpia (trim) y valida antes de encolar: "int" es un entero decimal (sin
+// límite de dígitos, por factor con big=true) y "float" un real.
+var numericParams = map[string]map[string]string{
+	"sleep":       {"seconds": "int"},
+	"spin":        {"seconds": "int"},
+	"isprime":     {"n": "int"},
+	"factor":      {"n": "int"},
+	"pi":          {"digits": "int", "index": "int"},
+	"pidigit":     {"pos": "int"},
+	"mandelbrot":  {"width": "int", "height": "int", "max_iter": "int"},
+	"julia":       {"width": "int", "height": "int", "max_iter": "int", "cre": "float", "cim": "float"},
+	"matrixmul":   {"size": "int", "seed": "int"},
+	"determinant": {"size": "int", "seed": "int"},
+	"collatz":     {"n": "int"},
+	"sieve":       {"limit": "int"},
+	"primesieve":  {"from": "int", "to": "int"},
+	"ackermann":   {"m": "int", "n": "int"},
+}
+
+// normalizeNumeric recorta los params numéricos de task en params (in place)
+// y valida su formato; así " 50 " no falla recién dentro del handler. Los
+// ausentes o vacíos quedan a criterio del handler.
+func normalizeNumeric(task string, params map[string]string) (resp.Result, bool) {
+	for name, kind := range numericParams[task] {
+		v, ok := params[name]
+		if !ok {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		params[name] = v
+		if v == "" {
+			continue
+		}
+		switch kind {
+		case "int":
+			if !isDecimalInt(v) {
+				return resp.BadReq(name, name+" must be an integer"), false
+			}
+		case "float":
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				return resp.BadReq(name, name+" must be a number"), false
+			}
+		}
+	}
+	return resp.Result{}, true
+}
+
+// jobRequest valida lo común a /jobs/submit y /jobs/submit_batch (task,
+// callback_url, group_limit, parent, params numéricos) y arma los params del job sin
+// task ni las claves de control en skip.
+func jobRequest(args map[string]string, skip ...string) (string, map[string]string, resp.Result, bool) {
+	task := args["task"]
+	if task == "" {
+		return "", nil, resp.BadReq("task", "task=<pool_name> required"), false
+	}
+	if cb := args["callback_url"]; cb != "" {
+		if err := jobs.ValidateCallbackURL(cb); err != nil {
+			return "", nil, resp.BadReq("callback_url", err.Error()), false
+		}
+	}
+	if v := args["group_limit"]; v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n < 1 {
+			return "", nil, resp.BadReq("group_limit", "group_limit must be integer >= 1"), false
+		}
+		if args["group"] == "" {
+			return "", nil, resp.BadReq("group", "group required with group_limit"), false
+		}
+	}
+	// parent=JOBID: el Job Manager hereda sus params; debe existir
+	if parent := args["parent"]; parent != "" {
+		if _, ok := jobman.SnapshotJSON(parent); !ok {
+			return "", nil, resp.NotFound("parent", "parent job not found"), false
+		}
+	}
+	params := make(map[string]string, len(args))
+next:
+	for k, v := range args {
+		if k == "task" {
+			continue
+		}
+		for _, s := range skip {
+			if k == s {
+				continue next
+			}
+		}
+		params[k] = v
+	}
+	// params numéricos limpios: el job guarda (y el handler recibe) el valor recortado
+	if r, ok := normalizeNumeric(task, params); !ok {
+		return "", nil, r, false
+	}
+	return task, params, resp.Result{}, true
+}
+
+// jobTimeout comprueba que el pool de task exista y admita jobs, y devuelve
+// el timeout del job: el default del pool (cpuTimeout/ioTimeout según
+// InitPools) o cpuTimeout si no tiene.
+func jobTimeout(task string) (time.Duration, resp.Result, bool) {
+	p, ok := manager.Pool(task)
+	if !ok {
+		return 0, resp.NotFound("no_pool", "pool not found"), false
+	}
+	// pool deshabilitado: rechazamos antes de crear el job (sin journal)
+	if !p.AcceptingNew() {
+		return 0, resp.Unavail("pool_disabled", "pool not accepting new jobs"), false
+	}
+	if t := p.DefaultTimeout(); t > 0 {
+		return t, resp.Result{}, true
+	}
+	return cpuTimeout, resp.Result{}, true
+}
+
+// isDecimalInt acepta [+-]dígitos, de cualquier largo.
+func isDecimalInt(s string) bool {
+	if s[0] == '+' || s[0] == '-' {
+		s = s[1:]
+	}
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// MinRepeatEvery es el intervalo mínimo de repeat_every_ms en /jobs/submit.
+const MinRepeatEvery = 10 * time.Millisecond
+
+// routeHints son los prefijos que se sugieren en el 404 (el listado completo
+// está en /help).
+var routeHints = []string{"/help", "/status", "/metrics", "/jobs/", "/isprime", "/pi", "/grep", "/createfile"}
+
+// notFound arma el 404 de rutas desconocidas. El código "not_found" y el
+// detail "route" no cambian (clientes programáticos); por defecto se agregan
+// "path", "help" y "routes" para descubrir la API. NOTFOUND_HINTS=0 vuelve
+// al 404 mínimo. Se lee en cada request, como ROOT_MESSAGE.
+func notFound(path string) resp.Result {
+	if os.Getenv("NOTFOUND_HINTS") == "0" {
+		return resp.NotFound("not_found", "route")
+	}
+	b, _ := json.Marshal(map[string]any{
+		"error":  "not_found",
+		"detail": "route",
+		"path":   path,
+		"help":   "/help",
+		"routes": routeHints,
+	})
+	return resp.Result{Status: 404, Body: string(b), JSON: true}
 }
 
 // Dispatch resuelve rutas sobre HTTP/1.0 (GET).
@@ -109,9 +364,20 @@ func Dispatch(method, target string) resp.Result {
 	switch path {
 	// Básicas
 	case "/":
-		return resp.PlainOK("hola mundo\n")
+		return resp.PlainOK(rootMessage())
 	case "/help":
 		return handlers.Help()
+	case "/healthz":
+		// liveness: si el proceso responde, está vivo
+		return resp.JSONOK(`{"status":"ok"}`)
+	case "/readyz":
+		if !Ready() {
+			return resp.Unavail("not_ready", "pools not initialized")
+		}
+		return resp.JSONOK(`{"status":"ready"}`)
+	case "/selftest":
+		// KAT de los kernels (escribe un temporal en /app/data): va por su pool
+		r, _ := submitSync("selftest", args, cpuTimeout); return r
 	case "/timestamp":
 		return handlers.Timestamp(nil)
 	case "/reverse":
@@ -130,6 +396,12 @@ func Dispatch(method, target string) resp.Result {
 		return handlers.CreateFile(args)
 	case "/deletefile":
 		return handlers.DeleteFile(args)
+	case "/listfiles":
+		return handlers.ListFilesJSON(args)
+	case "/movefile":
+		return handlers.MoveFileJSON(args)
+	case "/statfile":
+		return handlers.StatFileJSON(args)
 
 	// Pools / simulación
 	case "/sleep":
@@ -165,10 +437,63 @@ func Dispatch(method, target string) resp.Result {
 			}
 		}
 		return resp.PlainOK("ok " + strconv.Itoa(ok) + "/" + strconv.Itoa(n) + "\n")
+	case "/pools/resize":
+		name := args["name"]
+		if name == "" {
+			return resp.BadReq("name", "name=<pool_name> required")
+		}
+		n, err := strconv.Atoi(args["workers"])
+		if err != nil || n < 1 || n > MaxPoolWorkers {
+			return resp.BadReq("workers", "workers must be integer in 1.."+strconv.Itoa(MaxPoolWorkers))
+		}
+		p, ok := manager.Pool(name)
+		if !ok {
+			return resp.NotFound("pool", "unknown pool")
+		}
+		if err := p.Resize(n); err != nil {
+			return resp.Unavail("closed", err.Error())
+		}
+		b, _ := json.Marshal(map[string]any{"pool": name, "workers": p.Workers()})
+		return resp.JSONOK(string(b))
+	case "/pools/pause", "/pools/resume":
+		name := args["name"]
+		if name == "" {
+			return resp.BadReq("name", "name=<pool_name> required")
+		}
+		p, ok := manager.Pool(name)
+		if !ok {
+			return resp.NotFound("pool", "unknown pool")
+		}
+		if path == "/pools/pause" {
+			p.Pause()
+		} else {
+			p.Resume()
+		}
+		b, _ := json.Marshal(map[string]any{"pool": name, "paused": p.Paused()})
+		return resp.JSONOK(string(b))
 
 	// Métricas
 	case "/metrics":
-		return resp.JSONOK(manager.MetricsJSON())
+		// pools + sección "pi_cache" con los contadores de la LRU de /pi
+		var out map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(manager.MetricsJSON()), &out); err != nil || out == nil {
+			out = map[string]json.RawMessage{}
+		}
+		out["pi_cache"], _ = json.Marshal(handlers.PiCacheStats())
+		b, _ := json.Marshal(out)
+		return resp.JSONOK(string(b))
+	case "/metrics/reset":
+		// name=X: sólo ese pool; sin name: todos
+		if name := args["name"]; name != "" {
+			p, ok := manager.Pool(name)
+			if !ok {
+				return resp.NotFound("pool", "unknown pool")
+			}
+			p.ResetMetrics()
+		} else {
+			manager.ResetAll()
+		}
+		return resp.JSONOK(`{"reset":true}`)
 
 	// CPU-bound (todos usan cpuTimeout)
 	case "/isprime":
@@ -177,10 +502,24 @@ func Dispatch(method, target string) resp.Result {
 		r, _ := submitSync("factor", args, cpuTimeout); return r
 	case "/pi":
 		r, _ := submitSync("pi", args, cpuTimeout); return r
+	case "/pidigit":
+		r, _ := submitSync("pidigit", args, cpuTimeout); return r
 	case "/mandelbrot":
 		r, _ := submitSync("mandelbrot", args, cpuTimeout); return r
+	case "/julia":
+		r, _ := submitSync("julia", args, cpuTimeout); return r
 	case "/matrixmul":
 		r, _ := submitSync("matrixmul", args, cpuTimeout); return r
+	case "/determinant":
+		r, _ := submitSync("determinant", args, cpuTimeout); return r
+	case "/collatz":
+		r, _ := submitSync("collatz", args, cpuTimeout); return r
+	case "/sieve":
+		r, _ := submitSync("sieve", args, cpuTimeout); return r
+	case "/primesieve":
+		r, _ := submitSync("primesieve", args, cpuTimeout); return r
+	case "/ackermann":
+		r, _ := submitSync("ackermann", args, cpuTimeout); return r
 
 	// IO-bound (todos usan ioTimeout)
 	case "/wordcount":
@@ -193,30 +532,136 @@ func Dispatch(method, target string) resp.Result {
 		r, _ := submitSync("sortfile", args, ioTimeout); return r
 	case "/compress":
 		r, _ := submitSync("compress", args, ioTimeout); return r
+	case "/compress/probe":
+		r, _ := submitSync("compressprobe", args, ioTimeout); return r
+	case "/decompress":
+		r, _ := submitSync("decompress", args, ioTimeout); return r
+	case "/readfile":
+		r, _ := submitSync("readfile", args, ioTimeout); return r
+	case "/copyfile":
+		r, _ := submitSync("copyfile", args, ioTimeout); return r
+	case "/archive":
+		r, _ := submitSync("archive", args, ioTimeout); return r
+	case "/head":
+		r, _ := submitSync("head", args, ioTimeout); return r
+	case "/tail":
+		r, _ := submitSync("tail", args, ioTimeout); return r
 
 	// Jobs
 	case "/jobs/submit":
-		task := args["task"]
-		if task == "" {
-			return resp.BadReq("task", "task=<pool_name> required")
+		task, params, r, ok := jobRequest(args, "sync", "repeat_every_ms", "repeat_count")
+		if !ok {
+			return r
+		}
+		// sync=true: además de registrar el job, espera hasta timeout_ms
+		// (default syncWaitDefault, máx el timeout del job) y devuelve el resultado inline.
+		syncMode := args["sync"] == "1" || args["sync"] == "true"
+		wait := syncWaitDefault
+		if syncMode {
+			if v := args["timeout_ms"]; v != "" {
+				ms, err := strconv.Atoi(v)
+				if err != nil || ms <= 0 {
+					return resp.BadReq("timeout_ms", "timeout_ms must be integer > 0")
+				}
+				wait = time.Duration(ms) * time.Millisecond
+			}
+		}
+		// repeat_every_ms=N&repeat_count=K: el manager re-envía el job K veces
+		var every time.Duration
+		repeat := 0
+		if args["repeat_every_ms"] != "" || args["repeat_count"] != "" {
+			ms, err := strconv.Atoi(args["repeat_every_ms"])
+			if err != nil || time.Duration(ms)*time.Millisecond < MinRepeatEvery {
+				return resp.BadReq("repeat_every_ms", "repeat_every_ms must be integer >= 10")
+			}
+			repeat, err = strconv.Atoi(args["repeat_count"])
+			if err != nil || repeat < 1 || repeat > MaxBatchJobs {
+				return resp.BadReq("repeat_count", "repeat_count must be integer in [1,1000]")
+			}
+			if syncMode {
+				return resp.BadReq("sync", "sync not supported with repeat")
+			}
+			every = time.Duration(ms) * time.Millisecond
 		}
 		// el timeout lo maneja el Job Manager internamente; aquí sólo encolamos
-		params := make(map[string]string, len(args))
-		for k, v := range args {
-			if k == "task" {
-				continue
+		tout, r, ok := jobTimeout(task)
+		if !ok {
+			return r
+		}
+		if wait > tout {
+			wait = tout
+		}
+		if repeat > 0 {
+			sid, ids := jobman.SubmitRepeat(task, params, every, repeat, tout)
+			if sid == "" {
+				return resp.NotFound("no_pool", "pool not found")
 			}
-			params[k] = v
+			b, _ := json.Marshal(map[string]any{"schedule_id": sid, "job_ids": ids, "count": len(ids)})
+			return resp.JSONOK(string(b))
 		}
-		id := jobman.Submit(task, params, cpuTimeout) // puedes separar por tipo si quieres
+		id := jobman.Submit(task, params, tout)
 		if id == "" {
 			return resp.NotFound("no_pool", "pool not found")
 		}
+		if syncMode {
+			jobman.Wait(id, wait)
+			if body, ok, err := jobman.ResultJSON(id); ok && err == nil {
+				var out map[string]any
+				_ = json.Unmarshal([]byte(body), &out)
+				out["job_id"] = id
+				b, _ := json.Marshal(out)
+				return resp.JSONOK(string(b))
+			}
+			// no terminó a tiempo: 202 con el id para seguir con /jobs/status
+			out := map[string]any{"job_id": id, "status": "running"}
+			b, _ := json.Marshal(out)
+			return resp.Result{Status: 202, Body: string(b), JSON: true}
+		}
 		out := map[string]any{"job_id": id, "status": "queued"}
 		b, _ := json.Marshal(out)
 		return resp.JSONOK(string(b))
 
+	case "/jobs/submit_batch":
+		// count jobs idénticos en un solo round-trip; misma validación que /jobs/submit
+		for _, k := range []string{"sync", "repeat_every_ms", "repeat_count"} {
+			if _, set := args[k]; set {
+				return resp.BadReq(k, k+" not supported with submit_batch")
+			}
+		}
+		task, params, r, ok := jobRequest(args, "count")
+		if !ok {
+			return r
+		}
+		count, err := strconv.Atoi(args["count"])
+		if err != nil || count < 1 || count > MaxBatchJobs {
+			return resp.BadReq("count", "count must be integer in [1,1000]")
+		}
+		// se valida el pool antes de crear ninguno
+		tout, r, ok := jobTimeout(task)
+		if !ok {
+			return r
+		}
+		ids := jobman.SubmitBatch(task, params, count, tout)
+		if ids == nil {
+			return resp.NotFound("no_pool", "pool not found")
+		}
+		b, _ := json.Marshal(map[string]any{"job_ids": ids, "count": len(ids)})
+		return resp.JSONOK(string(b))
+
 	case "/jobs/status":
+		// ids=a,b,c → un solo arreglo de snapshots (not_found por id)
+		if raw := args["ids"]; raw != "" {
+			ids := make([]string, 0, 8)
+			for _, id := range strings.Split(raw, ",") {
+				if id = strings.TrimSpace(id); id != "" {
+					ids = append(ids, id)
+				}
+			}
+			if len(ids) == 0 {
+				return resp.BadReq("ids", "ids must list at least one id")
+			}
+			return resp.JSONOK(jobman.SnapshotsJSON(ids))
+		}
 		id := args["id"]
 		if id == "" {
 			return resp.BadReq("id", "id required")
@@ -233,6 +678,9 @@ func Dispatch(method, target string) resp.Result {
 		}
 		body, ok, err := jobman.ResultJSON(id)
 		if !ok {
+			if jobman.Expired(id) {
+				return resp.Gone("expired", "job was cleaned up")
+			}
 			return resp.NotFound("not_found", "job not found")
 		}
 		if err != nil {
@@ -240,7 +688,44 @@ func Dispatch(method, target string) resp.Result {
 		}
 		return resp.JSONOK(body)
 
+	case "/jobs/wait":
+		// long-poll: bloquea hasta que el job termine o venza timeout_ms
+		id := args["id"]
+		if id == "" {
+			return resp.BadReq("id", "id required")
+		}
+		wait := MaxJobWait
+		if v := args["timeout_ms"]; v != "" {
+			ms, err := strconv.Atoi(v)
+			if err != nil || ms < 0 {
+				return resp.BadReq("timeout_ms", "timeout_ms must be integer >= 0")
+			}
+			if ms < int(MaxJobWait/time.Millisecond) {
+				wait = time.Duration(ms) * time.Millisecond
+			}
+		}
+		if !jobman.Wait(id, wait) {
+			return resp.NotFound("not_found", "job not found")
+		}
+		body, ok, err := jobman.ResultJSON(id)
+		if !ok {
+			return resp.NotFound("not_found", "job not found")
+		}
+		if err != nil {
+			return resp.Timeout("still_running", "job not finished within timeout_ms")
+		}
+		return resp.JSONOK(body)
+
 	case "/jobs/cancel":
+		// schedule=SID frena los envíos futuros de un repeat
+		if sid := args["schedule"]; sid != "" {
+			created, ok := jobman.CancelSchedule(sid)
+			if !ok {
+				return resp.NotFound("not_found", "schedule not found")
+			}
+			b, _ := json.Marshal(map[string]any{"schedule_id": sid, "status": "canceled", "created": created})
+			return resp.JSONOK(string(b))
+		}
 		id := args["id"]
 		if id == "" {
 			return resp.BadReq("id", "id required")
@@ -253,15 +738,71 @@ func Dispatch(method, target string) resp.Result {
 		b, _ := json.Marshal(out)
 		return resp.JSONOK(string(b))
 
+	case "/jobs/retry":
+		id := args["id"]
+		if id == "" {
+			return resp.BadReq("id", "id required")
+		}
+		nid, ok := jobman.Resubmit(id)
+		switch {
+		case ok:
+			b, _ := json.Marshal(map[string]any{"job_id": nid, "from": id})
+			return resp.JSONOK(string(b))
+		case nid == "not_retryable":
+			return resp.Conflict("not_retryable", "job still queued or running")
+		case nid == "no_pool":
+			return resp.NotFound("no_pool", "pool not found")
+		default:
+			return resp.NotFound("not_found", "job not found")
+		}
+
+	case "/jobs/schedule":
+		id := args["id"]
+		if id == "" {
+			return resp.BadReq("id", "id required")
+		}
+		js, ok := jobman.ScheduleJSON(id)
+		if !ok {
+			return resp.NotFound("not_found", "schedule not found")
+		}
+		return resp.JSONOK(js)
+
 	case "/jobs/list":
-		return resp.JSONOK(jobman.ListJSON())
+		// filtros y paginado opcionales: status, task, tag, offset, limit
+		offset, limit := 0, 0
+		if v := args["offset"]; v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return resp.BadReq("offset", "offset must be integer >= 0")
+			}
+			offset = n
+		}
+		if v := args["limit"]; v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > MaxListLimit {
+				return resp.BadReq("limit", "limit must be integer in [1,1000]")
+			}
+			limit = n
+		}
+		return resp.JSONOK(jobman.ListJSONFiltered(args["status"], args["task"], args["tag"], offset, limit))
+
+	case "/jobs/metrics":
+		return resp.JSONOK(jobman.MetricsJSON())
+
+	case "/jobs/export":
+		js, truncated := jobman.ExportJSON()
+		r := resp.JSONOK(js)
+		if truncated {
+			r = r.WithHeader("X-Export-Truncated", "true")
+		}
+		return r
 
 	}
 
 	
 
 
-	return resp.NotFound("not_found", "route")
+	return notFound(path)
 
 	
 }
@@ -276,23 +817,30 @@ func submitSync(name string, args map[string]string, timeout time.Duration) (res
 	return p.SubmitAndWait(args, timeout)
 }
 
-// Close cierra recursos del router (Job Manager).
+// DrainTimeout es el plazo que Close da a los pools para terminar lo
+// encolado y en ejecución antes de cerrarlos.
+var DrainTimeout = 10 * time.Second
+
+// Close drena los pools (ver sched.Pool.Drain) y cierra el Job Manager.
 func Close() {
+	_ = manager.DrainAll(DrainTimeout)
 	if jobman != nil {
 		jobman.Close()
 	}
 }
 
-// PoolsSummary devuelve un mapa resumido por pool para /status (sin ciclo).
-func PoolsSummary() map[string]any {
+// PoolsSummary devuelve el resumen de cada pool para /status (sin ciclo),
+// como arreglo ordenado por nombre para que la salida sea estable.
+func PoolsSummary() []map[string]any {
 	var raw map[string]any
 	_ = json.Unmarshal([]byte(manager.MetricsJSON()), &raw)
 
-	pools := make(map[string]any, len(raw))
+	pools := make([]map[string]any, 0, len(raw))
 	for name, v := range raw {
 		m := v.(map[string]any)
 		w := m["workers"].(map[string]any)
-		pools[name] = map[string]any{
+		pools = append(pools, map[string]any{
+			"name": name,
 			"workers": map[string]any{
 				"total": w["total"],
 				"busy":  w["busy"],
@@ -300,7 +848,10 @@ func PoolsSummary() map[string]any {
 			},
 			"queue_len": m["queue_len"],
 			"queue_cap": m["queue_cap"],
-		}
+		})
 	}
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i]["name"].(string) < pools[j]["name"].(string)
+	})
 	return pools
 }
@@ -3,11 +3,16 @@ package router
 import (
 	"context"
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 	"path/filepath"
 	"os"
+	"sort"
+	"strconv"
+	"sync/atomic"
 
+	"so-http10-demo/internal/handlers"
 	"so-http10-demo/internal/jobs"
 	"so-http10-demo/internal/resp"
 	"so-http10-demo/internal/sched"
@@ -19,6 +24,7 @@ func resetGlobals(t *testing.T) func() {
 	t.Helper()
 	oldMgr := manager
 	oldJM := jobman
+	oldReady := atomic.LoadInt32(&ready)
 
 	manager = sched.NewManager()
 	jobman = jobs.NewManager(manager, time.Minute)
@@ -37,6 +43,7 @@ func resetGlobals(t *testing.T) func() {
 		}
 		manager = oldMgr
 		jobman = oldJM
+		atomic.StoreInt32(&ready, oldReady)
 	}
 }
 
@@ -140,7 +147,7 @@ func TestInitPools_RegistersKeyPools(t *testing.T) {
 	}
 	InitPools(cfg)
 
-	for _, name := range []string{"sleep", "spin", "isprime"} {
+	for _, name := range []string{"sleep", "spin", "isprime", "compressprobe"} {
 		if _, ok := manager.Pool(name); !ok {
 			t.Fatalf("pool %q not registered", name)
 		}
@@ -163,6 +170,700 @@ func TestDispatch_MethodAndBasics(t *testing.T) {
 	}
 }
 
+func TestDispatch_RootMessage_FromEnv(t *testing.T) {
+	t.Setenv("ROOT_MESSAGE", "bienvenido al demo")
+	if r := Dispatch("GET", "/"); r.Status != 200 || r.JSON || r.Body != "bienvenido al demo\n" {
+		t.Fatalf("root with ROOT_MESSAGE: %#v", r)
+	}
+
+	t.Setenv("ROOT_MESSAGE", "")
+	if r := Dispatch("GET", "/"); r.Body != "hola mundo\n" {
+		t.Fatalf("default root must be preserved: %#v", r)
+	}
+}
+
+func TestJobsSubmit_PoolDisabled_NoJobCreated(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+
+	mustRegisterPool(t, "echo", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.PlainOK("ok")
+	}, 1, 4, true)
+	p, _ := manager.Pool("echo")
+	p.SetAcceptingNew(false)
+
+	count := func() int {
+		var arr []map[string]any
+		_ = json.Unmarshal([]byte(jobman.ListJSON()), &arr)
+		return len(arr)
+	}
+	before := count()
+
+	r := Dispatch("GET", "/jobs/submit?task=echo")
+	if r.Status != 503 || r.Err == nil || r.Err.Code != "pool_disabled" {
+		t.Fatalf("disabled pool submit => %#v", r)
+	}
+	if after := count(); after != before {
+		t.Fatalf("no job must be created: before=%d after=%d", before, after)
+	}
+
+	// al rehabilitarlo vuelve a aceptar
+	p.SetAcceptingNew(true)
+	if r := Dispatch("GET", "/jobs/submit?task=echo"); r.Status != 200 {
+		t.Fatalf("re-enabled submit => %#v", r)
+	}
+}
+
+func TestDispatch_HealthzAndReadyz(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+	atomic.StoreInt32(&ready, 0)
+
+	if r := Dispatch("GET", "/healthz"); r.Status != 200 {
+		t.Fatalf("/healthz => %#v", r)
+	}
+	if r := Dispatch("GET", "/readyz"); r.Status != 503 || r.Err == nil || r.Err.Code != "not_ready" {
+		t.Fatalf("/readyz before InitPools => %#v", r)
+	}
+
+	InitPools(map[string]int{})
+	if r := Dispatch("GET", "/readyz"); r.Status != 200 || !strings.Contains(r.Body, "ready") {
+		t.Fatalf("/readyz after InitPools => %#v", r)
+	}
+	if r := Dispatch("GET", "/healthz"); r.Status != 200 {
+		t.Fatalf("/healthz => %#v", r)
+	}
+}
+
+func TestDispatch_CompressProbe_GoesThroughPool(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+	if r := Dispatch("GET", "/compress/probe?name=x"); r.Status != 500 || r.Err == nil || r.Err.Code != "no_pool" {
+		t.Fatalf("/compress/probe sin pool => %#v", r)
+	}
+	mustRegisterPool(t, "compressprobe", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.PlainOK("probe:" + p["name"])
+	}, 1, 2, true)
+	if r := Dispatch("GET", "/compress/probe?name=x"); r.Status != 200 || r.Body != "probe:x" {
+		t.Fatalf("/compress/probe => %#v", r)
+	}
+}
+
+func TestDispatch_SelfTest_AllSubsystemsPass(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+	if r := Dispatch("GET", "/selftest"); r.Status != 500 || r.Err == nil || r.Err.Code != "no_pool" {
+		t.Fatalf("/selftest sin pool => %#v", r)
+	}
+	mustRegisterPool(t, "selftest", func(ctx context.Context, p map[string]string) resp.Result {
+		return handlers.SelfTestJSONCtx(ctx, p)
+	}, 1, 2, true)
+
+	r := Dispatch("GET", "/selftest")
+	var o struct {
+		Pass       bool `json:"pass"`
+		Subsystems []struct {
+			Name string `json:"name"`
+			Pass bool   `json:"pass"`
+		} `json:"subsystems"`
+	}
+	if err := json.Unmarshal([]byte(r.Body), &o); err != nil || r.Status != 200 || !o.Pass {
+		t.Fatalf("/selftest => %d %s (%v)", r.Status, r.Body, err)
+	}
+	seen := map[string]bool{}
+	for _, s := range o.Subsystems {
+		seen[s.Name] = s.Pass
+	}
+	for _, name := range []string{"matrixmul", "pi", "isprime", "sortfile"} {
+		if !seen[name] {
+			t.Fatalf("subsystem %s no pasó: %s", name, r.Body)
+		}
+	}
+}
+
+func TestDispatch_MetricsReset(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+
+	mustRegisterPool(t, "echo", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.PlainOK("ok")
+	}, 1, 4, true)
+	mustRegisterPool(t, "echo2", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.PlainOK("ok")
+	}, 1, 4, true)
+	for _, name := range []string{"echo", "echo2"} {
+		if r, _ := submitSync(name, map[string]string{}, time.Second); r.Status != 200 {
+			t.Fatalf("submit %s: %#v", name, r)
+		}
+	}
+	submitted := func(name string) float64 {
+		var m map[string]map[string]any
+		_ = json.Unmarshal([]byte(manager.MetricsJSON()), &m)
+		return m[name]["submitted"].(float64)
+	}
+
+	if r := Dispatch("GET", "/metrics/reset?name=echo"); r.Status != 200 || r.Body != `{"reset":true}` {
+		t.Fatalf("reset one => %#v", r)
+	}
+	if submitted("echo") != 0 || submitted("echo2") != 1 {
+		t.Fatalf("reset one: echo=%v echo2=%v", submitted("echo"), submitted("echo2"))
+	}
+	if r := Dispatch("GET", "/metrics/reset"); r.Status != 200 || submitted("echo2") != 0 {
+		t.Fatalf("reset all => %#v", r)
+	}
+	if r := Dispatch("GET", "/metrics/reset?name=nope"); r.Status != 404 {
+		t.Fatalf("unknown pool => %#v", r)
+	}
+}
+
+func TestDispatch_PoolsPauseResume(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+
+	mustRegisterPool(t, "echo", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.PlainOK("ok")
+	}, 1, 4, true)
+
+	if r := Dispatch("GET", "/pools/pause?name=echo"); r.Status != 200 || r.Body != `{"paused":true,"pool":"echo"}` {
+		t.Fatalf("pause => %#v", r)
+	}
+	res := make(chan resp.Result, 1)
+	go func() {
+		r, _ := submitSync("echo", map[string]string{}, 2*time.Second)
+		res <- r
+	}()
+	select {
+	case r := <-res:
+		t.Fatalf("paused pool ran the job: %#v", r)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if r := Dispatch("GET", "/pools/resume?name=echo"); r.Status != 200 || r.Body != `{"paused":false,"pool":"echo"}` {
+		t.Fatalf("resume => %#v", r)
+	}
+	select {
+	case r := <-res:
+		if r.Status != 200 {
+			t.Fatalf("job after resume: %#v", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("job did not run after resume")
+	}
+
+	if r := Dispatch("GET", "/pools/pause"); r.Status != 400 {
+		t.Fatalf("missing name => 400: %#v", r)
+	}
+	if r := Dispatch("GET", "/pools/resume?name=nope"); r.Status != 404 {
+		t.Fatalf("unknown pool => 404: %#v", r)
+	}
+}
+
+func TestDispatch_PoolsResize(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+
+	mustRegisterPool(t, "echo", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.PlainOK("ok")
+	}, 1, 4, true)
+
+	workers := func() map[string]any {
+		var m map[string]map[string]any
+		_ = json.Unmarshal([]byte(manager.MetricsJSON()), &m)
+		return m["echo"]["workers"].(map[string]any)
+	}
+
+	r := Dispatch("GET", "/pools/resize?name=echo&workers=4")
+	if r.Status != 200 || !strings.Contains(r.Body, `"workers":4`) {
+		t.Fatalf("resize up => %#v", r)
+	}
+	if w := workers(); w["total"] != float64(4) || w["live"] != float64(4) {
+		t.Fatalf("metrics after grow: %v", w)
+	}
+	if r := Dispatch("GET", "/jobs/submit?task=echo"); r.Status != 200 {
+		t.Fatalf("submit => %#v", r)
+	}
+
+	if r := Dispatch("GET", "/pools/resize?name=echo&workers=2"); r.Status != 200 {
+		t.Fatalf("resize down => %#v", r)
+	}
+	deadline := time.Now().Add(time.Second)
+	for workers()["live"] != float64(2) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if w := workers(); w["total"] != float64(2) || w["live"] != float64(2) {
+		t.Fatalf("metrics after shrink: %v", w)
+	}
+
+	for _, q := range []string{"workers=2", "name=echo", "name=echo&workers=0", "name=echo&workers=x", "name=echo&workers=100000"} {
+		if r := Dispatch("GET", "/pools/resize?"+q); r.Status != 400 {
+			t.Fatalf("%s => 400: %#v", q, r)
+		}
+	}
+	if r := Dispatch("GET", "/pools/resize?name=nope&workers=2"); r.Status != 404 {
+		t.Fatalf("unknown pool => 404: %#v", r)
+	}
+}
+
+func TestJobsSubmit_ParentLink(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+
+	mustRegisterPool(t, "echo", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.PlainOK(p["digits"])
+	}, 1, 4, true)
+
+	if r := Dispatch("GET", "/jobs/submit?task=echo&parent=nope"); r.Status != 404 || r.Err == nil || r.Err.Code != "parent" {
+		t.Fatalf("unknown parent => %#v", r)
+	}
+	r := Dispatch("GET", "/jobs/submit?task=echo&digits=42")
+	var parent map[string]any
+	_ = json.Unmarshal([]byte(r.Body), &parent)
+	pid, _ := parent["job_id"].(string)
+
+	r = Dispatch("GET", "/jobs/submit?task=echo&sync=true&parent="+pid)
+	if r.Status != 200 || !strings.Contains(r.Body, "42") {
+		t.Fatalf("child sync => %#v", r)
+	}
+}
+
+func TestJobsSubmitBatch(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+
+	mustRegisterPool(t, "echo", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.PlainOK(p["digits"])
+	}, 2, 16, true)
+
+	jobCount := func() int {
+		var l []any
+		_ = json.Unmarshal([]byte(jobman.ListJSON()), &l)
+		return len(l)
+	}
+	before := jobCount()
+	for _, q := range []string{"count=0", "count=1001", "count=x", ""} {
+		if r := Dispatch("GET", "/jobs/submit_batch?task=echo&"+q); r.Status != 400 || r.Err == nil || r.Err.Code != "count" {
+			t.Fatalf("%q => %#v", q, r)
+		}
+	}
+	if r := Dispatch("GET", "/jobs/submit_batch?task=nope&count=3"); r.Status != 404 || r.Err == nil || r.Err.Code != "no_pool" {
+		t.Fatalf("missing pool => %#v", r)
+	}
+	if n := jobCount(); n != before {
+		t.Fatalf("no debe crearse ningún job: %d -> %d", before, n)
+	}
+
+	r := Dispatch("GET", "/jobs/submit_batch?task=echo&count=5&digits=7")
+	var out struct {
+		JobIDs []string `json:"job_ids"`
+		Count  int      `json:"count"`
+	}
+	if err := json.Unmarshal([]byte(r.Body), &out); err != nil || r.Status != 200 {
+		t.Fatalf("batch => %#v", r)
+	}
+	if out.Count != 5 || len(out.JobIDs) != 5 {
+		t.Fatalf("ids: %+v", out)
+	}
+	for _, id := range out.JobIDs {
+		if !jobman.Wait(id, time.Second) {
+			t.Fatalf("job %s no terminó", id)
+		}
+		if r := Dispatch("GET", "/jobs/result?id="+id); r.Status != 200 || !strings.Contains(r.Body, "7") {
+			t.Fatalf("result %s => %#v", id, r)
+		}
+	}
+}
+
+func TestJobsSubmitBatch_SharedValidation(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+
+	mustRegisterPool(t, "isprime", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.PlainOK("n=" + p["n"])
+	}, 1, 8, true)
+
+	// mismas reglas que /jobs/submit
+	cases := map[string]string{
+		"group_limit=0&group=g": "group_limit",
+		"group_limit=2":         "group",
+		"n=abc":                 "n",
+	}
+	for q, code := range cases {
+		for _, route := range []string{"/jobs/submit?task=isprime&", "/jobs/submit_batch?task=isprime&count=2&"} {
+			if r := Dispatch("GET", route+q); r.Status != 400 || r.Err == nil || r.Err.Code != code {
+				t.Fatalf("%s%s => %#v", route, q, r)
+			}
+		}
+	}
+
+	for _, route := range []string{"/jobs/submit?task=isprime&", "/jobs/submit_batch?task=isprime&count=2&"} {
+		if r := Dispatch("GET", route+"parent=nope"); r.Status != 404 || r.Err == nil || r.Err.Code != "parent" {
+			t.Fatalf("%sparent=nope => %#v", route, r)
+		}
+	}
+	// claves que sólo tienen sentido en /jobs/submit
+	for _, k := range []string{"sync=1", "repeat_every_ms=50", "repeat_count=2"} {
+		code := k[:strings.IndexByte(k, '=')]
+		if r := Dispatch("GET", "/jobs/submit_batch?task=isprime&count=2&"+k); r.Status != 400 || r.Err == nil || r.Err.Code != code {
+			t.Fatalf("batch %s => %#v", k, r)
+		}
+	}
+
+	r := Dispatch("GET", "/jobs/submit_batch?task=isprime&count=1&n=+50+")
+	var out struct {
+		JobIDs []string `json:"job_ids"`
+	}
+	if err := json.Unmarshal([]byte(r.Body), &out); err != nil || len(out.JobIDs) != 1 {
+		t.Fatalf("batch => %#v", r)
+	}
+	jobman.Wait(out.JobIDs[0], time.Second)
+	if r := Dispatch("GET", "/jobs/result?id="+out.JobIDs[0]); !strings.Contains(r.Body, "n=50") {
+		t.Fatalf("n sin normalizar: %#v", r)
+	}
+
+	// el job usa el timeout por defecto del pool, no siempre cpuTimeout
+	release := make(chan struct{})
+	defer close(release)
+	p := sched.NewPoolWithTimeout("slowio", func(ctx context.Context, _ map[string]string) resp.Result {
+		<-release
+		return resp.PlainOK("late")
+	}, 1, 4, 50*time.Millisecond)
+	p.Start()
+	if err := manager.Register("slowio", p); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	r = Dispatch("GET", "/jobs/submit_batch?task=slowio&count=1")
+	if err := json.Unmarshal([]byte(r.Body), &out); err != nil || len(out.JobIDs) != 1 {
+		t.Fatalf("batch => %#v", r)
+	}
+	if !jobman.Wait(out.JobIDs[0], 2*time.Second) {
+		t.Fatalf("el job debió vencer con el timeout del pool")
+	}
+}
+
+func TestJobsList_FiltersAndPaging(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+	// journal propio: el de /app/data puede traer jobs de corridas previas
+	jobman.Close()
+	jobman = jobs.NewManagerAt(manager, time.Minute, time.Minute, t.TempDir())
+	defer jobman.Close()
+
+	for _, q := range []string{"offset=-1", "offset=x", "limit=0", "limit=1001"} {
+		if r := Dispatch("GET", "/jobs/list?"+q); r.Status != 400 || r.Err == nil {
+			t.Fatalf("%q => %#v", q, r)
+		}
+	}
+
+	mustRegisterPool(t, "listed", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.PlainOK("ok")
+	}, 1, 8, true)
+	type page struct {
+		Total int `json:"total"`
+		Jobs  []struct {
+			Task string `json:"task"`
+		} `json:"jobs"`
+	}
+	r := Dispatch("GET", "/jobs/submit_batch?task=listed&count=3")
+	var sub struct {
+		JobIDs []string `json:"job_ids"`
+	}
+	_ = json.Unmarshal([]byte(r.Body), &sub)
+	for _, id := range sub.JobIDs {
+		jobman.Wait(id, time.Second)
+	}
+
+	r = Dispatch("GET", "/jobs/list?task=listed&status=done&limit=2")
+	var out page
+	if err := json.Unmarshal([]byte(r.Body), &out); err != nil || r.Status != 200 {
+		t.Fatalf("/jobs/list => %#v", r)
+	}
+	if out.Total != 3 || len(out.Jobs) != 2 || out.Jobs[0].Task != "listed" {
+		t.Fatalf("filtrado/paginado: %+v", out)
+	}
+}
+
+func TestJobsList_TagFilter(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+
+	var gotTags atomic.Value
+	mustRegisterPool(t, "tagged", func(ctx context.Context, p map[string]string) resp.Result {
+		gotTags.Store(p["tags"])
+		return resp.PlainOK("ok")
+	}, 1, 8, true)
+
+	submit := func(q string) string {
+		r := Dispatch("GET", "/jobs/submit?task=tagged"+q)
+		var out struct {
+			JobID string `json:"job_id"`
+		}
+		if err := json.Unmarshal([]byte(r.Body), &out); err != nil || r.Status != 200 {
+			t.Fatalf("submit %q => %#v", q, r)
+		}
+		jobman.Wait(out.JobID, time.Second)
+		return out.JobID
+	}
+	// tag único por corrida: el journal en /app/data sobrevive entre corridas
+	tag := "batch1-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	a := submit("&tags=" + tag)
+	b := submit("&tags=x,%20" + tag)
+	submit("&tags=batch2")
+	submit("")
+	if v, _ := gotTags.Load().(string); v != "" {
+		t.Fatalf("tags no debería llegar al handler: %q", v)
+	}
+
+	r := Dispatch("GET", "/jobs/list?tag="+tag)
+	var out struct {
+		Total int `json:"total"`
+		Jobs  []struct {
+			ID   string   `json:"id"`
+			Tags []string `json:"tags"`
+		} `json:"jobs"`
+	}
+	if err := json.Unmarshal([]byte(r.Body), &out); err != nil || r.Status != 200 {
+		t.Fatalf("/jobs/list?tag => %#v", r)
+	}
+	if out.Total != 2 || len(out.Jobs) != 2 {
+		t.Fatalf("tag=batch1: %+v", out)
+	}
+	got := map[string]bool{out.Jobs[0].ID: true, out.Jobs[1].ID: true}
+	if !got[a] || !got[b] {
+		t.Fatalf("tag=batch1 => %+v, want %s y %s", out, a, b)
+	}
+	for _, j := range out.Jobs {
+		if j.ID == b && strings.Join(j.Tags, ",") != "x,"+tag {
+			t.Fatalf("tags de %s = %v", b, j.Tags)
+		}
+	}
+}
+
+func TestJobsSubmit_RepeatAndCancelSchedule(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+
+	mustRegisterPool(t, "rep", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.PlainOK("ok")
+	}, 1, 8, true)
+
+	for _, q := range []string{"repeat_every_ms=5&repeat_count=2", "repeat_every_ms=50", "repeat_every_ms=50&repeat_count=0", "repeat_every_ms=50&repeat_count=2&sync=true"} {
+		if r := Dispatch("GET", "/jobs/submit?task=rep&"+q); r.Status != 400 {
+			t.Fatalf("%q => %#v", q, r)
+		}
+	}
+
+	r := Dispatch("GET", "/jobs/submit?task=rep&repeat_every_ms=30&repeat_count=50")
+	var out struct {
+		ScheduleID string   `json:"schedule_id"`
+		JobIDs     []string `json:"job_ids"`
+		Count      int      `json:"count"`
+	}
+	if err := json.Unmarshal([]byte(r.Body), &out); err != nil || r.Status != 200 || out.ScheduleID == "" || out.Count != 50 || len(out.JobIDs) != 50 {
+		t.Fatalf("repeat submit => %#v", r)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	r = Dispatch("GET", "/jobs/cancel?schedule="+out.ScheduleID)
+	if r.Status != 200 || !strings.Contains(r.Body, `"status":"canceled"`) {
+		t.Fatalf("cancel schedule => %#v", r)
+	}
+	time.Sleep(100 * time.Millisecond)
+	var st struct {
+		Created  int      `json:"created"`
+		Canceled bool     `json:"canceled"`
+		JobIDs   []string `json:"job_ids"`
+	}
+	r = Dispatch("GET", "/jobs/schedule?id="+out.ScheduleID)
+	if err := json.Unmarshal([]byte(r.Body), &st); err != nil || !st.Canceled || st.Created < 1 || st.Created >= 50 || len(st.JobIDs) != st.Created {
+		t.Fatalf("/jobs/schedule => %#v", r)
+	}
+	if js, ok := jobman.SnapshotJSON(out.JobIDs[0]); !ok || !strings.Contains(js, out.ScheduleID) {
+		t.Fatalf("el job debe llevar schedule_id: %s", js)
+	}
+	if r := Dispatch("GET", "/jobs/schedule?id=nope"); r.Status != 404 {
+		t.Fatalf("schedule inexistente => %#v", r)
+	}
+}
+
+func TestJobsRetry(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+
+	mustRegisterPool(t, "failing", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.BadReq("params", "always fails")
+	}, 1, 4, true)
+
+	if r := Dispatch("GET", "/jobs/retry"); r.Status != 400 {
+		t.Fatalf("sin id => %#v", r)
+	}
+	if r := Dispatch("GET", "/jobs/retry?id=nope"); r.Status != 404 || r.Err == nil || r.Err.Code != "not_found" {
+		t.Fatalf("id desconocido => %#v", r)
+	}
+
+	id := jobman.Submit("failing", map[string]string{"x": "1"}, time.Second)
+	jobman.Wait(id, time.Second)
+	r := Dispatch("GET", "/jobs/retry?id="+id)
+	var out struct {
+		JobID string `json:"job_id"`
+		From  string `json:"from"`
+	}
+	if err := json.Unmarshal([]byte(r.Body), &out); err != nil || r.Status != 200 || out.From != id || out.JobID == "" || out.JobID == id {
+		t.Fatalf("retry => %#v", r)
+	}
+	if js, ok := jobman.SnapshotJSON(out.JobID); !ok || !strings.Contains(js, `"x":"1"`) {
+		t.Fatalf("el retry debe reusar params: %s", js)
+	}
+}
+
+func TestJobsResult_GoneAfterGC(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+	// jobman con TTL y GC cortos para que limpie enseguida
+	jobman.Close()
+	jobman = jobs.NewManagerWithGC(manager, 20*time.Millisecond, 10*time.Millisecond)
+	defer jobman.Close()
+
+	mustRegisterPool(t, "gcd", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.PlainOK("ok")
+	}, 1, 4, true)
+
+	id := jobman.Submit("gcd", map[string]string{}, time.Second)
+	if !jobman.Wait(id, 2*time.Second) {
+		t.Fatalf("job no terminó")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	var r resp.Result
+	for {
+		r = Dispatch("GET", "/jobs/result?id="+id)
+		if r.Status != 200 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if r.Status != 410 || r.Err == nil || r.Err.Code != "expired" {
+		t.Fatalf("job limpiado => %#v, want 410 expired", r)
+	}
+	if r := Dispatch("GET", "/jobs/result?id=no-existe-"+id); r.Status != 404 || r.Err == nil || r.Err.Code != "not_found" {
+		t.Fatalf("id desconocido => %#v, want 404", r)
+	}
+}
+
+func TestJobsWait_LongPoll(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+
+	release := make(chan struct{})
+	defer close(release)
+	mustRegisterPool(t, "waited", func(ctx context.Context, p map[string]string) resp.Result {
+		if p["slow"] == "1" {
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+		}
+		return resp.PlainOK("done:" + p["x"])
+	}, 2, 4, true)
+
+	for _, q := range []string{"", "?id=x&timeout_ms=-1", "?id=x&timeout_ms=abc"} {
+		if r := Dispatch("GET", "/jobs/wait"+q); r.Status != 400 {
+			t.Fatalf("%q => %#v", q, r)
+		}
+	}
+	if r := Dispatch("GET", "/jobs/wait?id=nope&timeout_ms=10"); r.Status != 404 || r.Err == nil || r.Err.Code != "not_found" {
+		t.Fatalf("id desconocido => %#v", r)
+	}
+
+	// job rápido: devuelve el mismo payload que /jobs/result
+	fast := jobman.Submit("waited", map[string]string{"x": "7"}, time.Second)
+	r := Dispatch("GET", "/jobs/wait?id="+fast+"&timeout_ms=2000")
+	if r.Status != 200 || !strings.Contains(r.Body, `"result":"done:7"`) || !strings.Contains(r.Body, `"status":"done"`) {
+		t.Fatalf("fast wait => %#v", r)
+	}
+	if res := Dispatch("GET", "/jobs/result?id="+fast); res.Body != r.Body {
+		t.Fatalf("wait=%s result=%s", r.Body, res.Body)
+	}
+
+	// job lento: vence timeout_ms y responde 504 still_running
+	slow := jobman.Submit("waited", map[string]string{"slow": "1"}, 10*time.Second)
+	t0 := time.Now()
+	r = Dispatch("GET", "/jobs/wait?id="+slow+"&timeout_ms=50")
+	if r.Status != 504 || r.Err == nil || r.Err.Code != "still_running" {
+		t.Fatalf("slow wait => %#v", r)
+	}
+	if el := time.Since(t0); el < 50*time.Millisecond || el > time.Second {
+		t.Fatalf("wait duró %v, want ~50ms", el)
+	}
+}
+
+func TestJobsSubmit_NormalizesNumericParams(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+
+	mustRegisterPool(t, "pi", func(ctx context.Context, p map[string]string) resp.Result {
+		return handlers.PiJSONCtx(ctx, p)
+	}, 1, 4, true)
+
+	// digits=" 50 ": se recorta antes de encolar y el handler lo acepta
+	r := Dispatch("GET", "/jobs/submit?task=pi&digits=%2050%20&method=spigot")
+	var out struct {
+		JobID string `json:"job_id"`
+	}
+	if err := json.Unmarshal([]byte(r.Body), &out); err != nil || r.Status != 200 {
+		t.Fatalf("submit => %#v", r)
+	}
+	jobman.Wait(out.JobID, 2*time.Second)
+	js, _ := jobman.SnapshotJSON(out.JobID)
+	var snap struct {
+		Status string            `json:"status"`
+		Params map[string]string `json:"params"`
+	}
+	_ = json.Unmarshal([]byte(js), &snap)
+	if snap.Status != "done" || snap.Params["digits"] != "50" {
+		t.Fatalf("job => %s", js)
+	}
+	if res, _, _ := jobman.ResultJSON(out.JobID); !strings.Contains(res, "3.14159265358979323846264338327950288419716939937510") {
+		t.Fatalf("result => %s", res)
+	}
+
+	// formato inválido: 400 antes de crear el job
+	for _, q := range []string{"digits=5x", "digits=%20-%20"} {
+		if r := Dispatch("GET", "/jobs/submit?task=pi&"+q); r.Status != 400 || r.Err == nil || r.Err.Code != "digits" {
+			t.Fatalf("%q => %#v", q, r)
+		}
+	}
+	// params que no son numéricos no se tocan
+	if r := Dispatch("GET", "/jobs/submit?task=pi&digits=10&method=%20spigot"); r.Status != 200 {
+		t.Fatalf("method con espacios => %#v", r)
+	}
+}
+
+func TestDispatch_NotFound_IncludesHints(t *testing.T) {
+	r := Dispatch("GET", "/no-such-route?x=1")
+	if r.Status != 404 || !r.JSON {
+		t.Fatalf("unknown route: %#v", r)
+	}
+	var body struct {
+		Error  string   `json:"error"`
+		Detail string   `json:"detail"`
+		Path   string   `json:"path"`
+		Help   string   `json:"help"`
+		Routes []string `json:"routes"`
+	}
+	if err := json.Unmarshal([]byte(r.Body), &body); err != nil {
+		t.Fatalf("404 body must be JSON: %v (%q)", err, r.Body)
+	}
+	if body.Error != "not_found" || body.Detail != "route" || body.Path != "/no-such-route" || body.Help != "/help" || len(body.Routes) == 0 {
+		t.Fatalf("404 hint payload: %+v", body)
+	}
+
+	// NOTFOUND_HINTS=0: 404 mínimo de siempre
+	t.Setenv("NOTFOUND_HINTS", "0")
+	r = Dispatch("GET", "/no-such-route")
+	if r.Status != 404 || r.Err == nil || r.Err.Code != "not_found" || r.Err.Detail != "route" {
+		t.Fatalf("minimal 404: %#v", r)
+	}
+}
+
 func TestDispatch_Simulate_InvalidTask(t *testing.T) {
 	r := Dispatch("GET", "/simulate?task=foo")
 	if r.Status != 400 || r.Err == nil || r.Err.Code != "task" {
@@ -262,16 +963,27 @@ func TestPoolsSummaryAndMetrics(t *testing.T) {
 	if r.Status != 200 || !r.JSON || r.Body == "" {
 		t.Fatalf("metrics JSON expected, got %#v", r)
 	}
+	var mj map[string]map[string]any
+	if err := json.Unmarshal([]byte(r.Body), &mj); err != nil {
+		t.Fatalf("metrics unmarshal: %v", err)
+	}
+	if _, ok := mj["echo"]; !ok {
+		t.Fatalf("metrics must keep pools: %v", mj)
+	}
+	if pc, ok := mj["pi_cache"]; !ok || pc["capacity"] == nil || pc["evictions"] == nil {
+		t.Fatalf("metrics must include pi_cache: %v", mj)
+	}
 
 	// PoolsSummary forma básica
 	ps := PoolsSummary()
-	v, ok := ps["echo"]
-	if !ok {
-		t.Fatalf("echo not present in PoolsSummary: %#v", ps)
+	var m map[string]any
+	for _, p := range ps {
+		if p["name"] == "echo" {
+			m = p
+		}
 	}
-	m, ok := v.(map[string]any)
-	if !ok {
-		t.Fatalf("value not a map: %#v", v)
+	if m == nil {
+		t.Fatalf("echo not present in PoolsSummary: %#v", ps)
 	}
 	if _, ok := m["queue_len"]; !ok {
 		t.Fatalf("queue_len missing")
@@ -294,6 +1006,34 @@ func TestPoolsSummaryAndMetrics(t *testing.T) {
 	}
 }
 
+func TestPoolsSummary_SortedByName(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+
+	fn := func(ctx context.Context, _ map[string]string) resp.Result { return resp.PlainOK("ok") }
+	for _, name := range []string{"zeta", "alpha", "mid", "beta"} {
+		mustRegisterPool(t, name, fn, 1, 1, true)
+	}
+
+	var first []byte
+	for i := 0; i < 5; i++ {
+		ps := PoolsSummary()
+		names := make([]string, 0, len(ps))
+		for _, p := range ps {
+			names = append(names, p["name"].(string))
+		}
+		if !sort.StringsAreSorted(names) {
+			t.Fatalf("pools not sorted: %v", names)
+		}
+		b, _ := json.Marshal(ps)
+		if i == 0 {
+			first = b
+		} else if string(b) != string(first) {
+			t.Fatalf("summary changed between calls:\n%s\n%s", first, b)
+		}
+	}
+}
+
 /* ---------------- tests: Close ---------------- */
 
 func TestClose_NoPanic(t *testing.T) {
@@ -304,6 +1044,48 @@ func TestClose_NoPanic(t *testing.T) {
 	Close()
 }
 
+func TestClose_DrainsInFlightWork(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+
+	var finished int32
+	mustRegisterPool(t, "slow", func(ctx context.Context, p map[string]string) resp.Result {
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&finished, 1)
+		return resp.PlainOK("done")
+	}, 1, 4, true)
+	res := make(chan resp.Result, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			r, _ := submitSync("slow", map[string]string{}, 2*time.Second)
+			res <- r
+		}()
+	}
+	p, _ := manager.Pool("slow")
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		var m map[string]map[string]any
+		_ = json.Unmarshal([]byte(manager.MetricsJSON()), &m)
+		if m["slow"]["submitted"].(float64) == 2 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	Close()
+	if got := atomic.LoadInt32(&finished); got != 2 {
+		t.Fatalf("Close returned with %d/2 jobs finished", got)
+	}
+	for i := 0; i < 2; i++ {
+		if r := <-res; r.Status != 200 {
+			t.Fatalf("in-flight job must finish: %#v", r)
+		}
+	}
+	if r, _ := p.SubmitAndWait(map[string]string{}, time.Second); r.Status != 503 {
+		t.Fatalf("pool must be closed after Close: %#v", r)
+	}
+}
+
 func TestInitPools_ExecuteCPUClosures_Robust(t *testing.T) {
 	cleanup := resetGlobals(t)
 	defer cleanup()
@@ -416,6 +1198,12 @@ func TestDispatch_BasicRoutes_And_JobsFlow(t *testing.T) {
 	lj := Dispatch("GET", "/jobs/list")
 	if lj.Status != 200 || !lj.JSON { t.Fatalf("/jobs/list => %v", lj) }
 
+	// export (detalle completo, incluye params)
+	ex := Dispatch("GET", "/jobs/export")
+	if ex.Status != 200 || !ex.JSON || !strings.Contains(ex.Body, `"id":"`+id+`"`) || !strings.Contains(ex.Body, `"params"`) {
+		t.Fatalf("/jobs/export => %v", ex)
+	}
+
 	// (opcional) esperar que termine cancelado para no dejar goroutine colgando
 	_ = waitUntil(800*time.Millisecond, func() bool {
 		js := Dispatch("GET", "/jobs/status?id="+id)
@@ -564,3 +1352,105 @@ func TestInitPools_AllClosures_Robust_IO(t *testing.T) {
 		}
 	}
 }
+
+func TestDispatch_JobsStatus_BulkIDs(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+
+	mustRegisterPool(t, "quick", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.PlainOK("ok")
+	}, 1, 4, true)
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		sub := Dispatch("GET", "/jobs/submit?task=quick")
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(sub.Body), &obj); err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+		ids = append(ids, obj["job_id"].(string))
+	}
+
+	r := Dispatch("GET", "/jobs/status?ids="+ids[0]+","+ids[1]+",nope,"+ids[2])
+	if r.Status != 200 || !r.JSON {
+		t.Fatalf("bulk status => %v", r)
+	}
+	var arr []map[string]any
+	if err := json.Unmarshal([]byte(r.Body), &arr); err != nil {
+		t.Fatalf("bulk json: %v body=%s", err, r.Body)
+	}
+	if len(arr) != 4 {
+		t.Fatalf("want 4 entries, got %d: %s", len(arr), r.Body)
+	}
+	for i, want := range []string{ids[0], ids[1], "nope", ids[2]} {
+		if arr[i]["id"] != want {
+			t.Fatalf("entry %d id=%v want %s", i, arr[i]["id"], want)
+		}
+	}
+	if arr[2]["error"] != "not_found" {
+		t.Fatalf("unknown id must be marked not_found: %v", arr[2])
+	}
+	if arr[0]["task"] != "quick" || arr[0]["status"] == nil {
+		t.Fatalf("snapshot incompleto: %v", arr[0])
+	}
+
+	if r := Dispatch("GET", "/jobs/status?ids=,,"); r.Status != 400 {
+		t.Fatalf("empty ids => 400, got %v", r)
+	}
+}
+
+func TestDispatch_JobsSubmit_SyncInlineAndTimeout(t *testing.T) {
+	cleanup := resetGlobals(t)
+	defer cleanup()
+
+	mustRegisterPool(t, "fast", func(ctx context.Context, p map[string]string) resp.Result {
+		return resp.PlainOK("fast-done")
+	}, 1, 4, true)
+	mustRegisterPool(t, "slow", func(ctx context.Context, p map[string]string) resp.Result {
+		select {
+		case <-ctx.Done():
+			return resp.Unavail("canceled", "canceled")
+		case <-time.After(500 * time.Millisecond):
+			return resp.PlainOK("slow-done")
+		}
+	}, 1, 4, true)
+
+	// rápido: resultado inline y el job queda registrado
+	r := Dispatch("GET", "/jobs/submit?task=fast&sync=true&timeout_ms=1000")
+	if r.Status != 200 || !r.JSON {
+		t.Fatalf("sync fast => %v", r)
+	}
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(r.Body), &obj); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if obj["status"] != "done" || obj["result"] != "fast-done" || obj["job_id"] == "" {
+		t.Fatalf("inline result => %v", obj)
+	}
+	if st := Dispatch("GET", "/jobs/status?id="+obj["job_id"].(string)); st.Status != 200 {
+		t.Fatalf("job must be tracked: %v", st)
+	}
+
+	// lento: 202 con job_id para poll posterior
+	r = Dispatch("GET", "/jobs/submit?task=slow&sync=true&timeout_ms=50")
+	if r.Status != 202 {
+		t.Fatalf("sync slow => 202, got %v", r)
+	}
+	obj = nil
+	if err := json.Unmarshal([]byte(r.Body), &obj); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	id, _ := obj["job_id"].(string)
+	if id == "" || obj["status"] != "running" {
+		t.Fatalf("slow payload => %v", obj)
+	}
+	if !waitUntil(2*time.Second, func() bool {
+		return Dispatch("GET", "/jobs/result?id="+id).Status == 200
+	}) {
+		t.Fatalf("slow job never finished")
+	}
+
+	if r := Dispatch("GET", "/jobs/submit?task=fast&sync=true&timeout_ms=x"); r.Status != 400 {
+		t.Fatalf("bad timeout_ms => 400, got %v", r)
+	}
+}
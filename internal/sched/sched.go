@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"math"
+	"math/rand"
+	"sort"
 	"sync"
 	"strconv"
 	"sync/atomic"
@@ -26,11 +29,19 @@ type work struct {
 }
 
 // ---- estadísticos (Welford) ----
+
+// reservoirSize es cuántas muestras recientes se guardan para percentiles.
+const reservoirSize = 1024
+
 type stat struct {
 	mu   sync.Mutex
 	n    int64
 	mean float64
 	m2   float64
+
+	// últimas reservoirSize muestras (anillo), para p50/p95/p99
+	ring [reservoirSize]float64
+	next int
 }
 
 func (s *stat) add(x float64) {
@@ -40,7 +51,43 @@ func (s *stat) add(x float64) {
 	s.mean += delta / float64(s.n)
 	delta2 := x - s.mean
 	s.m2 += delta * delta2
+	s.ring[s.next%reservoirSize] = x
+	s.next++
+	s.mu.Unlock()
+}
+
+func (s *stat) reset() {
+	s.mu.Lock()
+	s.n, s.mean, s.m2 = 0, 0, 0
+	s.next = 0
+	s.mu.Unlock()
+}
+
+// percentiles devuelve los cuantiles qs (0..1, nearest-rank) sobre las
+// muestras del anillo; ceros si no hay muestras.
+func (s *stat) percentiles(qs ...float64) []float64 {
+	s.mu.Lock()
+	n := s.next
+	if n > reservoirSize {
+		n = reservoirSize
+	}
+	xs := make([]float64, n)
+	copy(xs, s.ring[:n])
 	s.mu.Unlock()
+
+	out := make([]float64, len(qs))
+	if n == 0 {
+		return out
+	}
+	sort.Float64s(xs)
+	for i, q := range qs {
+		k := int(math.Ceil(q*float64(n))) - 1
+		if k < 0 {
+			k = 0
+		}
+		out[i] = xs[k]
+	}
+	return out
 }
 
 func (s *stat) snapshot() (count int64, mean, std float64) {
@@ -57,6 +104,142 @@ func (s *stat) snapshot() (count int64, mean, std float64) {
 	return
 }
 
+// ---- Histograma de ejecución ----
+
+// histBounds son los límites superiores (exclusivos) de cada bucket; el
+// último bucket (>=10s) no tiene límite. histLabels sigue el mismo orden.
+var (
+	histBounds = []time.Duration{time.Millisecond, 10 * time.Millisecond, 100 * time.Millisecond, time.Second, 10 * time.Second}
+	histLabels = []string{"<1ms", "<10ms", "<100ms", "<1s", "<10s", ">=10s"}
+)
+
+// histBucket devuelve el índice del bucket que corresponde a d.
+func histBucket(d time.Duration) int {
+	for i, b := range histBounds {
+		if d < b {
+			return i
+		}
+	}
+	return len(histBounds)
+}
+
+// ---- Pista de reintento ante backpressure ----
+
+// RetryBase es el retry_after mínimo sugerido cuando la cola rechaza.
+// RetryJitter es la fracción aleatoria (0..1) que se suma sobre el valor
+// derivado, para que muchos clientes no reintenten todos a la vez.
+var (
+	RetryBase   = 100 * time.Millisecond
+	RetryJitter = 0.5
+)
+
+// retryAfter escala RetryBase con la presión de la cola (len/cap, 1x..2x)
+// y le agrega hasta RetryJitter de ruido uniforme.
+func (p *Pool) retryAfter() time.Duration {
+	qlen := len(p.qHigh) + len(p.qNorm) + len(p.qLow)
+	qcap := cap(p.qHigh) + cap(p.qNorm) + cap(p.qLow)
+	pressure := 0.0
+	if qcap > 0 {
+		pressure = float64(qlen) / float64(qcap)
+	}
+	base := float64(RetryBase) * (1 + pressure)
+	if RetryJitter > 0 {
+		base += base * RetryJitter * rand.Float64()
+	}
+	return time.Duration(base)
+}
+
+// ---- Aging de prioridades ----
+
+// AgingThreshold es la espera a partir de la cual un trabajo low/normal pasa
+// delante de high, para que un flujo constante de high no deje sin servicio
+// al resto. 0 desactiva el aging (orden estricto high > normal > low).
+// Cada pool lo copia al crearse (NewPool).
+var AgingThreshold time.Duration
+
+// ageTracker guarda los instantes de encolado de los trabajos pendientes de
+// una cola. Los canales no permiten espiar la cabeza, así que se lleva aparte.
+type ageTracker struct {
+	mu sync.Mutex
+	t  []time.Time
+}
+
+func (a *ageTracker) add(t time.Time) {
+	a.mu.Lock()
+	a.t = append(a.t, t)
+	a.mu.Unlock()
+}
+
+// remove quita una ocurrencia de t (no-op si no está).
+func (a *ageTracker) remove(t time.Time) {
+	a.mu.Lock()
+	for i, x := range a.t {
+		if x.Equal(t) {
+			a.t = append(a.t[:i], a.t[i+1:]...)
+			break
+		}
+	}
+	a.mu.Unlock()
+}
+
+// oldestWait devuelve cuánto lleva esperando el trabajo más antiguo (0 si no hay).
+func (a *ageTracker) oldestWait() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var oldest time.Time
+	for _, x := range a.t {
+		if oldest.IsZero() || x.Before(oldest) {
+			oldest = x
+		}
+	}
+	if oldest.IsZero() {
+		return 0
+	}
+	return time.Since(oldest)
+}
+
+// ageTrack devuelve el tracker de la cola; high no se rastrea (nil).
+func (p *Pool) ageTrack(prio string) *ageTracker {
+	switch prio {
+	case "normal":
+		return &p.normAge
+	case "low":
+		return &p.lowAge
+	}
+	return nil
+}
+
+// takeAged intenta (sin bloquear) tomar de la cola low/normal cuyo trabajo
+// más antiguo superó AgingThreshold, empezando por el que más esperó.
+func (p *Pool) takeAged() (work, string, bool) {
+	th := p.aging
+	if th <= 0 {
+		return work{}, "", false
+	}
+	lw, nw := p.lowAge.oldestWait(), p.normAge.oldestWait()
+	order := []string{"low", "normal"}
+	if nw > lw {
+		order = []string{"normal", "low"}
+	}
+	for _, prio := range order {
+		ch, wait := p.qLow, lw
+		if prio == "normal" {
+			ch, wait = p.qNorm, nw
+		}
+		if wait < th {
+			continue
+		}
+		select {
+		case w, ok := <-ch:
+			if ok {
+				return w, prio, true
+			}
+		default:
+		}
+	}
+	return work{}, "", false
+}
+
 // ---- Pool con 3 colas por prioridad ----
 type Pool struct {
 	name   string
@@ -67,22 +250,74 @@ type Pool struct {
 	qNorm chan work
 	qLow  chan work
 
-	total  int
+	// defaultTimeout se usa cuando SubmitAndWaitCtx recibe timeout <= 0
+	// (0 = el llamador debe indicarlo).
+	defaultTimeout time.Duration
+
+	total  int64 // workers deseados (atómico; ver Resize)
+	live   int64 // goroutines de worker vivas
 	busy   int64 // workers ejecutando
 	mu     sync.Mutex
 	start  sync.Once
 	closed bool
 
+	// sendMu ordena los envíos contra Close: cada envío chequea closed y
+	// encola con RLock tomado, y Close cierra las colas con Lock, así nunca
+	// se envía a una cola cerrada. closing se cierra antes para despertar a
+	// los envíos bloqueados en una cola llena.
+	sendMu  sync.RWMutex
+	closing chan struct{}
+
+	// Un canal de salida por worker lanzado (protegidos por mu). Resize los
+	// cierra para achicar; nextID da tags únicos a los workers nuevos.
+	quits   []chan struct{}
+	started bool
+	nextID  int
+
+	// Encolados pendientes de normal/low, para el aging; aging es
+	// AgingThreshold al crear el pool (los workers no leen el global)
+	aging   time.Duration
+	normAge ageTracker
+	lowAge  ageTracker
+
+	// Pausa (ver Pause/Resume; canales protegidos por mu): pauseC se cierra
+	// al pausar para despertar a los workers bloqueados; resumeC se cierra
+	// al reanudar.
+	paused  int32
+	pauseC  chan struct{}
+	resumeC chan struct{}
+
+	// draining != 0 ⇒ Drain en curso: los envíos nuevos reciben 503 draining.
+	// inflight cuenta trabajos encolados que aún no terminaron.
+	draining int32
+	inflight int64
+
+	// disabled != 0 ⇒ el pool no acepta trabajos nuevos (ver SetAcceptingNew)
+	disabled int32
+
 	// Métricas acumuladas
 	submitted uint64 // trabajos encolados
 	completed uint64 // trabajos finalizados
 	rejected  uint64 // no encolados por backpressure
 	waitStat  stat   // espera (ms)
 	runStat   stat   // ejecución (ms)
+	runHist   [6]uint64 // conteo por bucket de ejecución (ver histLabels)
+
+	// Máximos de cola observados al encolar (high-water mark): total y por
+	// prioridad (índices high, norm, low).
+	queuePeak int64
+	prioPeak  [3]int64
 }
 
 // NewPool crea un pool con workers y capacidad total, repartida en 1:2:1 (high:norm:low).
+// Sin timeout por defecto: cada envío debe traer el suyo.
 func NewPool(name string, fn TaskFunc, workers, capacity int) *Pool {
+	return NewPoolWithTimeout(name, fn, workers, capacity, 0)
+}
+
+// NewPoolWithTimeout es NewPool con un timeout por defecto para los envíos
+// que pasan timeout <= 0.
+func NewPoolWithTimeout(name string, fn TaskFunc, workers, capacity int, timeout time.Duration) *Pool {
 	if workers <= 0 {
 		workers = 1
 	}
@@ -99,10 +334,23 @@ func NewPool(name string, fn TaskFunc, workers, capacity int) *Pool {
 		qHigh: make(chan work, ch),
 		qNorm: make(chan work, cn),
 		qLow:  make(chan work, cl),
-		total: workers,
+		total: int64(workers),
+
+		defaultTimeout: timeout,
+		aging:          AgingThreshold,
+
+		pauseC:  make(chan struct{}),
+		resumeC: closedChan(),
+		closing: make(chan struct{}),
 	}
 }
 
+func closedChan() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}
+
 func imax(a, b int) int {
 	if a > b {
 		return a
@@ -110,23 +358,139 @@ func imax(a, b int) int {
 	return b
 }
 
-// Close cierra la cola y marca el pool como cerrado.
+// Close marca el pool como cerrado y cierra las colas; lo ya encolado se
+// sigue ejecutando. Los envíos bloqueados reciben 503 closed.
 func (p *Pool) Close() {
 	p.mu.Lock()
-	if !p.closed {
-		close(p.qHigh)
-		close(p.qNorm)
-		close(p.qLow)
-		p.closed = true
+	if atomic.LoadInt32(&p.paused) == 1 {
+		// los workers en pausa deben despertar para ver las colas cerradas
+		atomic.StoreInt32(&p.paused, 0)
+		close(p.resumeC)
+	}
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.closing)
+	p.mu.Unlock()
+
+	// espera a que ningún envío esté entre el chequeo y el encolado
+	p.sendMu.Lock()
+	close(p.qHigh)
+	close(p.qNorm)
+	close(p.qLow)
+	p.sendMu.Unlock()
+}
+
+// isClosed lee closed con el lock (los workers lo consultan sin tenerlo).
+func (p *Pool) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// drainPoll es cada cuánto Drain revisa si quedó trabajo pendiente.
+var drainPoll = 10 * time.Millisecond
+
+// Drain deja de aceptar envíos (503 draining), espera a que lo encolado y lo
+// que está en ejecución termine (o a que venza ctx) y luego cierra el pool.
+// Devuelve ctx.Err() si no llegó a vaciarse.
+func (p *Pool) Drain(ctx context.Context) error {
+	atomic.StoreInt32(&p.draining, 1)
+	p.Resume() // un pool pausado nunca se vaciaría
+	defer p.Close()
+
+	// se revisa recién tras el primer tick: da margen a envíos que ya habían
+	// pasado el chequeo de draining y están por encolar (los que lleguen
+	// tarde reciben 503 closed, ver Close)
+	t := time.NewTicker(drainPoll)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+		if atomic.LoadInt64(&p.inflight) <= 0 {
+			return nil
+		}
+	}
+}
+
+// Pause deja de sacar trabajos de las colas: cada worker termina el que está
+// ejecutando y espera a Resume. Los envíos se siguen encolando.
+func (p *Pool) Pause() {
+	p.mu.Lock()
+	if atomic.LoadInt32(&p.paused) == 0 && !p.closed {
+		atomic.StoreInt32(&p.paused, 1)
+		p.resumeC = make(chan struct{})
+		close(p.pauseC)
+	}
+	p.mu.Unlock()
+}
+
+// Resume reanuda un pool pausado.
+func (p *Pool) Resume() {
+	p.mu.Lock()
+	if atomic.LoadInt32(&p.paused) == 1 {
+		atomic.StoreInt32(&p.paused, 0)
+		p.pauseC = make(chan struct{})
+		close(p.resumeC)
 	}
 	p.mu.Unlock()
 }
 
+// Paused indica si el pool está pausado.
+func (p *Pool) Paused() bool { return atomic.LoadInt32(&p.paused) == 1 }
+
+// Draining indica si hay un Drain en curso (o ya terminó).
+func (p *Pool) Draining() bool { return atomic.LoadInt32(&p.draining) == 1 }
+
+// pauseChans devuelve el estado de pausa y sus canales de forma consistente.
+func (p *Pool) pauseChans() (paused bool, pauseC, resumeC chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return atomic.LoadInt32(&p.paused) == 1, p.pauseC, p.resumeC
+}
+
+// SetAcceptingNew habilita/deshabilita la admisión de trabajos nuevos. Lo ya
+// encolado o en ejecución sigue su curso; los envíos nuevos reciben 503
+// pool_disabled.
+func (p *Pool) SetAcceptingNew(on bool) {
+	var v int32
+	if !on {
+		v = 1
+	}
+	atomic.StoreInt32(&p.disabled, v)
+}
+
+// AcceptingNew indica si el pool admite trabajos nuevos.
+func (p *Pool) AcceptingNew() bool { return atomic.LoadInt32(&p.disabled) == 0 }
+
+// DefaultTimeout devuelve el timeout por defecto del pool (0 = sin default).
+func (p *Pool) DefaultTimeout() time.Duration { return p.defaultTimeout }
+
 // SubmitAndWaitCtx encola con prioridad (params["prio"]) y espera resultado/timeout/cancel.
+// Con timeout <= 0 usa el del pool (NewPoolWithTimeout). enq=false indica que
+// el trabajo no se encoló (backpressure o pool en Drain).
 func (p *Pool) SubmitAndWaitCtx(ctx context.Context, id string, params map[string]string, timeout time.Duration) (resp.Result, bool) {
-	if p.closed {
+	if timeout <= 0 {
+		timeout = p.defaultTimeout
+	}
+	if timeout <= 0 {
+		return resp.IntErr("no_timeout", "timeout required: pool has no default"), true
+	}
+	if p.isClosed() {
 		return resp.Unavail("closed", "pool closed"), true
 	}
+	if !p.AcceptingNew() {
+		return resp.Unavail("pool_disabled", "pool not accepting new jobs"), true
+	}
+	if p.Draining() {
+		// enq=false como el backpressure: no llegó a la cola
+		return resp.Unavail("draining", "pool draining"), false
+	}
 
 	w := work{
 		id:       id,
@@ -138,27 +502,62 @@ func (p *Pool) SubmitAndWaitCtx(ctx context.Context, id string, params map[strin
 
 	// elige cola por prioridad (default: normal)
 	var ch chan work
+	var pi int // índice en prioPeak
 	switch params["prio"] {
 	case "high":
 		ch = p.qHigh
 	case "low":
-		ch = p.qLow
+		ch, pi = p.qLow, 2
 	default:
-		ch = p.qNorm
+		ch, pi = p.qNorm, 1
 	}
 
 	timer := time.NewTimer(timeout)
 	defer timer.Stop()
 
-	// intento de encolado con timeout / cancel
+	// se registra antes de enviar: el worker puede sacarlo enseguida
+	tr := p.ageTrack(prioName(params["prio"]))
+	if tr != nil {
+		tr.add(w.enqueued)
+	}
+
+	// intento de encolado con timeout / cancel; con sendMu.RLock para que
+	// Close no cierre la cola en el medio
+	p.sendMu.RLock()
+	if p.isClosed() {
+		p.sendMu.RUnlock()
+		if tr != nil {
+			tr.remove(w.enqueued)
+		}
+		return resp.Unavail("closed", "pool closed"), true
+	}
 	select {
 	case ch <- w:
+		p.sendMu.RUnlock()
 		atomic.AddUint64(&p.submitted, 1)
+		atomic.AddInt64(&p.inflight, 1)
+		bumpPeak(&p.prioPeak[pi], int64(len(ch)))
+		bumpPeak(&p.queuePeak, int64(len(p.qHigh)+len(p.qNorm)+len(p.qLow)))
 	case <-timer.C:
+		p.sendMu.RUnlock()
+		if tr != nil {
+			tr.remove(w.enqueued)
+		}
 		atomic.AddUint64(&p.rejected, 1)
-		return resp.Unavail("backpressure", `{"retry_after_ms":100}`), false
+		hint := fmt.Sprintf(`{"retry_after_ms":%d}`, p.retryAfter().Milliseconds())
+		return resp.Unavail("backpressure", hint), false
 	case <-ctx.Done():
+		p.sendMu.RUnlock()
+		if tr != nil {
+			tr.remove(w.enqueued)
+		}
 		return resp.Unavail("canceled", "job canceled"), true
+	case <-p.closing:
+		p.sendMu.RUnlock()
+		if tr != nil {
+			tr.remove(w.enqueued)
+		}
+		return resp.Unavail("closed", "pool closed"), true
 	}
 
 	// esperar resultado / timeout / cancel de ejecución
@@ -173,104 +572,225 @@ func (p *Pool) SubmitAndWaitCtx(ctx context.Context, id string, params map[strin
 	}
 }
 
+// bumpPeak sube *peak a v si v es mayor (CAS, sin locks).
+func bumpPeak(peak *int64, v int64) {
+	for {
+		old := atomic.LoadInt64(peak)
+		if v <= old || atomic.CompareAndSwapInt64(peak, old, v) {
+			return
+		}
+	}
+}
+
+// prioName normaliza params["prio"] al nombre de cola ("high"|"normal"|"low").
+func prioName(v string) string {
+	switch v {
+	case "high", "low":
+		return v
+	}
+	return "normal"
+}
+
 // SubmitAndWait helper para rutas síncronas (sin cancel externo).
 func (p *Pool) SubmitAndWait(params map[string]string, timeout time.Duration) (resp.Result, bool) {
 	return p.SubmitAndWaitCtx(context.Background(), "", params, timeout)
 }
 
-// Start lanza los workers (preferencia: high > norm > low).
 // Start lanza los workers (preferencia: high > norm > low).
 func (p *Pool) Start() {
 	p.start.Do(func() {
-		for i := 0; i < p.total; i++ {
-			workerID := i // capturar el índice
-			go func() {
-				workerTag := p.name + "#" + strconv.Itoa(workerID)
-
-				for {
-					var (
-						w  work
-						ok bool
-					)
-
-					// 1) intenta alta (no bloqueante)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.started = true
+		for i := int64(0); i < atomic.LoadInt64(&p.total); i++ {
+			p.spawnLocked()
+		}
+	})
+}
+
+// spawnLocked lanza un worker con su propio canal de salida. Requiere p.mu.
+func (p *Pool) spawnLocked() {
+	quit := make(chan struct{})
+	p.quits = append(p.quits, quit)
+	workerID := p.nextID
+	p.nextID++
+	atomic.AddInt64(&p.live, 1)
+	go p.worker(workerID, quit)
+}
+
+// Resize ajusta la cantidad de workers en caliente. Crecer lanza workers
+// nuevos; achicar cierra el canal de salida de los sobrantes, que terminan
+// al acabar su trabajo actual (lo encolado no se pierde).
+func (p *Pool) Resize(n int) error {
+	if n <= 0 {
+		return errors.New("workers must be > 0")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return errors.New("pool closed")
+	}
+	atomic.StoreInt64(&p.total, int64(n))
+	if !p.started {
+		return nil // Start usará el nuevo total
+	}
+	for len(p.quits) < n {
+		p.spawnLocked()
+	}
+	for len(p.quits) > n {
+		last := len(p.quits) - 1
+		close(p.quits[last])
+		p.quits = p.quits[:last]
+	}
+	return nil
+}
+
+// Workers devuelve la cantidad de workers deseada (la que reporta metrics).
+func (p *Pool) Workers() int { return int(atomic.LoadInt64(&p.total)) }
+
+// worker es el loop de un worker; sale al cerrar quit o el pool.
+func (p *Pool) worker(workerID int, quit chan struct{}) {
+	defer atomic.AddInt64(&p.live, -1)
+	workerTag := p.name + "#" + strconv.Itoa(workerID)
+
+	for {
+		// Resize pidió salir: no se toma trabajo nuevo
+		select {
+		case <-quit:
+			return
+		default:
+		}
+
+		// En pausa: no se toma trabajo hasta Resume (o salida por Resize)
+		paused, pauseC, resumeC := p.pauseChans()
+		if paused {
+			select {
+			case <-resumeC:
+			case <-quit:
+				return
+			}
+			continue
+		}
+
+		var (
+			w    work
+			ok   bool
+			prio string // cola de la que salió el trabajo
+		)
+
+		// 0) aging: lo que esperó más de AgingThreshold en low/normal va primero
+		w, prio, ok = p.takeAged()
+		if !ok {
+			// 1) intenta alta (no bloqueante)
+			select {
+			case w, ok = <-p.qHigh:
+				prio = "high"
+				if !ok {
+					// qHigh cerrada: sigue con otras colas
+					w = work{}
+				}
+			default:
+				// 2) intenta normal (no bloqueante)
+				select {
+				case w, ok = <-p.qNorm:
+					prio = "normal"
+					if !ok {
+						w = work{}
+					}
+				default:
+					// 3) bloquea esperando cualquiera, con preferencia
 					select {
 					case w, ok = <-p.qHigh:
+						prio = "high"
 						if !ok {
-							// qHigh cerrada: sigue con otras colas
 							w = work{}
 						}
-					default:
-						// 2) intenta normal (no bloqueante)
-						select {
-						case w, ok = <-p.qNorm:
-							if !ok {
-								w = work{}
-							}
-						default:
-							// 3) bloquea esperando cualquiera, con preferencia
-							select {
-							case w, ok = <-p.qHigh:
-								if !ok {
-									w = work{}
-								}
-							case w, ok = <-p.qNorm:
-								if !ok {
-									w = work{}
-								}
-							case w, ok = <-p.qLow:
-								if !ok {
-									w = work{}
-								}
-							}
+					case w, ok = <-p.qNorm:
+						prio = "normal"
+						if !ok {
+							w = work{}
 						}
-					}
-
-					// Si todas las colas están cerradas y el pool está marcado cerrado, salimos.
-					if (w.params == nil && w.done == nil) && p.closed {
+					case w, ok = <-p.qLow:
+						prio = "low"
+						if !ok {
+							w = work{}
+						}
+					case <-quit:
 						return
+					case <-pauseC:
+						// Pause mientras esperaba: vuelve arriba sin trabajo
 					}
-					// Si no llegó nada útil (p.ej. una cola cerrada devolvió cero valor), continúa.
-					if w.done == nil {
-						continue
-					}
+				}
+			}
+		}
+		if tr := p.ageTrack(prio); tr != nil && w.done != nil {
+			tr.remove(w.enqueued)
+		}
 
-					// Cancelado antes de ejecutar
-					select {
-					case <-w.ctx.Done():
-						w.done <- resp.Unavail("canceled", "job canceled before run")
-						close(w.done)
-						continue
-					default:
-					}
+		// Si todas las colas están cerradas y el pool está marcado cerrado, salimos.
+		if (w.params == nil && w.done == nil) && p.isClosed() {
+			return
+		}
+		// Si no llegó nada útil (p.ej. una cola cerrada devolvió cero valor), continúa.
+		if w.done == nil {
+			continue
+		}
 
-					atomic.AddInt64(&p.busy, 1)
-					wait := time.Since(w.enqueued)
-					start := time.Now()
+		// Cancelado antes de ejecutar
+		select {
+		case <-w.ctx.Done():
+			w.done <- resp.Unavail("canceled", "job canceled before run")
+			close(w.done)
+			atomic.AddInt64(&p.inflight, -1)
+			continue
+		default:
+		}
 
-					// Ejecuta respetando contexto (handlers deben consultar ctx periódicamente)
-					res := p.fn(w.ctx, w.params)
+		atomic.AddInt64(&p.busy, 1)
+		wait := time.Since(w.enqueued)
+		start := time.Now()
 
-					run := time.Since(start)
-					atomic.AddInt64(&p.busy, -1)
-					atomic.AddUint64(&p.completed, 1)
+		// Ejecuta respetando contexto (handlers deben consultar ctx periódicamente)
+		res := p.fn(w.ctx, w.params)
 
-					// métricas en ms
-					p.waitStat.add(float64(wait) / 1e6)
-					p.runStat.add(float64(run) / 1e6)
+		run := time.Since(start)
+		atomic.AddInt64(&p.busy, -1)
+		atomic.AddUint64(&p.completed, 1)
 
-					// Adjunta X-Worker-Id sin depender de helpers
-					if res.Headers == nil {
-						res.Headers = map[string]string{}
-					}
-					res.Headers["X-Worker-Id"] = workerTag
+		// métricas en ms
+		p.waitStat.add(float64(wait) / 1e6)
+		p.runStat.add(float64(run) / 1e6)
+		atomic.AddUint64(&p.runHist[histBucket(run)], 1)
 
-					w.done <- res
-					close(w.done)
-				}
-			}()
+		// Adjunta X-Worker-Id y la cola de origen sin depender de helpers
+		if res.Headers == nil {
+			res.Headers = map[string]string{}
 		}
-	})
+		res.Headers["X-Worker-Id"] = workerTag
+		res.Headers["X-Queue-Priority"] = prio
+
+		w.done <- res
+		close(w.done)
+		atomic.AddInt64(&p.inflight, -1)
+	}
+}
+
+// ResetMetrics pone en cero los contadores, los acumuladores de latencia y el
+// histograma y los picos de cola (para repetir benchmarks). No toca colas ni
+// workers.
+func (p *Pool) ResetMetrics() {
+	atomic.StoreUint64(&p.submitted, 0)
+	atomic.StoreUint64(&p.completed, 0)
+	atomic.StoreUint64(&p.rejected, 0)
+	for i := range p.runHist {
+		atomic.StoreUint64(&p.runHist[i], 0)
+	}
+	atomic.StoreInt64(&p.queuePeak, 0)
+	for i := range p.prioPeak {
+		atomic.StoreInt64(&p.prioPeak[i], 0)
+	}
+	p.waitStat.reset()
+	p.runStat.reset()
 }
 
 // metrics devuelve un snapshot serializable para /metrics.
@@ -279,33 +799,58 @@ func (p *Pool) metrics() map[string]any {
 	comp := atomic.LoadUint64(&p.completed)
 	rej := atomic.LoadUint64(&p.rejected)
 	busy := atomic.LoadInt64(&p.busy)
+	total := atomic.LoadInt64(&p.total)
+	idle := total - busy
+	if idle < 0 {
+		idle = 0 // tras achicar, los sobrantes pueden seguir terminando su trabajo
+	}
 
 	_, meanWait, stdWait := p.waitStat.snapshot()
 	_, meanRun, stdRun := p.runStat.snapshot()
+	pw := p.waitStat.percentiles(0.50, 0.95, 0.99)
+	pr := p.runStat.percentiles(0.50, 0.95, 0.99)
 
 	qlen := len(p.qHigh) + len(p.qNorm) + len(p.qLow)
 	qcap := cap(p.qHigh) + cap(p.qNorm) + cap(p.qLow)
 
+	hist := make(map[string]uint64, len(histLabels))
+	for i, l := range histLabels {
+		hist[l] = atomic.LoadUint64(&p.runHist[i])
+	}
+
+	// utilization = busy/total en porcentaje (2 decimales); 0 si no hay workers.
+	util := 0.0
+	if total > 0 {
+		util = math.Round(float64(busy)/float64(total)*10000) / 100
+	}
+
 	return map[string]any{
-		"queue_len": qlen,
-		"queue_cap": qcap,
+		"queue_len":   qlen,
+		"queue_cap":   qcap,
+		"queue_peak":  atomic.LoadInt64(&p.queuePeak),
+		"utilization": util,
+		"accepting":   p.AcceptingNew(),
+		"paused":      p.Paused(),
+		"draining":    p.Draining(),
 		"priority_queues": map[string]any{
-			"high": map[string]int{"len": len(p.qHigh), "cap": cap(p.qHigh)},
-			"norm": map[string]int{"len": len(p.qNorm), "cap": cap(p.qNorm)},
-			"low":  map[string]int{"len": len(p.qLow),  "cap": cap(p.qLow)},
+			"high": map[string]int64{"len": int64(len(p.qHigh)), "cap": int64(cap(p.qHigh)), "peak": atomic.LoadInt64(&p.prioPeak[0])},
+			"norm": map[string]int64{"len": int64(len(p.qNorm)), "cap": int64(cap(p.qNorm)), "peak": atomic.LoadInt64(&p.prioPeak[1])},
+			"low":  map[string]int64{"len": int64(len(p.qLow)),  "cap": int64(cap(p.qLow)),  "peak": atomic.LoadInt64(&p.prioPeak[2])},
 		},
 		"workers": map[string]any{
-			"total": p.total,
+			"total": total,
+			"live":  atomic.LoadInt64(&p.live),
 			"busy":  busy,
-			"idle":  p.total - int(busy),
+			"idle":  idle,
 		},
 		"submitted": sub,
 		"completed": comp,
 		"rejected":  rej,
 		"latency_ms": map[string]any{
-			"wait": map[string]float64{"avg": meanWait, "std": stdWait},
-			"run":  map[string]float64{"avg": meanRun,  "std": stdRun},
+			"wait": map[string]float64{"avg": meanWait, "std": stdWait, "p50": pw[0], "p95": pw[1], "p99": pw[2]},
+			"run":  map[string]float64{"avg": meanRun,  "std": stdRun,  "p50": pr[0], "p95": pr[1], "p99": pr[2]},
 		},
+		"run_histogram": hist,
 	}
 }
 
@@ -337,6 +882,41 @@ func (m *Manager) Pool(name string) (*Pool, bool) {
 	return p, ok
 }
 
+// DrainAll drena todos los pools en paralelo con un plazo común; devuelve
+// el primer error (plazo vencido) si algún pool no llegó a vaciarse.
+func (m *Manager) DrainAll(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	m.mu.RLock()
+	pools := make([]*Pool, 0, len(m.pools))
+	for _, p := range m.pools {
+		pools = append(pools, p)
+	}
+	m.mu.RUnlock()
+
+	errs := make(chan error, len(pools))
+	for _, p := range pools {
+		go func(p *Pool) { errs <- p.Drain(ctx) }(p)
+	}
+	var first error
+	for range pools {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// ResetAll aplica ResetMetrics a todos los pools.
+func (m *Manager) ResetAll() {
+	m.mu.RLock()
+	for _, p := range m.pools {
+		p.ResetMetrics()
+	}
+	m.mu.RUnlock()
+}
+
 func (m *Manager) MetricsJSON() string {
 	m.mu.RLock()
 	out := make(map[string]any, len(m.pools))
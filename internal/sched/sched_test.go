@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"math"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -43,6 +44,48 @@ func TestStatAddSnapshot(t *testing.T) {
 	}
 }
 
+func TestStatPercentiles(t *testing.T) {
+	var s stat
+	if got := s.percentiles(0.5); got[0] != 0 {
+		t.Fatalf("sin muestras => 0, got %v", got)
+	}
+	for i := 100; i >= 1; i-- { // orden inverso: percentiles no dependen del orden
+		s.add(float64(i))
+	}
+	got := s.percentiles(0.50, 0.95, 0.99)
+	if got[0] != 50 || got[1] != 95 || got[2] != 99 {
+		t.Fatalf("p50/p95/p99 de 1..100 = %v", got)
+	}
+
+	// el anillo sólo recuerda las últimas reservoirSize muestras
+	s.reset()
+	for i := 0; i < 1000; i++ {
+		s.add(1e6)
+	}
+	for i := 1; i <= reservoirSize; i++ {
+		s.add(float64(i))
+	}
+	got = s.percentiles(0.50, 0.99)
+	if got[0] != 512 || math.Abs(got[1]-1014) > 1 {
+		t.Fatalf("percentiles sobre la ventana reciente = %v", got)
+	}
+}
+
+func TestMetrics_LatencyPercentiles(t *testing.T) {
+	p := NewPool("pct", func(ctx context.Context, _ map[string]string) resp.Result { return resp.PlainOK("ok") }, 1, 1)
+	for i := 1; i <= 20; i++ {
+		p.runStat.add(float64(i))
+	}
+	run := p.metrics()["latency_ms"].(map[string]any)["run"].(map[string]float64)
+	if run["p50"] != 10 || run["p95"] != 19 || run["p99"] != 20 || run["avg"] != 10.5 {
+		t.Fatalf("latency_ms.run = %v", run)
+	}
+	wait := p.metrics()["latency_ms"].(map[string]any)["wait"].(map[string]float64)
+	if _, ok := wait["p99"]; !ok {
+		t.Fatalf("latency_ms.wait sin p99: %v", wait)
+	}
+}
+
 func TestIMax(t *testing.T) {
 	if imax(2, 1) != 2 {
 		t.Fatal("imax(2,1) != 2")
@@ -149,6 +192,468 @@ func TestSubmitAndWaitCtx_PoolClosed(t *testing.T) {
 	}
 }
 
+func TestSubmitAndWaitCtx_PoolDisabled(t *testing.T) {
+	ran := make(chan struct{}, 1)
+	p := NewPool("off", func(ctx context.Context, _ map[string]string) resp.Result {
+		ran <- struct{}{}
+		return resp.PlainOK("ok")
+	}, 1, 1)
+	p.Start()
+	defer p.Close()
+
+	if !p.AcceptingNew() || p.metrics()["accepting"] != true {
+		t.Fatalf("pool must accept by default")
+	}
+	p.SetAcceptingNew(false)
+	r, enq := p.SubmitAndWaitCtx(context.Background(), "id", nil, 50*time.Millisecond)
+	if !enq || r.Status != 503 || r.Err == nil || r.Err.Code != "pool_disabled" {
+		t.Fatalf("esperado pool_disabled; got enq=%v res=%#v", enq, r)
+	}
+	if p.metrics()["accepting"] != false || p.metrics()["submitted"].(uint64) != 0 {
+		t.Fatalf("metrics with disabled pool: %v", p.metrics())
+	}
+	select {
+	case <-ran:
+		t.Fatalf("disabled pool must not run work")
+	default:
+	}
+
+	p.SetAcceptingNew(true)
+	if r, _ := p.SubmitAndWaitCtx(context.Background(), "id", nil, time.Second); r.Status != 200 {
+		t.Fatalf("re-enabled pool: %#v", r)
+	}
+}
+
+func TestPoolResize_GrowAndShrink(t *testing.T) {
+	release := make(chan struct{})
+	p := NewPool("rs", func(ctx context.Context, _ map[string]string) resp.Result {
+		<-release
+		return resp.PlainOK("ok")
+	}, 1, 8)
+	p.Start()
+	defer p.Close()
+
+	if err := p.Resize(0); err == nil {
+		t.Fatalf("Resize(0) must fail")
+	}
+	if err := p.Resize(3); err != nil {
+		t.Fatalf("Resize(3): %v", err)
+	}
+	workers := func() map[string]any { return p.metrics()["workers"].(map[string]any) }
+	if w := workers(); w["total"] != int64(3) || w["live"] != int64(3) {
+		t.Fatalf("after grow: %v", w)
+	}
+
+	// 3 trabajos bloqueados a la vez => hay 3 workers reales
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r, _ := p.SubmitAndWaitCtx(context.Background(), "id", nil, 2*time.Second); r.Status != 200 {
+				t.Errorf("submit: %#v", r)
+			}
+		}()
+	}
+	if !waitUntil(time.Second, func() bool { return workers()["busy"] == int64(3) }) {
+		t.Fatalf("esperado busy=3: %v", workers())
+	}
+
+	// achicar con trabajos en curso: terminan igual y luego salen
+	if err := p.Resize(1); err != nil {
+		t.Fatalf("Resize(1): %v", err)
+	}
+	if w := workers(); w["total"] != int64(1) || w["idle"] != int64(0) {
+		t.Fatalf("after shrink (busy): %v", w)
+	}
+	close(release)
+	wg.Wait()
+	if !waitUntil(time.Second, func() bool { return workers()["live"] == int64(1) }) {
+		t.Fatalf("surplus workers must exit: %v", workers())
+	}
+	if w := workers(); w["total"] != int64(1) || w["busy"] != int64(0) || w["idle"] != int64(1) {
+		t.Fatalf("after shrink: %v", w)
+	}
+	if r, _ := p.SubmitAndWaitCtx(context.Background(), "id", nil, time.Second); r.Status != 200 {
+		t.Fatalf("pool must still serve after shrink: %#v", r)
+	}
+
+	p.Close()
+	if err := p.Resize(2); err == nil {
+		t.Fatalf("Resize on closed pool must fail")
+	}
+}
+
+func TestPoolResize_BeforeStart(t *testing.T) {
+	p := NewPool("rs0", func(ctx context.Context, _ map[string]string) resp.Result { return resp.PlainOK("ok") }, 1, 1)
+	defer p.Close()
+	if err := p.Resize(4); err != nil {
+		t.Fatalf("Resize: %v", err)
+	}
+	if p.metrics()["workers"].(map[string]any)["live"] != int64(0) {
+		t.Fatalf("no workers before Start")
+	}
+	p.Start()
+	if w := p.metrics()["workers"].(map[string]any); w["total"] != int64(4) || w["live"] != int64(4) {
+		t.Fatalf("Start must use resized total: %v", w)
+	}
+}
+
+func TestSubmitAndWaitCtx_PoolDefaultTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	p := NewPoolWithTimeout("dt", func(ctx context.Context, params map[string]string) resp.Result {
+		if params["block"] == "1" {
+			<-release
+		}
+		return resp.PlainOK("ok")
+	}, 2, 4, 80*time.Millisecond)
+	p.Start()
+	defer p.Close()
+
+	// timeout 0 => usa el del pool
+	if r, _ := p.SubmitAndWaitCtx(context.Background(), "id", nil, 0); r.Status != 200 {
+		t.Fatalf("zero timeout with pool default: %#v", r)
+	}
+	start := time.Now()
+	r, enq := p.SubmitAndWaitCtx(context.Background(), "id", map[string]string{"block": "1"}, 0)
+	if !enq || r.Err == nil || r.Err.Code != "timeout" {
+		t.Fatalf("esperado timeout del pool; enq=%v r=%#v", enq, r)
+	}
+	if el := time.Since(start); el < 80*time.Millisecond || el > time.Second {
+		t.Fatalf("timeout por defecto no respetado: %v", el)
+	}
+
+	// un timeout explícito manda sobre el default
+	done := make(chan resp.Result, 1)
+	go func() {
+		r, _ := p.SubmitAndWaitCtx(context.Background(), "id", map[string]string{"block": "1"}, 2*time.Second)
+		done <- r
+	}()
+	select {
+	case r := <-done:
+		t.Fatalf("explicit timeout must win over pool default: %#v", r)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestSubmitAndWaitCtx_NoTimeoutWithoutDefault(t *testing.T) {
+	ran := false
+	p := NewPool("nt", func(ctx context.Context, _ map[string]string) resp.Result {
+		ran = true
+		return resp.PlainOK("ok")
+	}, 1, 1)
+	p.Start()
+	defer p.Close()
+
+	r, enq := p.SubmitAndWaitCtx(context.Background(), "id", nil, 0)
+	if !enq || r.Status != 500 || r.Err == nil || r.Err.Code != "no_timeout" {
+		t.Fatalf("esperado no_timeout; enq=%v r=%#v", enq, r)
+	}
+	if ran || p.metrics()["submitted"].(uint64) != 0 {
+		t.Fatalf("no debe encolarse sin timeout")
+	}
+}
+
+func TestPoolResetMetrics_And_ManagerResetAll(t *testing.T) {
+	m := NewManager()
+	mk := func(name string) *Pool {
+		p := NewPool(name, func(ctx context.Context, _ map[string]string) resp.Result { return resp.PlainOK("ok") }, 1, 4)
+		if err := m.Register(name, p); err != nil {
+			t.Fatalf("register: %v", err)
+		}
+		return p
+	}
+	a, b := mk("ra"), mk("rb")
+	defer a.Close()
+	defer b.Close()
+	for _, p := range []*Pool{a, b} {
+		for i := 0; i < 3; i++ {
+			if r, _ := p.SubmitAndWait(nil, time.Second); r.Status != 200 {
+				t.Fatalf("submit: %#v", r)
+			}
+		}
+	}
+
+	zeroed := func(p *Pool) bool {
+		mt := p.metrics()
+		lat := mt["latency_ms"].(map[string]any)
+		var hist uint64
+		for _, v := range mt["run_histogram"].(map[string]uint64) {
+			hist += v
+		}
+		return mt["submitted"].(uint64) == 0 && mt["completed"].(uint64) == 0 && mt["rejected"].(uint64) == 0 &&
+			lat["run"].(map[string]float64)["avg"] == 0 && lat["wait"].(map[string]float64)["avg"] == 0 && hist == 0
+	}
+
+	a.ResetMetrics()
+	if !zeroed(a) || zeroed(b) {
+		t.Fatalf("ResetMetrics sólo debe afectar a su pool: a=%v b=%v", a.metrics(), b.metrics())
+	}
+	m.ResetAll()
+	if !zeroed(b) {
+		t.Fatalf("ResetAll: %v", b.metrics())
+	}
+
+	// los workers siguen funcionando y se vuelve a contar desde cero
+	if r, _ := a.SubmitAndWait(nil, time.Second); r.Status != 200 {
+		t.Fatalf("submit after reset: %#v", r)
+	}
+	if mt := a.metrics(); mt["submitted"].(uint64) != 1 || mt["completed"].(uint64) != 1 {
+		t.Fatalf("counters after reset: %v", mt)
+	}
+}
+
+func TestMetricsQueuePeak_FillDrainAndReset(t *testing.T) {
+	// capacidad 8 → high 2, norm 4, low 2; sin Start los trabajos quedan en cola
+	p := NewPool("peak", func(ctx context.Context, _ map[string]string) resp.Result { return resp.PlainOK("ok") }, 1, 8)
+	defer p.Close()
+
+	var wg sync.WaitGroup
+	submit := func(prio string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.SubmitAndWaitCtx(context.Background(), "id", map[string]string{"prio": prio}, 2*time.Second)
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		submit("")
+	}
+	submit("high")
+	if !waitUntil(time.Second, func() bool { return p.metrics()["queue_len"].(int) == 4 }) {
+		t.Fatalf("queue_len: %v", p.metrics()["queue_len"])
+	}
+
+	peaks := func() (int64, int64, int64, int64) {
+		mt := p.metrics()
+		pq := mt["priority_queues"].(map[string]any)
+		return mt["queue_peak"].(int64), pq["high"].(map[string]int64)["peak"],
+			pq["norm"].(map[string]int64)["peak"], pq["low"].(map[string]int64)["peak"]
+	}
+	if q, h, n, l := peaks(); q != 4 || h != 1 || n != 3 || l != 0 {
+		t.Fatalf("peaks con cola llena: total=%d high=%d norm=%d low=%d", q, h, n, l)
+	}
+
+	// al vaciar la cola el pico se mantiene
+	p.Start()
+	wg.Wait()
+	if p.metrics()["queue_len"].(int) != 0 {
+		t.Fatalf("queue_len tras drenar: %v", p.metrics()["queue_len"])
+	}
+	if q, h, n, _ := peaks(); q != 4 || h != 1 || n != 3 {
+		t.Fatalf("peaks tras drenar: total=%d high=%d norm=%d", q, h, n)
+	}
+
+	p.ResetMetrics()
+	if q, h, n, l := peaks(); q != 0 || h != 0 || n != 0 || l != 0 {
+		t.Fatalf("peaks tras reset: total=%d high=%d norm=%d low=%d", q, h, n, l)
+	}
+}
+
+func TestPoolPauseResume(t *testing.T) {
+	var ran int32
+	release := make(chan struct{})
+	p := NewPool("pz", func(ctx context.Context, params map[string]string) resp.Result {
+		if params["block"] == "1" {
+			<-release
+		}
+		atomic.AddInt32(&ran, 1)
+		return resp.PlainOK("ok")
+	}, 1, 4)
+	p.Start()
+	defer p.Close()
+
+	// un trabajo en curso termina aunque se pause a mitad
+	first := make(chan resp.Result, 1)
+	go func() {
+		r, _ := p.SubmitAndWaitCtx(context.Background(), "a", map[string]string{"block": "1"}, 2*time.Second)
+		first <- r
+	}()
+	if !waitUntil(time.Second, func() bool { return atomic.LoadInt64(&p.busy) == 1 }) {
+		t.Fatalf("first job did not start")
+	}
+	p.Pause()
+	close(release)
+	if r := <-first; r.Status != 200 {
+		t.Fatalf("running job must finish after Pause: %#v", r)
+	}
+
+	// en pausa: se encola pero no corre
+	done := make(chan resp.Result, 1)
+	go func() {
+		r, _ := p.SubmitAndWaitCtx(context.Background(), "b", nil, 2*time.Second)
+		done <- r
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if atomic.LoadInt32(&ran) != 1 {
+		t.Fatalf("paused pool must not run new work (ran=%d)", ran)
+	}
+	if m := p.metrics(); m["paused"] != true || m["queue_len"] != 1 {
+		t.Fatalf("metrics while paused: paused=%v queue_len=%v", m["paused"], m["queue_len"])
+	}
+
+	p.Resume()
+	select {
+	case r := <-done:
+		if r.Status != 200 {
+			t.Fatalf("after resume: %#v", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("job did not complete after Resume")
+	}
+	if p.Paused() || p.metrics()["paused"] != false {
+		t.Fatalf("pool must not be paused after Resume")
+	}
+}
+
+func TestPoolClose_WhilePaused_WorkersExit(t *testing.T) {
+	p := NewPool("pzc", func(ctx context.Context, _ map[string]string) resp.Result { return resp.PlainOK("ok") }, 2, 2)
+	p.Start()
+	p.Pause()
+	p.Close()
+	if !waitUntil(time.Second, func() bool { return atomic.LoadInt64(&p.live) == 0 }) {
+		t.Fatalf("workers must exit on Close while paused (live=%d)", atomic.LoadInt64(&p.live))
+	}
+}
+
+func TestPoolDrain_CompletesQueuedWork(t *testing.T) {
+	var completed int32
+	p := NewPool("dr", func(ctx context.Context, _ map[string]string) resp.Result {
+		time.Sleep(40 * time.Millisecond)
+		atomic.AddInt32(&completed, 1)
+		return resp.PlainOK("ok")
+	}, 2, 8)
+	p.Start()
+
+	const n = 6
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r, _ := p.SubmitAndWaitCtx(context.Background(), "id", nil, 2*time.Second); r.Status != 200 {
+				t.Errorf("queued job must complete during drain: %#v", r)
+			}
+		}()
+	}
+	if !waitUntil(time.Second, func() bool { return atomic.LoadInt64(&p.inflight) == n }) {
+		t.Fatalf("jobs not enqueued: inflight=%d", atomic.LoadInt64(&p.inflight))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	drained := make(chan error, 1)
+	go func() { drained <- p.Drain(ctx) }()
+
+	// durante el drain no se aceptan envíos nuevos
+	if !waitUntil(time.Second, func() bool { return p.metrics()["draining"] == true }) {
+		t.Fatalf("draining flag not set")
+	}
+	sub := atomic.LoadUint64(&p.submitted)
+	if r, enq := p.SubmitAndWaitCtx(context.Background(), "late", nil, time.Second); enq || r.Err == nil || r.Err.Code != "draining" {
+		t.Fatalf("submit while draining => enq=%v %#v", enq, r)
+	}
+	if got := atomic.LoadUint64(&p.submitted); got != sub {
+		t.Fatalf("draining no debe encolar: submitted %d -> %d", sub, got)
+	}
+
+	if err := <-drained; err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if got := atomic.LoadInt32(&completed); got != n {
+		t.Fatalf("Drain returned with %d/%d jobs completed", got, n)
+	}
+	if !p.closed {
+		t.Fatalf("Drain must close the pool")
+	}
+	wg.Wait()
+}
+
+func TestPoolClose_BlockedSendersGetClosed(t *testing.T) {
+	release := make(chan struct{})
+	p := NewPool("closeblk", func(ctx context.Context, _ map[string]string) resp.Result {
+		<-release
+		return resp.PlainOK("ok")
+	}, 1, 4) // cola normal de 2
+	p.Start()
+	defer close(release)
+
+	// 1 en ejecución + 2 encolados llenan la cola normal
+	for i := 0; i < 3; i++ {
+		go p.SubmitAndWait(map[string]string{}, 5*time.Second)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// estos quedan bloqueados en "ch <- w" con la cola llena
+	const n = 8
+	errs := make(chan string, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					errs <- "panic"
+				}
+			}()
+			r, _ := p.SubmitAndWait(map[string]string{}, 5*time.Second)
+			if r.Err != nil {
+				errs <- r.Err.Code
+			} else {
+				errs <- "ok"
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// el Drain vence con la cola llena y cierra: nadie debe enviar a una
+	// cola cerrada
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	_ = p.Drain(ctx)
+	for i := 0; i < n; i++ {
+		select {
+		case code := <-errs:
+			if code != "closed" {
+				t.Fatalf("envío bloqueado => %q, want closed", code)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("envío bloqueado no volvió tras Close")
+		}
+	}
+	if r, _ := p.SubmitAndWait(map[string]string{}, time.Second); r.Err == nil || r.Err.Code != "closed" {
+		t.Fatalf("envío tras Close => %+v", r)
+	}
+}
+
+func TestPoolDrain_DeadlineAndDrainAll(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	m := NewManager()
+	slow := NewPool("slow", func(ctx context.Context, _ map[string]string) resp.Result {
+		<-release
+		return resp.PlainOK("ok")
+	}, 1, 2)
+	fast := NewPool("fast", func(ctx context.Context, _ map[string]string) resp.Result { return resp.PlainOK("ok") }, 1, 2)
+	_ = m.Register("slow", slow)
+	_ = m.Register("fast", fast)
+
+	go slow.SubmitAndWaitCtx(context.Background(), "id", nil, 5*time.Second)
+	if !waitUntil(time.Second, func() bool { return atomic.LoadInt64(&slow.busy) == 1 }) {
+		t.Fatalf("slow job did not start")
+	}
+
+	start := time.Now()
+	if err := m.DrainAll(100 * time.Millisecond); err != context.DeadlineExceeded {
+		t.Fatalf("DrainAll con trabajo colgado => %v", err)
+	}
+	if el := time.Since(start); el > time.Second {
+		t.Fatalf("DrainAll no respetó el plazo: %v", el)
+	}
+	if !slow.closed || !fast.closed {
+		t.Fatalf("DrainAll debe cerrar todos los pools")
+	}
+}
+
 func TestSubmitAndWaitCtx_BackpressureReject(t *testing.T) {
 	// No arrancamos el worker; llenamos la cola norm y pedimos encolado con timeout corto
 	p := NewPool("bp", func(ctx context.Context, _ map[string]string) resp.Result { return resp.PlainOK("ok") }, 1, 1)
@@ -314,6 +819,91 @@ func TestMetricsShapeAndBusy(t *testing.T) {
 	}
 }
 
+func TestMetricsUtilization_RisesAndReturnsToZero(t *testing.T) {
+	release := make(chan struct{})
+	p := NewPool("util", func(ctx context.Context, _ map[string]string) resp.Result {
+		<-release
+		return resp.PlainOK("ok")
+	}, 2, 4)
+	p.Start()
+	defer p.Close()
+
+	if u := p.metrics()["utilization"].(float64); u != 0 {
+		t.Fatalf("idle utilization=%v want 0", u)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.SubmitAndWaitCtx(context.Background(), "slow", nil, 2*time.Second)
+		close(done)
+	}()
+
+	if !waitUntil(500*time.Millisecond, func() bool {
+		return p.metrics()["utilization"].(float64) == 50
+	}) {
+		t.Fatalf("utilization should be 50%% with 1/2 busy: %v", p.metrics()["utilization"])
+	}
+
+	close(release)
+	<-done
+	if !waitUntil(500*time.Millisecond, func() bool {
+		return p.metrics()["utilization"].(float64) == 0
+	}) {
+		t.Fatalf("utilization should return to 0: %v", p.metrics()["utilization"])
+	}
+}
+
+func TestHistBucket_Boundaries(t *testing.T) {
+	cases := map[time.Duration]string{
+		0:                       "<1ms",
+		999 * time.Microsecond:  "<1ms",
+		time.Millisecond:        "<10ms",
+		50 * time.Millisecond:   "<100ms",
+		100 * time.Millisecond:  "<1s",
+		9 * time.Second:         "<10s",
+		10 * time.Second:        ">=10s",
+		time.Hour:               ">=10s",
+	}
+	for d, want := range cases {
+		if got := histLabels[histBucket(d)]; got != want {
+			t.Fatalf("histBucket(%v)=%s want %s", d, got, want)
+		}
+	}
+}
+
+func TestMetricsRunHistogram_CountsByDuration(t *testing.T) {
+	p := NewPool("hist", func(ctx context.Context, params map[string]string) resp.Result {
+		if params["sleep"] == "1" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		return resp.PlainOK("ok")
+	}, 1, 4)
+	p.Start()
+	defer p.Close()
+
+	for _, s := range []string{"0", "0", "1"} {
+		if _, ok := p.SubmitAndWaitCtx(context.Background(), "", map[string]string{"sleep": s}, time.Second); !ok {
+			t.Fatalf("submit sleep=%s rejected", s)
+		}
+	}
+
+	// el resultado se entrega después de registrar el histograma
+	hist := p.metrics()["run_histogram"].(map[string]uint64)
+	if len(hist) != len(histLabels) {
+		t.Fatalf("histogram must expose all buckets: %v", hist)
+	}
+	if hist["<1ms"] != 2 || hist["<100ms"] != 1 {
+		t.Fatalf("unexpected histogram: %v", hist)
+	}
+	var total uint64
+	for _, v := range hist {
+		total += v
+	}
+	if total != 3 {
+		t.Fatalf("histogram total=%d want 3: %v", total, hist)
+	}
+}
+
 /* ================= Manager ================= */
 
 func TestManagerRegisterPoolLookupAndDup(t *testing.T) {
@@ -455,3 +1045,101 @@ func TestStart_ClosedHighQueue_StarvesAndTimesOut(t *testing.T) {
 }
 
 
+
+func TestAging_LowRunsDespiteHighFlood(t *testing.T) {
+	old := AgingThreshold
+	AgingThreshold = 100 * time.Millisecond
+	p := NewPool("aging", func(ctx context.Context, _ map[string]string) resp.Result {
+		time.Sleep(5 * time.Millisecond)
+		return resp.PlainOK("ok")
+	}, 1, 16)
+	p.Start()
+	AgingThreshold = old // el pool ya tomó su copia
+	defer p.Close()
+
+	// varios productores mantienen qHigh siempre con trabajo
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				p.SubmitAndWaitCtx(context.Background(), "h", map[string]string{"prio": "high"}, time.Second)
+			}
+		}()
+	}
+	defer func() { close(stop); wg.Wait() }()
+	if !waitUntil(time.Second, func() bool { return len(p.qHigh) == cap(p.qHigh) }) {
+		t.Fatalf("qHigh no se llenó")
+	}
+
+	start := time.Now()
+	r, enq := p.SubmitAndWaitCtx(context.Background(), "low", map[string]string{"prio": "low"}, 2*time.Second)
+	elapsed := time.Since(start)
+	if !enq || r.Status != 200 || r.Headers["X-Queue-Priority"] != "low" {
+		t.Fatalf("low job must run under flood; enq=%v r=%#v", enq, r)
+	}
+	if elapsed > time.Second {
+		t.Fatalf("low job tardó %v (aging 100ms)", elapsed)
+	}
+	if len(p.qHigh) == 0 {
+		t.Fatalf("el flood de high debía seguir activo")
+	}
+	if p.lowAge.oldestWait() != 0 {
+		t.Fatalf("tracker de low debe quedar vacío")
+	}
+}
+
+func TestWorker_SetsQueuePriorityHeader(t *testing.T) {
+	p := NewPool("qp", func(ctx context.Context, _ map[string]string) resp.Result { return resp.PlainOK("ok") }, 1, 8)
+	p.Start()
+	defer p.Close()
+
+	cases := map[string]string{"high": "high", "low": "low", "": "normal", "normal": "normal"}
+	for in, want := range cases {
+		r, ok := p.SubmitAndWait(map[string]string{"prio": in}, time.Second)
+		if !ok || r.Status != 200 {
+			t.Fatalf("prio=%q: ok=%v r=%+v", in, ok, r)
+		}
+		if got := r.Headers["X-Queue-Priority"]; got != want {
+			t.Fatalf("prio=%q -> X-Queue-Priority=%q want %q", in, got, want)
+		}
+	}
+}
+
+func TestBackpressure_RetryAfterJitter(t *testing.T) {
+	// Sin workers: llenamos qNorm (2 de 4 slots) => presión 0.5 => base 150ms
+	p := NewPool("jit", func(ctx context.Context, _ map[string]string) resp.Result { return resp.PlainOK("ok") }, 1, 4)
+	for i := 0; i < cap(p.qNorm); i++ {
+		p.qNorm <- work{ctx: context.Background(), params: map[string]string{}, enqueued: time.Now(), done: make(chan resp.Result, 1)}
+	}
+
+	base := float64(RetryBase.Milliseconds()) * 1.5
+	lo, hi := int64(base), int64(base*(1+RetryJitter))+1
+	seen := map[int64]bool{}
+	for i := 0; i < 20; i++ {
+		r, enq := p.SubmitAndWait(map[string]string{}, time.Millisecond)
+		if enq || r.Err == nil || r.Err.Code != "backpressure" {
+			t.Fatalf("esperado backpressure: enq=%v r=%+v", enq, r)
+		}
+		var hint struct {
+			RetryAfterMS int64 `json:"retry_after_ms"`
+		}
+		if err := json.Unmarshal([]byte(r.Err.Detail), &hint); err != nil {
+			t.Fatalf("detail no es JSON: %q", r.Err.Detail)
+		}
+		if hint.RetryAfterMS < lo || hint.RetryAfterMS > hi {
+			t.Fatalf("retry_after_ms=%d fuera de [%d,%d]", hint.RetryAfterMS, lo, hi)
+		}
+		seen[hint.RetryAfterMS] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("el jitter debería variar retry_after_ms: %v", seen)
+	}
+}
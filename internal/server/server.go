@@ -2,9 +2,15 @@ package server
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net"
+	"runtime"
+	"runtime/debug"
 	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 	"os"
@@ -17,28 +23,271 @@ import (
 var (
 	startedAt = time.Now()
 	connCount uint64
+
+	slowWrites    uint64 // respuestas que tardaron más de SlowWriteThreshold
+	abortedWrites uint64 // respuestas cortadas por error/deadline de escritura
+	ipRejected    uint64 // conexiones rechazadas por MaxConnsPerIP
+	busyRejected  uint64 // conexiones rechazadas por MaxConns
+)
+
+// MaxConns limita las conexiones atendidas a la vez por cada ListenAndServe;
+// las que exceden reciben 503 too_busy en el acto (se descarta carga en vez
+// de crecer sin tope). 0 = sin límite. Configurable con SERVER_MAX_CONNS.
+var MaxConns = 1024
+
+// MaxConnsPerIP limita las conexiones simultáneas desde una misma IP; las
+// que exceden reciben 429 too_many_connections. 0 = sin límite.
+// Configurable con MAX_CONNS_PER_IP.
+var MaxConnsPerIP = 64
+
+// rejectReadTimeout acota cuánto se espera el request de una conexión que
+// se va a rechazar (se lee para no cerrar con datos sin leer: eso manda RST
+// y el cliente podría no ver el 429/503).
+const rejectReadTimeout = time.Second
+
+// maxRejecters acota las goroutines que responden 503 too_busy a la vez: en
+// una avalancha, las que exceden se cierran sin respuesta.
+const maxRejecters = 64
+
+// Conexiones activas por IP (sólo TCP). Las entradas en cero se borran.
+var (
+	ipConnsMu sync.Mutex
+	ipConns   = make(map[string]int)
 )
 
+// remoteIP devuelve la IP de origen, o "" si la conexión no es TCP (net.Pipe
+// en tests): esas no se cuentan.
+func remoteIP(conn net.Conn) string {
+	if a, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		return a.IP.String()
+	}
+	return ""
+}
+
+// acquireIP registra una conexión de ip; false si ya está en el límite.
+func acquireIP(ip string) bool {
+	ipConnsMu.Lock()
+	defer ipConnsMu.Unlock()
+	if MaxConnsPerIP > 0 && ipConns[ip] >= MaxConnsPerIP {
+		return false
+	}
+	ipConns[ip]++
+	return true
+}
+
+// releaseIP descuenta una conexión de ip y poda la entrada si queda en cero.
+func releaseIP(ip string) {
+	ipConnsMu.Lock()
+	defer ipConnsMu.Unlock()
+	if ipConns[ip] <= 1 {
+		delete(ipConns, ip)
+		return
+	}
+	ipConns[ip]--
+}
+
+// ErrServerClosed lo devuelve ListenAndServe cuando el listener se cerró con
+// Shutdown (no es una falla).
+var ErrServerClosed = errors.New("server closed")
+
+// Estado para Shutdown: listeners activos (cada uno con la señal de que su
+// accept loop terminó), conexiones en vuelo de todos ellos y si ya empezó
+// (shuttingDown=1: las conexiones keep-alive se cierran tras su respuesta).
+var (
+	lnMu         sync.Mutex
+	listeners    = make(map[net.Listener]chan struct{})
+	inflight     sync.WaitGroup
+	shuttingDown int32
+)
+
+// ReadTimeout acota cuánto puede tardar el cliente en mandar cada request
+// completo (request-line + headers); si vence se responde 408
+// request_timeout. 0 = sin límite. Configurable con SERVER_READ_TIMEOUT_MS.
+var ReadTimeout = 10 * time.Second
+
+// KeepAliveTimeout es cuánto se espera el request siguiente en una conexión
+// keep-alive antes de cerrarla. Configurable con KEEPALIVE_TIMEOUT_MS.
+var KeepAliveTimeout = 5 * time.Second
+
+// WriteTimeout acota cuánto puede tardar el cliente en recibir la respuesta;
+// un cliente que no lee no retiene la conexión más allá de este plazo.
+// SlowWriteThreshold marca a partir de cuándo una escritura cuenta como lenta.
+var (
+	WriteTimeout       = 10 * time.Second
+	SlowWriteThreshold = time.Second
+)
+
+// trackedWriter envuelve la conexión para medir la escritura de la respuesta
+// y recordar el primer error (los helpers de http10 lo ignoran).
+type trackedWriter struct {
+	net.Conn
+	err     error
+	elapsed time.Duration
+}
+
+func (w *trackedWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	t0 := time.Now()
+	n, err := w.Conn.Write(p)
+	w.elapsed += time.Since(t0)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// record vuelca el resultado de la escritura en las métricas globales.
+func (w *trackedWriter) record() {
+	switch {
+	case w.err != nil:
+		atomic.AddUint64(&abortedWrites, 1)
+	case w.elapsed > SlowWriteThreshold:
+		atomic.AddUint64(&slowWrites, 1)
+	}
+}
+
 func pid() int              { return os.Getpid() }           // importa "os"
 func uptime() time.Duration { return time.Since(startedAt) }
 func conns() uint64         { return atomic.LoadUint64(&connCount) }
 
-func HandleConn(c net.Conn) {
-	defer c.Close()
+// buildInfo identifica el binario en ejecución: versión de Go y, si el
+// build las embebió, módulo/versión y datos de VCS (revision, time, modified).
+func buildInfo() map[string]string {
+	out := map[string]string{"go_version": runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return out
+	}
+	out["module"] = bi.Main.Path
+	out["module_version"] = bi.Main.Version
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			out["vcs_revision"] = s.Value
+		case "vcs.time":
+			out["vcs_time"] = s.Value
+		case "vcs.modified":
+			out["vcs_modified"] = s.Value
+		}
+	}
+	return out
+}
+
+func HandleConn(conn net.Conn) {
+	defer conn.Close()
+
+	// Límite por IP: se cuenta durante toda la vida de la conexión
+	if ip := remoteIP(conn); ip != "" {
+		if !acquireIP(ip) {
+			atomic.AddUint64(&ipRejected, 1)
+			rejectConn(conn, 429, "too_many_connections", "too many concurrent connections from this address")
+			return
+		}
+		defer releaseIP(ip)
+	}
+
+	// Un request por vuelta; con keep-alive se sigue leyendo del mismo
+	// reader (puede traer requests ya encolados) hasta que uno no lo pida o
+	// el cliente quede inactivo más de KeepAliveTimeout.
+	r := bufio.NewReader(conn)
+	for n := 0; ; n++ {
+		if n > 0 {
+			if atomic.LoadInt32(&shuttingDown) == 1 {
+				return
+			}
+			_ = conn.SetReadDeadline(time.Now().Add(KeepAliveTimeout))
+			if _, err := r.Peek(1); err != nil {
+				return // cierre o inactividad entre requests: no es un error
+			}
+		}
+		if !serveRequest(conn, r) {
+			return
+		}
+	}
+}
+
+// rejectConn responde status/code a una conexión que no se va a atender.
+// Antes lee el request (con plazo corto): cerrar con datos sin leer manda RST
+// y el cliente podría no ver la respuesta. La escritura también tiene plazo
+// corto (la respuesta es chica). No cierra conn.
+func rejectConn(conn net.Conn, status int, code, detail string) {
+	c := &trackedWriter{Conn: conn}
+	defer c.record()
+	_ = conn.SetReadDeadline(time.Now().Add(rejectReadTimeout))
+	_, _ = http10.ParseRequest(bufio.NewReader(c))
+	_ = conn.SetWriteDeadline(time.Now().Add(rejectReadTimeout))
+	http10.WriteErrorJSON(c, status, code, detail, traceHeaders())
+}
+
+// bodyDiscardLimit acota cuánto cuerpo pendiente se descarta tras un 413.
+const bodyDiscardLimit = 256 << 10
 
-	trace := map[string]string{
+// discardBody lee y tira (con plazo corto y hasta bodyDiscardLimit) el cuerpo
+// que el cliente siguió mandando tras un 413: cerrar con datos sin leer manda
+// RST y el cliente podría no ver la respuesta.
+func discardBody(conn net.Conn, r *bufio.Reader, req *http10.Request) {
+	if req == nil {
+		return
+	}
+	n, err := strconv.ParseInt(req.Header["content-length"], 10, 64)
+	if err != nil || n <= 0 {
+		return
+	}
+	if n > bodyDiscardLimit {
+		n = bodyDiscardLimit
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(rejectReadTimeout))
+	_, _ = io.CopyN(io.Discard, r, n)
+}
+
+// traceHeaders son las cabeceras de trazabilidad de cada respuesta.
+func traceHeaders() map[string]string {
+	return map[string]string{
 		"X-Request-Id": util.NewReqID(),
 		"X-Worker-Pid": strconv.Itoa(pid()),
 		"Connection":   "close",
 	}
+}
+
+// serveRequest lee un request de r, escribe su respuesta y devuelve si la
+// conexión queda abierta para otro (keep-alive).
+func serveRequest(conn net.Conn, r *bufio.Reader) bool {
+	c := &trackedWriter{Conn: conn}
+	defer c.record()
+	trace := traceHeaders()
 
-	// Parseo HTTP/1.0
-	r := bufio.NewReader(c)
+	// Parseo HTTP/1.0, con plazo para que un cliente colgado no retenga la goroutine
+	if ReadTimeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(ReadTimeout))
+	} else {
+		_ = conn.SetReadDeadline(time.Time{})
+	}
 	req, err := http10.ParseRequest(r)
 	if err != nil {
+		_ = conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			http10.WriteErrorJSON(c, 408, "request_timeout", "request not received in time", trace)
+			return false
+		}
+		if errors.Is(err, http10.ErrBodyTooLarge) {
+			http10.WriteErrorJSON(c, 413, "payload_too_large", "request body exceeds the server limit", trace)
+			discardBody(conn, r, req)
+			return false
+		}
 		http10.WriteErrorJSON(c, 400, "bad_request", err.Error(), trace)
-		return
+		return false
 	}
+	// durante Shutdown se responde y se cierra, aunque el cliente pida keep-alive
+	keepAlive := req.KeepAlive() && atomic.LoadInt32(&shuttingDown) == 0
+	if keepAlive {
+		trace["Connection"] = "keep-alive"
+	}
+
+	// A partir de aquí sólo queda escribir: el deadline corta clientes que no leen
+	_ = conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
 
 	// Intercepta /status aquí (evita importar server en router)
 	if req.Method == "GET" {
@@ -49,17 +298,28 @@ func HandleConn(c net.Conn) {
 				"uptime_ms":   uptime().Milliseconds(),
 				"started_at":  startedAt.UTC().Format(time.RFC3339Nano),
 				"connections": conns(),
+				"connections_rejected_per_ip": atomic.LoadUint64(&ipRejected),
+				"connections_rejected_busy":   atomic.LoadUint64(&busyRejected),
+				"writes": map[string]uint64{
+					"slow":    atomic.LoadUint64(&slowWrites),
+					"aborted": atomic.LoadUint64(&abortedWrites),
+				},
+				"build":       buildInfo(),
 				"pools":       router.PoolsSummary(), // <- viene del router
 			}
 			b, _ := json.Marshal(out)
 			http10.WriteJSONH(c, 200, string(b), trace)
-			return
+			return keepAlive && c.err == nil
 		}
 	}
 
 	// Resto de rutas
 	res := router.Dispatch(req.Method, req.Target)
 
+	// Dispatch puede tardar (jobs síncronos, /jobs/wait): el plazo de
+	// escritura corre recién desde que hay respuesta
+	_ = conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+
 	// Mezcla headers de trazabilidad con los del Result (si tienes ese campo)
 	hdrs := map[string]string{}
 	for k, v := range trace {
@@ -71,7 +331,19 @@ func HandleConn(c net.Conn) {
 		}
 	}
 
-	if res.JSON {
+	// gzip negociado con Accept-Encoding (q-values); sólo cuerpos de texto/JSON
+	// grandes: los errores son chicos y lo binario suele venir comprimido
+	if res.Bytes == nil && res.Err == nil && len(res.Body) >= http10.GzipMinSize && http10.AcceptsGzip(req.Header["accept-encoding"]) {
+		if gz, err := http10.GzipBody(res.Body); err == nil {
+			res.Body = gz
+			hdrs["Content-Encoding"] = "gzip"
+			hdrs["Vary"] = "Accept-Encoding"
+		}
+	}
+
+	if res.Bytes != nil {
+		http10.WriteBinaryH(c, res.Status, res.ContentType, res.Bytes, hdrs)
+	} else if res.JSON {
 		if res.Err != nil {
 			http10.WriteErrorJSON(c, res.Status, res.Err.Code, res.Err.Detail, hdrs)
 		} else {
@@ -80,6 +352,7 @@ func HandleConn(c net.Conn) {
 	} else {
 		http10.WritePlainH(c, res.Status, res.Body, hdrs)
 	}
+	return keepAlive && c.err == nil
 }
 
 func ListenAndServe(addr string) error {
@@ -87,14 +360,98 @@ func ListenAndServe(addr string) error {
 	if err != nil {
 		return err
 	}
+	done := make(chan struct{})
+	lnMu.Lock()
+	listeners[ln] = done
+	atomic.StoreInt32(&shuttingDown, 0)
+	lnMu.Unlock()
+	defer close(done)
 	defer ln.Close()
 
+	// semáforo de conexiones atendidas (nil = sin límite) y de rechazos en curso
+	var sem chan struct{}
+	if MaxConns > 0 {
+		sem = make(chan struct{}, MaxConns)
+	}
+	rejecters := make(chan struct{}, maxRejecters)
+
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
+			lnMu.Lock()
+			_, active := listeners[ln]
+			delete(listeners, ln)
+			lnMu.Unlock()
+			if !active { // lo cerró Shutdown
+				return ErrServerClosed
+			}
 			return err
 		}
 		atomic.AddUint64(&connCount, 1) // cuenta conexiones aceptadas
-		go HandleConn(conn)
+		inflight.Add(1)
+		if sem == nil {
+			go func() {
+				defer inflight.Done()
+				HandleConn(conn)
+			}()
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+			go func() {
+				defer inflight.Done()
+				defer func() { <-sem }()
+				HandleConn(conn)
+			}()
+		default:
+			// lleno: se rechaza sin bloquear el accept loop; si ya hay
+			// maxRejecters respondiendo, se cierra sin más
+			atomic.AddUint64(&busyRejected, 1)
+			select {
+			case rejecters <- struct{}{}:
+				go func() {
+					defer inflight.Done()
+					defer func() { <-rejecters }()
+					defer conn.Close()
+					rejectConn(conn, 503, "too_busy", "server at connection limit")
+				}()
+			default:
+				conn.Close()
+				inflight.Done()
+			}
+		}
+	}
+}
+
+// Shutdown deja de aceptar conexiones (cierra los listeners de
+// ListenAndServe, que devuelve ErrServerClosed) y espera a que terminen las
+// que están en vuelo, hasta el deadline de ctx (devuelve ctx.Err() si vence;
+// esas conexiones siguen su curso). Las keep-alive reciben Connection: close
+// en su respuesta siguiente.
+func Shutdown(ctx context.Context) error {
+	lnMu.Lock()
+	atomic.StoreInt32(&shuttingDown, 1)
+	dones := make([]chan struct{}, 0, len(listeners))
+	for ln, done := range listeners {
+		delete(listeners, ln)
+		_ = ln.Close()
+		dones = append(dones, done)
+	}
+	lnMu.Unlock()
+	// con el accept loop parado ya no hay inflight.Add nuevos
+	for _, done := range dones {
+		<-done
+	}
+
+	idle := make(chan struct{})
+	go func() {
+		inflight.Wait()
+		close(idle)
+	}()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
@@ -0,0 +1,25 @@
+package util
+
+import "context"
+
+// ProgressFunc recibe el avance de una tarea larga (done de total unidades).
+type ProgressFunc func(done, total int64)
+
+type progressKey struct{}
+
+// WithProgress adjunta un sink de progreso al contexto. El Job Manager lo usa
+// para que los handlers reporten avance sin depender del paquete jobs.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress publica (done, total) en el sink del contexto, si existe.
+// Es no-op para peticiones síncronas (sin sink).
+func ReportProgress(ctx context.Context, done, total int64) {
+	if ctx == nil {
+		return
+	}
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok && fn != nil {
+		fn(done, total)
+	}
+}
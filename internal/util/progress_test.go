@@ -0,0 +1,23 @@
+package util
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReportProgress_SinkAndNoop(t *testing.T) {
+	t.Parallel()
+
+	var gotDone, gotTotal int64
+	ctx := WithProgress(context.Background(), func(done, total int64) {
+		gotDone, gotTotal = done, total
+	})
+	ReportProgress(ctx, 3, 10)
+	if gotDone != 3 || gotTotal != 10 {
+		t.Fatalf("sink got (%d,%d) want (3,10)", gotDone, gotTotal)
+	}
+
+	// sin sink (o ctx nil) no debe paniquear
+	ReportProgress(context.Background(), 1, 2)
+	ReportProgress(nil, 1, 2)
+}